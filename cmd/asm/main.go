@@ -0,0 +1,96 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	outputName := flag.String("o", "", "output INF file, default is LEVELn.INF in the current folder")
+	raw := flag.Bool("raw", false, "write the decompressed INF data instead of a CPS file")
+	verify := flag.Bool("verify", false, "disassemble and reassemble the level and compare the result with the original bytes")
+	flag.Parse()
+
+	if *verify && flag.NArg() == 2 {
+		if err := verifyLevel(flag.Arg(0), flag.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.NArg() != 3 {
+		fmt.Printf("Usage: asm [flags] SOURCE EOB1DATA_DIR LEVEL\n       asm -verify EOB1DATA_DIR LEVEL\neg: asm -o LEVEL1.INF level1.s /home/joe/EOB1 1\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	infHeader, err := formats.LoadInf(flag.Arg(1), flag.Arg(2))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer source.Close()
+	script, triggers, err := inf.Assemble(source)
+	if err != nil {
+		log.Fatalf("%s: %s", flag.Arg(0), err)
+	}
+
+	data, err := infHeader.EncodeWithScript(script, triggers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*raw {
+		if data, err = formats.EncodeCPS(data); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fileName := *outputName
+	if fileName == "" {
+		fileName = "LEVEL" + flag.Arg(2) + ".INF"
+	}
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d bytes of script and %d triggers written to %s.", script.End-script.Offset, len(triggers), fileName)
+}
+
+// verifyLevel checks that disassembling and reassembling the level reproduces the decompressed INF data.
+func verifyLevel(dataDir, level string) error {
+	infHeader, err := formats.LoadInf(dataDir, level)
+	if err != nil {
+		return err
+	}
+
+	var source bytes.Buffer
+	if err := inf.WriteText(&source, infHeader.Script, infHeader.Triggers); err != nil {
+		return err
+	}
+	script, triggers, err := inf.Assemble(&source)
+	if err != nil {
+		return fmt.Errorf("reassembling the disassembly: %w", err)
+	}
+	data, err := infHeader.EncodeWithScript(script, triggers)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(data, infHeader.RawData) {
+		for i := 0; i < len(data) && i < len(infHeader.RawData); i++ {
+			if data[i] != infHeader.RawData[i] {
+				return fmt.Errorf("LEVEL%s.INF differs at $%04x", level, i)
+			}
+		}
+		return fmt.Errorf("LEVEL%s.INF differs in length: %d instead of %d bytes", level, len(data), len(infHeader.RawData))
+	}
+	fmt.Printf("LEVEL%s.INF: %d instructions and %d triggers reassembled identically.\n", level, len(script.Instructions), len(triggers))
+	return nil
+}
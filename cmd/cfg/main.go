@@ -0,0 +1,68 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	triggerIndex := flag.Int("trigger", -1, "only export the blocks reached from the trigger with this index of the trigger table")
+	outputName := flag.String("o", "", "output DOT file, default is stdout")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: cfg [flags] EOB1DATA_DIR LEVEL\neg: cfg -o level1.dot /home/joe/EOB1 1 && dot -Tsvg -o level1.svg level1.dot\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	infHeader, err := formats.LoadInf(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	graph := inf.BuildGraph(infHeader.Script, infHeader.Triggers)
+	for _, block := range graph.UnreachableBlocks() {
+		log.Printf("Unreachable block %s-%s", inf.Label(block.Start), inf.Label(block.End))
+	}
+	for _, block := range graph.Blocks {
+		if block.FallsIntoTable {
+			log.Printf("Block %s falls into the trigger table at %s", inf.Label(block.Start), inf.Label(infHeader.Script.End))
+		}
+	}
+	for _, target := range graph.InvalidTargets() {
+		log.Printf("%s is not the address of an instruction", inf.Label(target))
+	}
+
+	name := "level" + flag.Arg(1)
+	var roots []inf.Trigger
+	if *triggerIndex >= 0 {
+		for _, trigger := range infHeader.Triggers {
+			if trigger.Index == *triggerIndex {
+				roots = append(roots, trigger)
+			}
+		}
+		if len(roots) == 0 {
+			log.Fatalf("Level %s has no trigger %d", flag.Arg(1), *triggerIndex)
+		}
+		name = fmt.Sprintf("%s_trigger%d", name, *triggerIndex)
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputName != "" {
+		file, err := os.Create(*outputName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		output = file
+	}
+	if err := graph.WriteDOT(output, name, roots); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"EOB1MazeViewer/renderer"
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	outputName := flag.String("o", "", "output charset file, default is stdout")
+	baseName := flag.String("base", formats.ASCII.Name, "code page of the release the letters drawn on glyphs replace: ascii, cp437 (German) or cp850 (French)")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: charset [flags] ENGLISH_EOB1DATA_DIR EOB1DATA_DIR\neg: charset -o german.charset /home/joe/EOB1 /home/joe/EOB1DE\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	base, ok := formats.Charsets[*baseName]
+	if !ok {
+		log.Fatalf("Unknown base charset %s", *baseName)
+	}
+	english, err := formats.UnPak(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	localized, err := formats.UnPak(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	usage, err := countMessageBytes(localized)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output := &bytes.Buffer{}
+	if err := writeCharset(output, flag.Arg(1), base, english, localized, usage); err != nil {
+		log.Fatal(err)
+	}
+	if *outputName == "" {
+		os.Stdout.Write(output.Bytes())
+		return
+	}
+	if err := os.WriteFile(*outputName, output.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s written, fill in the characters of the changed glyphs.", *outputName)
+}
+
+// countMessageBytes returns how often every byte occurs in the messages of the levels.
+func countMessageBytes(dataFiles map[string]*[]byte) ([256]int, error) {
+	var usage [256]int
+	for _, number := range formats.LevelNumbers(dataFiles) {
+		header, err := formats.NewInfFromDataFiles(dataFiles, strconv.Itoa(number))
+		if err != nil {
+			return usage, fmt.Errorf("LEVEL%d.INF: %w", number, err)
+		}
+		for _, instruction := range header.Script.Instructions {
+			if message, ok := instruction.(*inf.Message); ok {
+				for i := 0; i < len(message.Text); i++ {
+					usage[message.Text[i]]++
+				}
+			}
+		}
+	}
+	return usage, nil
+}
+
+// writeCharset writes a charset file listing the glyphs of the fonts of a release differing from the English
+// release and the bytes of the messages without a glyph and a character of the base charset. The lines of the
+// bytes are commented out until their characters are filled in.
+func writeCharset(w io.Writer, release string, base *formats.Charset, english, localized map[string]*[]byte, usage [256]int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "; Charset of %s, derived by comparing its fonts with the English release.\n", release)
+	fmt.Fprintf(bw, "; Replace ? by the character drawn by the glyph and remove the ; of the line.\n")
+	if base != formats.ASCII {
+		fmt.Fprintf(bw, "base %s\n", base.Name)
+	}
+
+	changed := map[byte]bool{}
+	for _, name := range renderer.FontNames {
+		englishFont, localizedFont, err := loadFonts(name, english, localized)
+		if err != nil {
+			return err
+		}
+		if englishFont == nil {
+			continue
+		}
+		for c := 0; c < 0x80; c++ {
+			englishGlyph, localizedGlyph := englishFont.GetGlyph(byte(c)), localizedFont.GetGlyph(byte(c))
+			if bytes.Equal(englishGlyph, localizedGlyph) {
+				continue
+			}
+			fmt.Fprintf(bw, ";\n; %s $%02x, English %s, used %d times in the messages\n", name, c, strconv.QuoteRune(rune(c)), usage[c])
+			for y := 0; y < localizedFont.Height; y++ {
+				fmt.Fprintf(bw, ";   %s  %s\n", glyphRow(englishFont, englishGlyph, y), glyphRow(localizedFont, localizedGlyph, y))
+			}
+			if !changed[byte(c)] {
+				fmt.Fprintf(bw, "; $%02x ?\n", c)
+			}
+			changed[byte(c)] = true
+		}
+	}
+
+	for c := 0x80; c < 0x100; c++ {
+		if usage[c] == 0 {
+			continue
+		}
+		if decoded := base.Decode(string([]byte{byte(c)})); formats.ASCII.Decode(string([]byte{byte(c)})) != decoded {
+			fmt.Fprintf(bw, ";\n; $%02x has no glyph, used %d times in the messages, %s in %s\n", c, usage[c], strconv.Quote(decoded), base.Name)
+			continue
+		}
+		fmt.Fprintf(bw, ";\n; $%02x has no glyph, used %d times in the messages\n; $%02x ?\n", c, usage[c], c)
+	}
+	if len(changed) == 0 {
+		fmt.Fprintf(bw, ";\n; The fonts have the same glyphs as the English release.\n")
+	}
+	return bw.Flush()
+}
+
+// loadFonts returns a font of both releases, nil if a release does not have it. The fonts must have the same
+// glyph size.
+func loadFonts(name string, english, localized map[string]*[]byte) (*formats.FNT, *formats.FNT, error) {
+	englishData, ok := english[name]
+	localizedData, ok2 := localized[name]
+	if !ok || !ok2 {
+		return nil, nil, nil
+	}
+	englishFont, err := formats.NewFNTFromByteArray(englishData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("English %s: %w", name, err)
+	}
+	localizedFont, err := formats.NewFNTFromByteArray(localizedData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if englishFont.Width != localizedFont.Width || englishFont.Height != localizedFont.Height {
+		return nil, nil, fmt.Errorf("%s has %dx%d glyphs, the English font %dx%d", name, localizedFont.Width, localizedFont.Height,
+			englishFont.Width, englishFont.Height)
+	}
+	return englishFont, localizedFont, nil
+}
+
+// glyphRow draws a row of a glyph with # and ., a missing glyph blank.
+func glyphRow(font *formats.FNT, glyph []byte, y int) string {
+	if glyph == nil {
+		return strings.Repeat(" ", font.Width)
+	}
+	var b strings.Builder
+	for x := 0; x < font.Width; x++ {
+		if font.IsSet(glyph, x, y) {
+			b.WriteByte('#')
+		} else {
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+func main() {
+	format := flag.String("format", "text", "output format: text (assembly syntax), pseudo (structured pseudo-code), json or ca65 (C64 port bytecode)")
+	outputName := flag.String("o", "", "output file, default is stdout")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: disasm [flags] EOB1DATA_DIR LEVEL\neg: disasm -format json -o level1.json /home/joe/EOB1 1\n    disasm -format ca65 -o level1.s /home/joe/EOB1 1\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	infHeader, err := formats.LoadInf(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the output is written once complete, a level that cannot be converted leaves no file
+	output := &bytes.Buffer{}
+	switch *format {
+	case "text":
+		err = inf.WriteText(output, infHeader.Script, infHeader.Triggers)
+	case "pseudo":
+		err = inf.Decompile(output, infHeader.Script, infHeader.Triggers)
+	case "json":
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		err = encoder.Encode(inf.NewListing(infHeader.Script, infHeader.Triggers))
+	case "ca65":
+		var level int
+		if level, err = strconv.Atoi(flag.Arg(1)); err != nil || level < 1 {
+			log.Fatalf("Invalid level %s, the C64 bytecode needs the level number", flag.Arg(1))
+		}
+		err = inf.WriteCA65(output, infHeader.Script, infHeader.Triggers, level)
+	default:
+		err = fmt.Errorf("unknown format %s", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *outputName == "" {
+		_, err = os.Stdout.Write(output.Bytes())
+	} else {
+		err = os.WriteFile(*outputName, output.Bytes(), 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
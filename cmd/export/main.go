@@ -0,0 +1,179 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/postprocess"
+	"EOB1MazeViewer/renderer"
+	"EOB1MazeViewer/scaler"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// presentation is applied to the exported views, without scaler and effects they are written as paletted PNGs.
+// With a screen compositor the views are placed into the game screen, a text is written as a game message.
+type presentation struct {
+	screen    *renderer.ScreenCompositor
+	text      string
+	textColor byte
+	font      *renderer.TextRenderer
+	scaler    scaler.Scaler
+	options   postprocess.Options
+}
+
+func (p presentation) apply(img *image.Paletted, direction int) image.Image {
+	if p.screen != nil {
+		img = p.screen.Compose(img, direction, nil)
+	}
+	if p.text != "" {
+		p.font.DrawMessage(img, p.text, p.textColor)
+	}
+	if p.scaler == scaler.None && p.options == (postprocess.Options{}) {
+		return img
+	}
+
+	pixels, width, height := p.scaler.Scale(scaler.Pack(img), img.Rect.Dx(), img.Rect.Dy())
+	pixels, width, height = postprocess.Apply(pixels, width, height, p.scaler.Factor(), p.options)
+	return scaler.ToRGBA(pixels, width, height)
+}
+
+func main() {
+	x := flag.Int("x", 10, "x position of the party")
+	y := flag.Int("y", 15, "y position of the party")
+	direction := flag.Int("dir", 0, "direction of the party: 0 north, 1 east, 2 south, 3 west")
+	outputName := flag.String("o", "view.png", "output PNG file, or the output folder with -all")
+	all := flag.Bool("all", false, "export every position and direction of the level")
+	scalerName := flag.String("scaler", scaler.None.Name(), "upscaler: "+strings.Join(scaler.Names(), ", "))
+	crt := flag.Bool("crt", false, "CRT filter with scanlines, slot mask and bloom")
+	screen := flag.Bool("screen", false, "export the views inside the 320x200 game screen with the playfield frame and the compass")
+	aspect := flag.Bool("aspect", false, "stretch the views vertically by 1.2 like on the 4:3 monitors of the time")
+	text := flag.String("text", "", "message written into the text area of the screen, or over the views without -screen")
+	textColor := flag.Int("text-color", 15, "palette index of the message text")
+	itemSpritesName := flag.String("item-sprites", "", "sprite map file selecting the floor sprites of the item pictures, without it items lie on the floor as icons")
+	bench := flag.Int("bench", 0, "render every position and direction of the level the given number of times and print the timings instead of exporting")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: export [flags] EOB1DATA_DIR LEVEL\neg: export -x 10 -y 15 -dir 0 -o view.png /home/joe/EOB1 8\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	viewScaler, err := scaler.ByName(*scalerName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	output := presentation{text: *text, textColor: byte(*textColor), scaler: viewScaler, options: postprocess.Options{AspectCorrection: *aspect, CRT: *crt}}
+
+	dataFiles, err := formats.UnPak(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var itemSprites map[int]formats.ItemSprite
+	if *itemSpritesName != "" {
+		if itemSprites, err = formats.LoadItemSprites(*itemSpritesName); err != nil {
+			log.Fatal(err)
+		}
+	}
+	mazeRenderer, err := renderer.LoadLevel(dataFiles, flag.Arg(1), itemSprites)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *screen {
+		output.screen = renderer.NewScreenCompositor(dataFiles, mazeRenderer.GetPalette())
+	}
+	if *text != "" {
+		if output.font, err = renderer.LoadTextRenderer(dataFiles); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch {
+	case *bench > 0:
+		runBenchmark(mazeRenderer, *bench)
+	case *all:
+		err = exportAll(mazeRenderer, output, flag.Arg(1), *outputName)
+	default:
+		err = exportView(mazeRenderer, output, *x, *y, *direction, *outputName, nil)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func exportView(mazeRenderer *renderer.MazeRenderer, output presentation, x, y, direction int, fileName string, dst *image.Paletted) error {
+	img, err := mazeRenderer.RenderMaze(x, y, direction, dst)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return png.Encode(file, output.apply(img, direction))
+}
+
+// exportAll writes level-L-x-y-dir.png for every block and direction into the folder.
+func exportAll(mazeRenderer *renderer.MazeRenderer, output presentation, level string, folder string) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	width, height := mazeRenderer.GetMazeSize()
+	dst := image.NewPaletted(image.Rect(0, 0, renderer.ViewportWidth, renderer.ViewportHeight), mazeRenderer.GetPalette())
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for direction := 0; direction < 4; direction++ {
+				fileName := filepath.Join(folder, fmt.Sprintf("level-%s-%d-%d-%d.png", level, x, y, direction))
+				if err := exportView(mazeRenderer, output, x, y, direction, fileName, dst); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	log.Printf("%d views written to %s.", width*height*4, folder)
+	return nil
+}
+
+// runBenchmark renders the whole level into a reused image and prints the time and the allocations per frame.
+func runBenchmark(mazeRenderer *renderer.MazeRenderer, iterations int) {
+	width, height := mazeRenderer.GetMazeSize()
+	dst := image.NewPaletted(image.Rect(0, 0, renderer.ViewportWidth, renderer.ViewportHeight), mazeRenderer.GetPalette())
+
+	// first pass builds the wall bitmaps
+	renderLevel(mazeRenderer, width, height, dst)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		renderLevel(mazeRenderer, width, height, dst)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	frames := uint64(iterations * width * height * 4)
+	fmt.Printf("%d frames in %s\n", frames, elapsed)
+	fmt.Printf("%s/frame, %d allocs/frame, %d B/frame\n",
+		elapsed/time.Duration(frames), (after.Mallocs-before.Mallocs)/frames, (after.TotalAlloc-before.TotalAlloc)/frames)
+}
+
+func renderLevel(mazeRenderer *renderer.MazeRenderer, width, height int, dst *image.Paletted) {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for direction := 0; direction < 4; direction++ {
+				mazeRenderer.RenderMaze(x, y, direction, dst)
+			}
+		}
+	}
+}
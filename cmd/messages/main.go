@@ -0,0 +1,175 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	importName := flag.String("import", "", "PO or CSV file with translated messages to write back into the levels")
+	outputName := flag.String("o", "", "output file of the extracted messages, default is stdout; folder of the imported levels, default is the current folder")
+	format := flag.String("format", "", "format of the messages: po or csv, default is the extension of the file or po")
+	charsetName := flag.String("charset", "ascii", "charset of the release: ascii (English), cp437 (German), cp850 (French) or a charset file made by the charset command")
+	raw := flag.Bool("raw", false, "write the decompressed INF data of the imported levels instead of CPS files")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Printf("Usage: messages [flags] EOB1DATA_DIR\neg: messages -o messages.po /home/joe/EOB1\n    messages -import messages.po -o translated /home/joe/EOB1\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	charset, err := formats.LoadCharset(*charsetName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	levels, err := loadLevels(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *importName != "" {
+		err = importMessages(levels, *importName, messagesFormat(*format, *importName), charset, *outputName, *raw)
+	} else {
+		err = extractMessages(levels, *outputName, messagesFormat(*format, *outputName), charset)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// level is a decoded INF file of the data files.
+type level struct {
+	number int
+	header *formats.InfHeader
+}
+
+// loadLevels returns the levels of the data files in ascending order.
+func loadLevels(dataDir string) ([]level, error) {
+	dataFiles, err := formats.UnPak(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var levels []level
+	for _, number := range formats.LevelNumbers(dataFiles) {
+		header, err := formats.NewInfFromDataFiles(dataFiles, strconv.Itoa(number))
+		if err != nil {
+			return nil, fmt.Errorf("LEVEL%d.INF: %w", number, err)
+		}
+		levels = append(levels, level{number: number, header: header})
+	}
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("cannot find any LEVELn.INF file")
+	}
+	return levels, nil
+}
+
+func messagesFormat(format, fileName string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(fileName), ".csv") {
+		return "csv"
+	}
+	return "po"
+}
+
+func extractMessages(levels []level, outputName, format string, charset *formats.Charset) error {
+	var messages []formats.ScriptMessage
+	for _, l := range levels {
+		messages = append(messages, formats.ExtractMessages(l.number, l.header, charset)...)
+	}
+
+	output := &bytes.Buffer{}
+	var err error
+	switch format {
+	case "po":
+		err = formats.WriteMessagesPO(output, messages, charset)
+	case "csv":
+		err = formats.WriteMessagesCSV(output, messages)
+	default:
+		err = fmt.Errorf("unknown format %s", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if outputName == "" {
+		_, err = os.Stdout.Write(output.Bytes())
+		return err
+	}
+	if err := os.WriteFile(outputName, output.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Printf("%d messages of %d levels written to %s.", len(messages), len(levels), outputName)
+	return nil
+}
+
+func importMessages(levels []level, importName, format string, charset *formats.Charset, outputDir string, raw bool) error {
+	file, err := os.Open(importName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var messages []formats.ScriptMessage
+	switch format {
+	case "po":
+		messages, err = formats.ReadMessagesPO(file)
+	case "csv":
+		messages, err = formats.ReadMessagesCSV(file)
+	default:
+		err = fmt.Errorf("unknown format %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", importName, err)
+	}
+
+	known := map[int]bool{}
+	for _, l := range levels {
+		known[l.number] = true
+	}
+	for _, m := range messages {
+		if !known[m.Level] {
+			return fmt.Errorf("%s: the data files have no level %d", importName, m.Level)
+		}
+	}
+
+	// all levels are encoded before writing, a message that cannot be imported leaves no files
+	files := map[string][]byte{}
+	for _, l := range levels {
+		data, changed, err := l.header.EncodeWithMessages(l.number, messages, charset)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		if !raw {
+			if data, err = formats.EncodeCPS(data); err != nil {
+				return err
+			}
+		}
+		files[fmt.Sprintf("LEVEL%d.INF", l.number)] = data
+	}
+
+	if outputDir == "" {
+		outputDir = "."
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(outputDir, name), data, 0644); err != nil {
+			return err
+		}
+		log.Printf("%s written.", filepath.Join(outputDir, name))
+	}
+	log.Printf("%d of %d levels changed.", len(files), len(levels))
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/vm"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+func main() {
+	partyFile := flag.String("party", "", "JSON file with the party the script conditions are evaluated with")
+	x := flag.Int("x", 10, "x position of the party")
+	y := flag.Int("y", 15, "y position of the party")
+	direction := flag.Int("dir", 0, "direction of the party: 0 north, 1 east, 2 south, 3 west")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: scriptdbg [flags] EOB1DATA_DIR LEVEL\neg: scriptdbg -party party.json /home/joe/EOB1 1\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	party := vm.NewParty()
+	if *partyFile != "" {
+		var err error
+		if party, err = vm.LoadParty(*partyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	party.X, party.Y, party.Direction = *x, *y, *direction&0x03
+
+	levelState, err := loadLevel(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := &session{debugger: vm.NewDebugger(vm.NewMachine(party, levelState, time.Now().UnixNano())), out: os.Stdout}
+	fmt.Printf("%d triggers, type help for the commands.\n", len(levelState.Inf.Triggers))
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("(dbg) ")
+		if !scanner.Scan() || !s.execute(scanner.Text()) {
+			return
+		}
+	}
+}
+
+func loadLevel(dataDir, level string) (*vm.LevelState, error) {
+	dataFiles, err := formats.UnPak(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	infHeader, err := formats.NewInfFromDataFiles(dataFiles, level)
+	if err != nil {
+		return nil, err
+	}
+	mazName := "LEVEL" + level + ".MAZ"
+	if _, ok := dataFiles[mazName]; !ok {
+		return nil, fmt.Errorf("cannot find file %s", mazName)
+	}
+	maz, err := formats.NewMazFromByteArray(dataFiles[mazName])
+	if err != nil {
+		return nil, err
+	}
+
+	levelNumber, _ := strconv.Atoi(level)
+	return vm.NewLevelState(levelNumber, infHeader, vm.NewMazeFromMaz(maz), vm.LoadLevelItems(dataFiles, levelNumber)), nil
+}
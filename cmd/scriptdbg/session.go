@@ -0,0 +1,389 @@
+package main
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"EOB1MazeViewer/vm"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const helpText = `fire X Y [enter|leave|drop|pickup|monster|click]  run the scripts of a block, stops at breakpoints
+s, step                  execute the next instruction
+t, token                 evaluate the next token of a conditional and show the expression stack
+c, continue              run to the next breakpoint or the end of the scripts
+b, break [ADDR|trigger N] set a breakpoint, without argument list the breakpoints
+clear ADDR               remove a breakpoint
+where                    show the current instruction, the expression stack and the call stack
+list [ADDR] [COUNT]      show instructions, from the current instruction by default
+watch maze|global N      watch a maze or global flag
+watch monster M N        watch flag N of monster M, without argument list the watched flags
+flags                    show the set maze, global and monster flags
+party [X Y DIR]          show or move the party
+walls X Y                show the wall mappings of a block
+triggers                 list the trigger table
+q, quit                  leave the debugger`
+
+var eventNames = map[string]vm.Event{
+	"enter": vm.EventEnter, "leave": vm.EventLeave, "drop": vm.EventItemDrop, "pickup": vm.EventItemPickup,
+	"monster": vm.EventMonsterEnter, "click": vm.EventWallClick,
+}
+
+// session executes the commands of the debugger prompt.
+type session struct {
+	debugger *vm.Debugger
+	out      io.Writer
+}
+
+// execute runs a command line and returns false to quit.
+func (s *session) execute(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	var err error
+	switch command, args := fields[0], fields[1:]; command {
+	case "q", "quit", "exit":
+		return false
+	case "help", "h", "?":
+		fmt.Fprintln(s.out, helpText)
+	case "fire":
+		err = s.fire(args)
+	case "s", "step":
+		err = s.step(s.debugger.Step)
+	case "t", "token":
+		err = s.step(s.debugger.StepToken)
+	case "c", "continue":
+		err = s.step(func() error {
+			_, err := s.debugger.Continue()
+			return err
+		})
+	case "b", "break":
+		err = s.setBreakpoint(args)
+	case "clear":
+		err = s.clearBreakpoint(args)
+	case "where":
+		s.where()
+	case "list":
+		err = s.list(args)
+	case "watch":
+		err = s.watch(args)
+	case "flags":
+		s.flags()
+	case "party":
+		err = s.party(args)
+	case "walls":
+		err = s.walls(args)
+	case "triggers":
+		for _, trigger := range inf.SortTriggersByIndex(s.debugger.Machine.Level.Inf.Triggers) {
+			fmt.Fprintf(s.out, "%3d [%d,%d] flags $%02x %s on %s\n", trigger.Index, trigger.Pos.X, trigger.Pos.Y, trigger.Flags, inf.Label(trigger.Address), trigger.Events())
+		}
+	default:
+		err = fmt.Errorf("unknown command %s, type help for the commands", command)
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "error: %s\n", err)
+	}
+	return true
+}
+
+func (s *session) fire(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: fire X Y [event]")
+	}
+	x, errX := strconv.Atoi(args[0])
+	y, errY := strconv.Atoi(args[1])
+	if errX != nil || errY != nil {
+		return fmt.Errorf("invalid position %s,%s", args[0], args[1])
+	}
+	event := vm.EventEnter
+	if len(args) == 3 {
+		var ok bool
+		if event, ok = eventNames[args[2]]; !ok {
+			var err error
+			if event, err = inf.ParseTriggerEvent(args[2]); err != nil {
+				return err
+			}
+		}
+	}
+
+	count := s.debugger.Fire(x, y, event)
+	fmt.Fprintf(s.out, "%d trigger(s) at [%d,%d]\n", count, x, y)
+	if count == 0 || s.debugger.AtBreakpoint() {
+		s.where()
+		return nil
+	}
+	return s.step(func() error {
+		_, err := s.debugger.Continue()
+		return err
+	})
+}
+
+// step runs a step function and reports the messages, the watched flags and the next instruction.
+func (s *session) step(run func() error) error {
+	if s.debugger.Thread == nil {
+		return fmt.Errorf("no script is running, use fire")
+	}
+
+	printed := len(s.debugger.Effects.Messages) + len(s.debugger.Thread.Effects.Messages)
+	err := run()
+	messages := s.debugger.Effects.Messages
+	if s.debugger.Thread != nil {
+		messages = append(append([]vm.Message(nil), messages...), s.debugger.Thread.Effects.Messages...)
+	}
+	for _, message := range messages[min(printed, len(messages)):] {
+		fmt.Fprintf(s.out, "message %q color=%d\n", message.Text, message.Color)
+	}
+	for _, change := range s.debugger.WatchChanges() {
+		fmt.Fprintf(s.out, "%s: %d -> %d\n", change.Watch, change.Old, change.New)
+	}
+	if err != nil {
+		return err
+	}
+	s.where()
+	return nil
+}
+
+// where prints the next instruction, during a conditional the next token and the expression stack.
+func (s *session) where() {
+	thread := s.debugger.Thread
+	if thread == nil {
+		s.summary()
+		return
+	}
+
+	instruction := s.debugger.Current()
+	marker := "=>"
+	if s.debugger.AtBreakpoint() {
+		marker = "*>"
+	}
+	fmt.Fprintf(s.out, "%s %s: %s\n", marker, inf.Label(thread.PC), inf.FormatInstruction(instruction))
+	if conditional, ok := instruction.(*inf.Conditional); ok && thread.Stack != nil {
+		if thread.Token < len(conditional.Expression) {
+			fmt.Fprintf(s.out, "   next token %d/%d: %s\n", thread.Token+1, len(conditional.Expression), inf.FormatToken(conditional.Expression[thread.Token]))
+		} else {
+			fmt.Fprintf(s.out, "   expression evaluated, step to branch\n")
+		}
+		fmt.Fprintf(s.out, "   stack: %v\n", thread.Stack.Values())
+	}
+	if len(thread.CallStack) > 0 {
+		var returns []string
+		for i := len(thread.CallStack) - 1; i >= 0; i-- {
+			returns = append(returns, inf.Label(thread.CallStack[i]))
+		}
+		fmt.Fprintf(s.out, "   returns to %s\n", strings.Join(returns, " "))
+	}
+}
+
+// summary prints the effects of the finished scripts.
+func (s *session) summary() {
+	effects := s.debugger.Effects
+	party := s.debugger.Machine.Party
+	fmt.Fprintf(s.out, "no script running: %d message(s), walls changed %t, party at [%d,%d] direction %d\n",
+		len(effects.Messages), effects.WallsChanged, party.X, party.Y, party.Direction)
+	if change := effects.LevelChange; change != nil {
+		fmt.Fprintf(s.out, "level change to %d at [%d,%d] direction %d\n", change.Level, change.Pos.X, change.Pos.Y, change.Direction)
+	}
+}
+
+func (s *session) setBreakpoint(args []string) error {
+	switch {
+	case len(args) == 0:
+		var addresses []int
+		for address := range s.debugger.Breakpoints {
+			addresses = append(addresses, address)
+		}
+		sort.Ints(addresses)
+		for _, address := range addresses {
+			fmt.Fprintf(s.out, "%s: %s\n", inf.Label(address), inf.FormatInstruction(s.debugger.Machine.Level.Inf.Script.At(address)))
+		}
+		return nil
+	case len(args) == 2 && args[0] == "trigger":
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		for _, trigger := range s.debugger.Machine.Level.Inf.Triggers {
+			if trigger.Index == index {
+				return s.addBreakpoint(trigger.Address)
+			}
+		}
+		return fmt.Errorf("no trigger %d", index)
+	case len(args) == 1:
+		address, err := parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		return s.addBreakpoint(address)
+	}
+	return fmt.Errorf("usage: break [ADDR|trigger N]")
+}
+
+func (s *session) addBreakpoint(address int) error {
+	if s.debugger.Machine.Level.Inf.Script.At(address) == nil {
+		return fmt.Errorf("no instruction at $%04x", address)
+	}
+	s.debugger.Breakpoints[address] = true
+	fmt.Fprintf(s.out, "breakpoint at %s\n", inf.Label(address))
+	return nil
+}
+
+func (s *session) clearBreakpoint(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: clear ADDR")
+	}
+	address, err := parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	delete(s.debugger.Breakpoints, address)
+	return nil
+}
+
+func (s *session) list(args []string) error {
+	script := s.debugger.Machine.Level.Inf.Script
+	address, count := script.Offset, 10
+	if s.debugger.Thread != nil {
+		address = s.debugger.Thread.PC
+	}
+	var err error
+	if len(args) > 0 {
+		if address, err = parseAddress(args[0]); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		if count, err = strconv.Atoi(args[1]); err != nil {
+			return err
+		}
+	}
+
+	index, ok := script.IndexOf(address)
+	if !ok {
+		return fmt.Errorf("no instruction at $%04x", address)
+	}
+	for _, instruction := range script.Instructions[index:min(index+count, len(script.Instructions))] {
+		marker := "  "
+		if s.debugger.Thread != nil && instruction.GetOffset() == s.debugger.Thread.PC {
+			marker = "=>"
+		}
+		if s.debugger.Breakpoints[instruction.GetOffset()] {
+			marker = marker[:1] + "*"
+		}
+		fmt.Fprintf(s.out, "%s %s: %s\n", marker, inf.Label(instruction.GetOffset()), inf.FormatInstruction(instruction))
+	}
+	return nil
+}
+
+func (s *session) watch(args []string) error {
+	var watch vm.FlagWatch
+	switch {
+	case len(args) == 0:
+		for _, watch := range s.debugger.Watches() {
+			fmt.Fprintf(s.out, "%s = %d\n", watch, s.debugger.FlagValue(watch))
+		}
+		return nil
+	case len(args) == 2 && (args[0] == "maze" || args[0] == "global"):
+		watch.Target = inf.FlagMaze
+		if args[0] == "global" {
+			watch.Target = inf.FlagGlobal
+		}
+		flag, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		watch.Flag = flag
+	case len(args) == 3 && args[0] == "monster":
+		monster, errMonster := strconv.Atoi(args[1])
+		flag, errFlag := strconv.Atoi(args[2])
+		if errMonster != nil || errFlag != nil {
+			return fmt.Errorf("invalid monster flag %s %s", args[1], args[2])
+		}
+		watch = vm.FlagWatch{Target: inf.FlagMonster, Monster: monster, Flag: flag}
+	default:
+		return fmt.Errorf("usage: watch maze|global N or watch monster M N")
+	}
+
+	s.debugger.Watch(watch)
+	fmt.Fprintf(s.out, "%s = %d\n", watch, s.debugger.FlagValue(watch))
+	return nil
+}
+
+func (s *session) flags() {
+	machine := s.debugger.Machine
+	fmt.Fprintf(s.out, "maze flags: %s\n", setBits(machine.Level.MazeFlags))
+	fmt.Fprintf(s.out, "global flags: %s\n", setBits(machine.GlobalFlags))
+	for monster, flags := range machine.Level.MonsterFlags {
+		if flags != 0 {
+			fmt.Fprintf(s.out, "monster %d flags: %s\n", monster, setBits(uint32(flags)))
+		}
+	}
+}
+
+func (s *session) party(args []string) error {
+	party := s.debugger.Machine.Party
+	if len(args) == 3 {
+		values := make([]int, 3)
+		for i, arg := range args {
+			value, err := strconv.Atoi(arg)
+			if err != nil {
+				return err
+			}
+			values[i] = value
+		}
+		party.X, party.Y, party.Direction = values[0], values[1], values[2]&0x03
+	} else if len(args) != 0 {
+		return fmt.Errorf("usage: party [X Y DIR]")
+	}
+	fmt.Fprintf(s.out, "party at [%d,%d] direction %d, %d member(s)\n", party.X, party.Y, party.Direction, len(party.Members))
+	return nil
+}
+
+func (s *session) walls(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: walls X Y")
+	}
+	x, errX := strconv.Atoi(args[0])
+	y, errY := strconv.Atoi(args[1])
+	if errX != nil || errY != nil {
+		return fmt.Errorf("invalid position %s,%s", args[0], args[1])
+	}
+	maze := s.debugger.Machine.Level.Maze
+	fmt.Fprintf(s.out, "[%d,%d] north %d east %d south %d west %d\n", x, y,
+		maze.GetWall(x, y, 0), maze.GetWall(x, y, 1), maze.GetWall(x, y, 2), maze.GetWall(x, y, 3))
+	return nil
+}
+
+// setBits lists the numbers of the set bits, "none" if no bit is set.
+func setBits(flags uint32) string {
+	var bits []string
+	for bit := 0; bit < 32; bit++ {
+		if flags&(1<<bit) != 0 {
+			bits = append(bits, strconv.Itoa(bit))
+		}
+	}
+	if len(bits) == 0 {
+		return "none"
+	}
+	return strings.Join(bits, " ")
+}
+
+// parseAddress reads an address as a label _0xNNNN, $NNNN, 0xNNNN or a decimal number.
+func parseAddress(text string) (int, error) {
+	value := strings.TrimPrefix(text, "_")
+	base := 10
+	switch {
+	case strings.HasPrefix(value, "$"):
+		value, base = value[1:], 16
+	case strings.HasPrefix(value, "0x"):
+		value, base = value[2:], 16
+	}
+	address, err := strconv.ParseInt(value, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %s", text)
+	}
+	return int(address), nil
+}
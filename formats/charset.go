@@ -0,0 +1,172 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// unmappedBase is added to the bytes without a character of a charset, they decode to the private use area and
+// survive a round trip through the message files.
+const unmappedBase = 0xf700
+
+// Charset converts the texts of a release between the bytes drawn with its font and UTF-8. The fonts only have
+// glyphs for the bytes below $80 (see FNT), the English release draws them as ASCII. The German and French releases
+// store their letters as the bytes of the DOS code pages 437 and 850, a release drawing its own letters on some of
+// the glyphs below $80 needs a charset file made with the charset command, which compares its font with the
+// English one.
+type Charset struct {
+	Name    string
+	runes   [256]rune
+	encoded map[rune]byte
+}
+
+// ASCII is the charset of the English release, CP437 and CP850 are the code pages of the German and French
+// releases.
+var (
+	ASCII = mustCharset(NewCharset("ascii", nil))
+	CP437 = mustCharset(ASCII.Extend("cp437", codePage("ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒáíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐"+
+		"└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■\u00a0")))
+	CP850 = mustCharset(ASCII.Extend("cp850", codePage("ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜø£Ø×ƒáíóúñÑªº¿®¬½¼¡«»░▒▓│┤ÁÂÀ©╣║╗╝¢¥┐"+
+		"└┴┬├─┼ãÃ╚╔╩╦╠═╬¤ðÐÊËÈıÍÎÏ┘┌█▄¦Ì▀ÓßÔÒõÕµþÞÚÛÙýÝ¯´\u00ad±‗¾¶§÷¸°¨·¹³²■\u00a0")))
+)
+
+// Charsets are the charsets known by name.
+var Charsets = map[string]*Charset{ASCII.Name: ASCII, CP437.Name: CP437, CP850.Name: CP850}
+
+// codePage returns the characters of the bytes from $80 of a DOS code page.
+func codePage(upper string) map[byte]rune {
+	characters := map[byte]rune{}
+	for i, r := range []rune(upper) {
+		characters[byte(0x80+i)] = r
+	}
+	return characters
+}
+
+// NewCharset returns ASCII with the characters of some bytes replaced.
+func NewCharset(name string, characters map[byte]rune) (*Charset, error) {
+	var runes [256]rune
+	for i := range runes {
+		runes[i] = rune(i)
+		if i >= 0x80 {
+			runes[i] = unmappedBase + rune(i)
+		}
+	}
+	return newCharset(name, runes, characters)
+}
+
+// Extend returns the charset with the characters of some bytes replaced. A byte from $80 having one of the new
+// characters is left without a character, a letter drawn on a glyph replaces the one of the code page.
+func (c *Charset) Extend(name string, characters map[byte]rune) (*Charset, error) {
+	runes := c.runes
+	for b, r := range characters {
+		if other, ok := c.encoded[r]; ok && other != b && other >= 0x80 {
+			runes[other] = unmappedBase + rune(other)
+		}
+	}
+	return newCharset(name, runes, characters)
+}
+
+func newCharset(name string, runes [256]rune, characters map[byte]rune) (*Charset, error) {
+	c := &Charset{Name: name, runes: runes, encoded: make(map[rune]byte)}
+	for b, r := range characters {
+		c.runes[b] = r
+	}
+	for i, r := range c.runes {
+		if other, ok := c.encoded[r]; ok {
+			return nil, fmt.Errorf("charset %s has %q at $%02x and $%02x", name, r, other, i)
+		}
+		c.encoded[r] = byte(i)
+	}
+	return c, nil
+}
+
+func mustCharset(c *Charset, err error) *Charset {
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ReadCharset reads a charset file: a byte as $xx and its character per line, ; starts a comment. A line
+// "base NAME" replaces the characters of a charset of Charsets instead of ASCII.
+func ReadCharset(r io.Reader, name string) (*Charset, error) {
+	base := ASCII
+	baseLine := 0
+	characters := map[byte]rune{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), ";")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "base" {
+			var ok bool
+			if len(fields) != 2 || baseLine != 0 {
+				return nil, fmt.Errorf("line %d: expected a single base charset", line)
+			}
+			if base, ok = Charsets[fields[1]]; !ok {
+				return nil, fmt.Errorf("line %d: unknown base charset %s", line, fields[1])
+			}
+			baseLine = line
+			continue
+		}
+		var b byte
+		if len(fields) != 2 || utf8.RuneCountInString(fields[1]) != 1 {
+			return nil, fmt.Errorf("line %d: expected a byte and a character", line)
+		}
+		if _, err := fmt.Sscanf(fields[0], "$%x", &b); err != nil {
+			return nil, fmt.Errorf("line %d: invalid byte %s", line, fields[0])
+		}
+		if _, ok := characters[b]; ok {
+			return nil, fmt.Errorf("line %d: $%02x is mapped twice", line, b)
+		}
+		characters[b], _ = utf8.DecodeRuneInString(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return base.Extend(name, characters)
+}
+
+// LoadCharset returns a charset of Charsets by its name, otherwise the charset of a charset file.
+func LoadCharset(name string) (*Charset, error) {
+	if charset, ok := Charsets[name]; ok {
+		return charset, nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCharset(file, strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))
+}
+
+// Decode returns the UTF-8 text of the bytes of a text.
+func (c *Charset) Decode(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		b.WriteRune(c.runes[text[i]])
+	}
+	return b.String()
+}
+
+// Encode returns the bytes of a UTF-8 text, an error for characters the charset does not have.
+func (c *Charset) Encode(text string) (string, error) {
+	var b strings.Builder
+	for _, r := range text {
+		encoded, ok := c.encoded[r]
+		if !ok {
+			return "", fmt.Errorf("%q is not in charset %s", r, c.Name)
+		}
+		b.WriteByte(encoded)
+	}
+	return b.String(), nil
+}
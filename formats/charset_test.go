@@ -0,0 +1,80 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASCII(t *testing.T) {
+	text := "Hello\rworld \x81\xff"
+	decoded := ASCII.Decode(text)
+	if decoded != "Hello\rworld " {
+		t.Errorf("decoded as %q", decoded)
+	}
+	if encoded, err := ASCII.Encode(decoded); err != nil || encoded != text {
+		t.Errorf("encoded as %q, %v", encoded, err)
+	}
+	if _, err := ASCII.Encode("Ärger"); err == nil {
+		t.Error("Ä encoded")
+	}
+}
+
+func TestReadCharset(t *testing.T) {
+	charset, err := ReadCharset(strings.NewReader("; German\n$5b Ä\n\n$5c ö ; umlaut\n$81 ß\n"), "german")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded := charset.Decode("[rger \\ \x81 \x82"); decoded != "Ärger ö ß " {
+		t.Errorf("decoded as %q", decoded)
+	}
+	if encoded, err := charset.Encode("Ärger ö ß "); err != nil || encoded != "[rger \\ \x81 \x82" {
+		t.Errorf("encoded as %q, %v", encoded, err)
+	}
+	if _, err := charset.Encode("["); err == nil {
+		t.Error("character of a replaced glyph encoded")
+	}
+}
+
+func TestCodePages(t *testing.T) {
+	tests := []struct {
+		charset *Charset
+		text    string
+		decoded string
+	}{
+		{CP437, "Gr\x81\xe1e, T\x81r \x8e \x99 \x9a", "Grüße, Tür Ä Ö Ü"},
+		{CP850, "Ev\x82nement \x90p\x82e \xb7 \xd2 \x85", "Evénement Épée À Ê à"},
+	}
+	for _, test := range tests {
+		if decoded := test.charset.Decode(test.text); decoded != test.decoded {
+			t.Errorf("%s: decoded as %q", test.charset.Name, decoded)
+		}
+		if encoded, err := test.charset.Encode(test.decoded); err != nil || encoded != test.text {
+			t.Errorf("%s: encoded as %q, %v", test.charset.Name, encoded, err)
+		}
+		if charset, err := LoadCharset(test.charset.Name); err != nil || charset != test.charset {
+			t.Errorf("%s loaded as %v, %v", test.charset.Name, charset, err)
+		}
+	}
+}
+
+func TestReadCharsetBase(t *testing.T) {
+	charset, err := ReadCharset(strings.NewReader("base cp437 ; German\n$5b Ä\n"), "german")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ä is drawn on the glyph of [, its byte of the code page is kept as an unmapped byte
+	if decoded := charset.Decode("[ \x8e \x81"); decoded != "Ä \uf78e ü" {
+		t.Errorf("decoded as %q", decoded)
+	}
+	if encoded, err := charset.Encode("Ä \uf78e ü"); err != nil || encoded != "[ \x8e \x81" {
+		t.Errorf("encoded as %q, %v", encoded, err)
+	}
+}
+
+func TestReadCharsetErrors(t *testing.T) {
+	for _, text := range []string{"$5b", "$5b Ä ö", "$5b Äö", "5b Ä", "$5b Ä\n$5b Ö", "$5b A", "$5b Ä\n$5c Ä", "base", "base cp999", "base cp437\nbase cp850", "base cp437 cp850"} {
+		if _, err := ReadCharset(strings.NewReader(text), "bad"); err == nil {
+			t.Errorf("%q read", text)
+		}
+	}
+}
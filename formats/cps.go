@@ -34,15 +34,6 @@ func NewCPSFromByteArray(data *[]byte) (*CPS, error) {
 		return nil, fmt.Errorf("invalid data stream length, cannot load CPS file")
 	}
 
-	compressionType := int(binary.LittleEndian.Uint16((*data)[2:]))
-	fmt.Printf("\tcompressionType: %d\n", compressionType)
-
-	uncompressedSize := int(binary.LittleEndian.Uint32((*data)[4:]))
-	fmt.Printf("\tuncompressedSize: %d\n", uncompressedSize)
-
-	paletteSize := int(binary.LittleEndian.Uint16((*data)[8:]))
-	fmt.Printf("\tpalette size: %d\n", paletteSize)
-
 	var decompressedData []byte
 	var err error
 
@@ -69,7 +60,6 @@ func NewCPSFromByteArray(data *[]byte) (*CPS, error) {
 }
 
 func NewCPSFromFile(cpsFilename string) (*CPS, error) {
-	fmt.Printf("Processing CPS %s", cpsFilename)
 	data, err := os.ReadFile(cpsFilename)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", cpsFilename, ErrOpenFile)
@@ -91,6 +81,21 @@ func NewCPSFromFile(cpsFilename string) (*CPS, error) {
 
 }
 
+// EncodeCPS stores data as an uncompressed CPS (compression type 0) without palette.
+func EncodeCPS(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyFile
+	}
+	if len(data) > MaxSrcLen-10 {
+		return nil, ErrOverflow
+	}
+
+	result := make([]byte, 10, 10+len(data))
+	binary.LittleEndian.PutUint16(result, uint16(8+len(data)))
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(data)))
+	return append(result, data...), nil
+}
+
 func cpsCopy(src []byte) ([]byte, error) {
 	if len(src) < 6 {
 		return nil, ErrUnknownComp
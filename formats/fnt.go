@@ -0,0 +1,73 @@
+package formats
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+const (
+	fntGlyphCount  = 128
+	fntHeightIndex = 0x102
+	fntWidthIndex  = 0x103
+)
+
+// FNT is a bitmap font of the DOS version: a size word, 128 glyph offsets, the glyph height and width followed
+// by the glyphs. Every glyph row is one byte, the leftmost pixel is the most significant bit.
+type FNT struct {
+	Width  int
+	Height int
+	glyphs [][]byte
+}
+
+func NewFNTFromByteArray(data *[]byte) (*FNT, error) {
+	if len(*data) < fntWidthIndex+1 {
+		return nil, fmt.Errorf("file too short")
+	}
+
+	size := int(binary.LittleEndian.Uint16(*data))
+	if size != len(*data)-2 {
+		return nil, fmt.Errorf("invalid font size %d for file length %d", size, len(*data))
+	}
+
+	fnt := &FNT{
+		Width:  int((*data)[fntWidthIndex]),
+		Height: int((*data)[fntHeightIndex]),
+		glyphs: make([][]byte, fntGlyphCount),
+	}
+	if fnt.Width == 0 || fnt.Width > 8 || fnt.Height == 0 {
+		return nil, fmt.Errorf("unsupported glyph size %dx%d", fnt.Width, fnt.Height)
+	}
+
+	for i := 0; i < fntGlyphCount; i++ {
+		offset := int(binary.LittleEndian.Uint16((*data)[2+i*2:]))
+		if offset == 0 || offset+fnt.Height > len(*data) {
+			continue
+		}
+		fnt.glyphs[i] = (*data)[offset : offset+fnt.Height]
+	}
+
+	return fnt, nil
+}
+
+func NewFNTFromFile(filename string) (*FNT, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFNTFromByteArray(&data)
+}
+
+// GetGlyph returns the rows of a character, nil if the font has no glyph for it.
+func (f *FNT) GetGlyph(c byte) []byte {
+	if int(c) >= len(f.glyphs) {
+		return nil
+	}
+	return f.glyphs[c]
+}
+
+// IsSet reports whether the pixel x, y of the glyph row data is drawn.
+func (f *FNT) IsSet(glyph []byte, x, y int) bool {
+	return glyph[y]&(0x80>>uint(x)) != 0
+}
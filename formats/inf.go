@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"golang.org/x/exp/maps"
+	"log"
+	"sort"
 	"strings"
 )
 
@@ -55,6 +57,9 @@ type InfHeader struct {
 	Monster2Name                       string
 	Monsters                           [30]Monster
 	WallMapping                        map[int]WallMapping
+	Triggers                           []inf.Trigger
+	Script                             *inf.Script
+	RawData                            []byte
 }
 
 type WallMapping struct {
@@ -106,6 +111,38 @@ func NewInfFromFile(filename string) (*InfHeader, error) {
 	return buildInfHeader(cps, err)
 }
 
+// NewInfFromDataFiles decodes LEVELn.INF of the files returned by UnPak.
+func NewInfFromDataFiles(dataFiles map[string]*[]byte, level string) (*InfHeader, error) {
+	infName := "LEVEL" + level + ".INF"
+	data, ok := dataFiles[infName]
+	if !ok {
+		return nil, fmt.Errorf("cannot find file %s", infName)
+	}
+	return NewInfFromByteArray(data)
+}
+
+// LevelNumbers returns the numbers of the LEVELn.INF files of the files returned by UnPak in ascending order.
+func LevelNumbers(dataFiles map[string]*[]byte) []int {
+	var levels []int
+	for name := range dataFiles {
+		var number int
+		if _, err := fmt.Sscanf(name, "LEVEL%d.INF", &number); err == nil && name == fmt.Sprintf("LEVEL%d.INF", number) {
+			levels = append(levels, number)
+		}
+	}
+	sort.Ints(levels)
+	return levels
+}
+
+// LoadInf decodes LEVELn.INF of the PAK files in the data folder.
+func LoadInf(dataDir, level string) (*InfHeader, error) {
+	dataFiles, err := UnPak(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewInfFromDataFiles(dataFiles, level)
+}
+
 func buildInfHeader(cps *CPS, err error) (*InfHeader, error) {
 	var internalInfHeader rawInfHeader
 
@@ -125,9 +162,15 @@ func buildInfHeader(cps *CPS, err error) (*InfHeader, error) {
 	}
 
 	triggers, err := inf.LoadTriggers(buffer, internalInfHeader.TriggerOffset)
-	fmt.Printf("%d", len(*triggers))
+	if err != nil {
+		return nil, err
+	}
 
-	inf.ParseScripts(buffer, triggers, internalInfHeader.TriggerOffset)
+	// a broken script still leaves the level viewable, the undecodable rest is kept as raw bytes
+	script, err := inf.ParseScripts(buffer, triggers, internalInfHeader.TriggerOffset)
+	if err != nil {
+		log.Printf("Script of %s: %s", toString(internalInfHeader.MazeName), err)
+	}
 
 	result := InfHeader{
 		TriggersOffset:                     internalInfHeader.TriggerOffset,
@@ -143,6 +186,9 @@ func buildInfHeader(cps *CPS, err error) (*InfHeader, error) {
 		Monster2Name:                       toString(internalInfHeader.Monster2Name),
 		Monsters:                           internalInfHeader.Monsters,
 		WallMapping:                        *wallMap,
+		Triggers:                           *triggers,
+		Script:                             script,
+		RawData:                            *data,
 	}
 
 	return &result, nil
@@ -171,7 +217,6 @@ func loadDecoration(buffer *bytes.Reader, commands uint16, wallMap *map[int]Wall
 			currentCpsName = toString([12]byte(rawCpsName))
 			currentDatName = toString([12]byte(rawDatName))
 
-			fmt.Printf("0xEC: %s - %s\n", currentCpsName, currentDatName)
 		} else if command == 0xFB {
 			wallMappingIndex, _ := buffer.ReadByte()
 			wallType, _ := buffer.ReadByte()
@@ -179,7 +224,6 @@ func loadDecoration(buffer *bytes.Reader, commands uint16, wallMap *map[int]Wall
 			evantMask, _ := buffer.ReadByte()
 			flags, _ := buffer.ReadByte()
 
-			fmt.Printf("0xFB: %d %d %d %d %d\n", wallMappingIndex, wallType, decorationId, evantMask, flags)
 			wm := WallMapping{WallMappingIndex: int(wallMappingIndex), WallSetId: int(wallType), DecorationId: int(decorationId), EventMask: int(evantMask), Flags: int(flags), DatName: currentDatName, CpsName: currentCpsName}
 			(*wallMap)[int(wallMappingIndex)] = wm
 		}
@@ -203,6 +247,31 @@ func prefillWallMappings() *map[int]WallMapping {
 	return &wallMap
 }
 
+// EncodeWithScript returns the decompressed INF data with the script and the trigger table replaced and the trigger
+// offset of the header updated. The script has to start where the current one starts, the header and the
+// decoration commands before it and the data after the trigger table are kept.
+func (header *InfHeader) EncodeWithScript(script *inf.Script, triggers []inf.Trigger) ([]byte, error) {
+	if script.Offset != header.Script.Offset {
+		return nil, fmt.Errorf("script has to start at $%04x, not $%04x", header.Script.Offset, script.Offset)
+	}
+	code := script.Encode()
+	triggerOffset := script.Offset + len(code)
+	if triggerOffset > 0xffff {
+		return nil, fmt.Errorf("script too long, the trigger table would start at $%x", triggerOffset)
+	}
+
+	tableEnd := int(header.TriggersOffset) + 2 + 5*len(header.Triggers)
+	if tableEnd > len(header.RawData) {
+		tableEnd = len(header.RawData)
+	}
+
+	data := append([]byte(nil), header.RawData[:script.Offset]...)
+	binary.LittleEndian.PutUint16(data, uint16(triggerOffset))
+	data = append(data, code...)
+	data = append(data, inf.EncodeTriggers(triggers)...)
+	return append(data, header.RawData[tableEnd:]...), nil
+}
+
 func (inf *InfHeader) GetDecorationCPSNames() []string {
 	var set = make(map[string]bool)
 	for _, v := range inf.WallMapping {
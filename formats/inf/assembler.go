@@ -0,0 +1,647 @@
+package inf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var mnemonics = map[string]func() Instruction{
+	"SetWall":               func() Instruction { return &SetWall{} },
+	"ChangeWall":            func() Instruction { return &ChangeWall{} },
+	"OpenDoor":              func() Instruction { return &OpenDoor{} },
+	"CloseDoor":             func() Instruction { return &CloseDoor{} },
+	"CreateMonster":         func() Instruction { return &CreateMonster{} },
+	"Teleport":              func() Instruction { return &Teleport{} },
+	"StealSmallItems":       func() Instruction { return &StealSmallItems{} },
+	"Message":               func() Instruction { return &Message{} },
+	"SetFlag":               func() Instruction { return &SetFlag{} },
+	"Sound":                 func() Instruction { return &Sound{} },
+	"ClearFlag":             func() Instruction { return &ClearFlag{} },
+	"Heal":                  func() Instruction { return &Heal{} },
+	"Damage":                func() Instruction { return &Damage{} },
+	"Jump":                  func() Instruction { return &Jump{} },
+	"EndCode":               func() Instruction { return &EndCode{} },
+	"Return":                func() Instruction { return &Return{} },
+	"Call":                  func() Instruction { return &Call{} },
+	"Conditional":           func() Instruction { return &Conditional{} },
+	"ItemConsume":           func() Instruction { return &ItemConsume{} },
+	"ChangeLevel":           func() Instruction { return &ChangeLevel{} },
+	"GiveXP":                func() Instruction { return &GiveXP{} },
+	"NewItem":               func() Instruction { return &NewItem{} },
+	"Launcher":              func() Instruction { return &Launcher{} },
+	"Turn":                  func() Instruction { return &Turn{} },
+	"IdentAllItems":         func() Instruction { return &IdentAllItems{} },
+	"Encounters":            func() Instruction { return &Encounters{} },
+	"Wait":                  func() Instruction { return &Wait{} },
+	"UpdateScreen":          func() Instruction { return &UpdateScreen{} },
+	"TextMenu":              func() Instruction { return &TextMenu{} },
+	"SpecialWindowPictures": func() Instruction { return &SpecialWindowPictures{} },
+}
+
+var tokenNames = map[string]func() Token{
+	"monstersAt":     func() Token { return &CountMonstersAt{} },
+	"monstersOfType": func() Token { return &CountMonstersOfType{} },
+	"partyVisible":   func() Token { return &PartyVisible{} },
+	"rollDice":       func() Token { return &RollDice{} },
+	"race":           func() Token { return &PartyContains{Code: ExprRace} },
+	"alignment":      func() Token { return &PartyContains{Code: ExprAlignment} },
+	"class":          func() Token { return &PartyContains{Code: ExprClass} },
+	"triggerFlags":   func() Token { return &TriggerFlags{} },
+	"partyDirection": func() Token { return &PartyDirection{} },
+	"globalFlag":     func() Token { return &GlobalFlag{} },
+	"mazeFlag":       func() Token { return &MazeFlag{} },
+	"pointerItem":    func() Token { return &PointerItem{} },
+	"wallSide":       func() Token { return &WallSide{} },
+	"inventoryCount": func() Token { return &PartyInventoryCount{} },
+	"partyAt":        func() Token { return &PartyAt{} },
+	"itemsAt":        func() Token { return &ItemsAt{} },
+	"wallNumber":     func() Token { return &WallNumber{} },
+}
+
+// fixup is an address operand referring to a label, resolved once all instructions are placed.
+type fixup struct {
+	target *int
+	label  string
+	line   int
+}
+
+type assembler struct {
+	offset       int
+	originSet    bool
+	instructions []Instruction
+	triggers     []Trigger
+	triggerRefs  []fixup
+	labels       map[string]int
+	pending      []string
+	fixups       []fixup
+	line         int
+}
+
+// Assemble compiles a script in the syntax of WriteText. It returns the script, placed at the address of the
+// .org directive, and the triggers of the .trigger directives with their addresses resolved. Labels of the form
+// _0xNNNN that are not defined refer to the address NNNN.
+func Assemble(source io.Reader) (*Script, []Trigger, error) {
+	a := &assembler{labels: make(map[string]int)}
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		a.line++
+		if err := a.parseLine(scanner.Text()); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", a.line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	for _, label := range a.pending {
+		a.labels[label] = a.offset
+	}
+
+	for _, f := range a.fixups {
+		address, err := a.resolve(f.label)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", f.line, err)
+		}
+		*f.target = address
+	}
+
+	triggers := make([]Trigger, len(a.triggers))
+	for i, trigger := range a.triggers {
+		address, err := a.resolve(a.triggerRefs[i].label)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", a.triggerRefs[i].line, err)
+		}
+		triggers[i] = *NewTrigger(trigger.Pos, trigger.Flags, address)
+		triggers[i].Index = trigger.Index
+	}
+	sort.Slice(triggers, func(i, j int) bool { return triggers[i].Index < triggers[j].Index })
+	for i, trigger := range triggers {
+		if trigger.Index != i {
+			return nil, nil, fmt.Errorf("trigger indices must be 0 to %d without gaps, found %d", len(triggers)-1, trigger.Index)
+		}
+	}
+
+	start := a.offset
+	if len(a.instructions) > 0 {
+		start = a.instructions[0].GetOffset()
+	}
+	return NewScript(start, a.offset, a.instructions, triggers), triggers, nil
+}
+
+func (a *assembler) resolve(label string) (int, error) {
+	if address, ok := a.labels[label]; ok {
+		return address, nil
+	}
+	if strings.HasPrefix(label, "_0x") {
+		if address, err := strconv.ParseUint(label[3:], 16, 16); err == nil {
+			return int(address), nil
+		}
+	}
+	if address, err := parseNumber(label); err == nil {
+		return address, nil
+	}
+	return 0, fmt.Errorf("undefined label %s", label)
+}
+
+func (a *assembler) parseLine(line string) error {
+	line = strings.TrimSpace(stripComment(line))
+	for {
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 || !isIdentifier(line[:colon]) {
+			break
+		}
+		label := line[:colon]
+		if _, ok := a.labels[label]; ok {
+			return fmt.Errorf("label %s defined twice", label)
+		}
+		for _, pending := range a.pending {
+			if pending == label {
+				return fmt.Errorf("label %s defined twice", label)
+			}
+		}
+		a.pending = append(a.pending, label)
+		line = strings.TrimSpace(line[colon+1:])
+	}
+	if line == "" {
+		return nil
+	}
+
+	fields, err := splitFields(line)
+	if err != nil {
+		return err
+	}
+	switch fields[0] {
+	case ".org":
+		return a.parseOrigin(fields[1:])
+	case ".trigger":
+		return a.parseTrigger(strings.TrimSpace(line[len(".trigger"):]))
+	case ".byte":
+		data, err := parseByteList(strings.TrimSpace(line[len(".byte"):]))
+		if err != nil {
+			return err
+		}
+		return a.place(&RawBytes{Bytes: data})
+	}
+
+	create, ok := mnemonics[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown instruction %s", fields[0])
+	}
+	instruction := create()
+	if err := a.setOperands(instruction, fields[1:]); err != nil {
+		return fmt.Errorf("%s: %w", fields[0], err)
+	}
+	return a.place(instruction)
+}
+
+// place puts an instruction at the current address and defines the labels preceding it.
+func (a *assembler) place(instruction Instruction) error {
+	if !a.originSet {
+		return fmt.Errorf("missing .org before the first instruction")
+	}
+	for _, label := range a.pending {
+		a.labels[label] = a.offset
+	}
+	a.pending = a.pending[:0]
+
+	instruction.(interface{ setOffset(int) }).setOffset(a.offset)
+	a.instructions = append(a.instructions, instruction)
+	a.offset += len(instruction.Encode())
+	if a.offset > 0xffff {
+		return fmt.Errorf("script exceeds 64KB")
+	}
+	return nil
+}
+
+func (a *assembler) parseOrigin(fields []string) error {
+	if a.originSet {
+		return fmt.Errorf(".org can only be used once")
+	}
+	if len(fields) != 1 {
+		return fmt.Errorf(".org needs an address")
+	}
+	address, err := parseNumber(fields[0])
+	if err != nil {
+		return err
+	}
+	a.offset = address
+	a.originSet = true
+	return nil
+}
+
+// parseTrigger reads `.trigger index, [x,y], $flags, label`.
+func (a *assembler) parseTrigger(arguments string) error {
+	parts, err := splitList(arguments)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 4 {
+		return fmt.Errorf(".trigger needs index, position, flags and address")
+	}
+	index, err := parseNumber(parts[0])
+	if err != nil {
+		return err
+	}
+	pos, err := parsePosition(parts[1])
+	if err != nil {
+		return err
+	}
+	flags, err := parseNumber(parts[2])
+	if err != nil {
+		return err
+	}
+	for _, trigger := range a.triggers {
+		if trigger.Index == index {
+			return fmt.Errorf("trigger %d defined twice", index)
+		}
+	}
+
+	if flags > 0xff {
+		return fmt.Errorf("trigger flags %s out of range", parts[2])
+	}
+	a.triggers = append(a.triggers, Trigger{Index: index, Pos: pos, Flags: flags})
+	a.triggerRefs = append(a.triggerRefs, fixup{label: parts[3], line: a.line})
+	return nil
+}
+
+// setOperands assigns the key=value fields to the operands. The operands depend on the sub type, so they are
+// assigned again until no field is left over.
+func (a *assembler) setOperands(instruction Instruction, fields []string) error {
+	values := make(map[string]string)
+	for _, field := range fields {
+		name, value := "", field
+		if !strings.HasPrefix(field, "{") {
+			var ok bool
+			if name, value, ok = strings.Cut(field, "="); !ok {
+				return fmt.Errorf("operand %s is not name=value", field)
+			}
+		}
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("operand %s given twice", name)
+		}
+		values[name] = value
+	}
+
+	assigned := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, op := range instructionOperands(instruction) {
+			value, ok := values[op.name]
+			if !ok || assigned[op.name] {
+				continue
+			}
+			if err := a.setOperand(op, value); err != nil {
+				return fmt.Errorf("%s: %w", operandName(op), err)
+			}
+			assigned[op.name] = true
+			changed = true
+			break
+		}
+	}
+
+	for _, op := range instructionOperands(instruction) {
+		if !assigned[op.name] {
+			return fmt.Errorf("missing operand %s", operandName(op))
+		}
+	}
+	for name := range values {
+		if !assigned[name] {
+			return fmt.Errorf("unexpected operand %s", name)
+		}
+	}
+	return nil
+}
+
+func operandName(op operand) string {
+	if op.name == "" {
+		return "expression"
+	}
+	return op.name
+}
+
+// setOperand reads a value, addresses are resolved once all labels are known.
+func (a *assembler) setOperand(op operand, value string) error {
+	if target, ok := op.value.(*int); ok && op.address {
+		a.fixups = append(a.fixups, fixup{target: target, label: value, line: a.line})
+		return nil
+	}
+	return parseOperand(op, value)
+}
+
+func parseOperand(op operand, value string) error {
+	switch v := op.value.(type) {
+	case *int:
+		number, err := parseSymbol(value, op.symbols, 0xffff)
+		*v = number
+		return err
+	case *byte:
+		number, err := parseSymbol(value, op.symbols, 0xff)
+		*v = byte(number)
+		return err
+	case *Position:
+		position, err := parsePosition(value)
+		*v = position
+		return err
+	case *string:
+		text, err := strconv.Unquote(value)
+		if err != nil {
+			return fmt.Errorf("invalid string %s", value)
+		}
+		if strings.IndexByte(text, 0) >= 0 {
+			return fmt.Errorf("strings cannot contain \\x00")
+		}
+		*v = text
+		return nil
+	case *[]Token:
+		expression, err := parseExpression(value)
+		*v = expression
+		return err
+	case *[]MonsterTypeValue:
+		pairs, err := parseMonsterTypes(value)
+		*v = pairs
+		return err
+	}
+	return fmt.Errorf("unsupported operand")
+}
+
+func parseExpression(value string) ([]Token, error) {
+	if !strings.HasPrefix(value, "{") || !strings.HasSuffix(value, "}") {
+		return nil, fmt.Errorf("expression must be in braces")
+	}
+	fields, err := splitFields(value[1 : len(value)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	var expression []Token
+	for _, field := range fields {
+		token, err := parseExpressionToken(field)
+		if err != nil {
+			return nil, err
+		}
+		expression = append(expression, token)
+	}
+	return expression, nil
+}
+
+func parseExpressionToken(field string) (Token, error) {
+	for code, symbol := range operatorSymbols {
+		if field == symbol {
+			return &Operator{Code: code}, nil
+		}
+	}
+	if value, err := parseNumber(field); err == nil {
+		if value > 0xff || isTokenCode(byte(value)) {
+			return nil, fmt.Errorf("constant %s out of range", field)
+		}
+		return &Constant{Value: byte(value)}, nil
+	}
+
+	name, arguments := field, ""
+	if open := strings.IndexByte(field, '('); open >= 0 {
+		if !strings.HasSuffix(field, ")") {
+			return nil, fmt.Errorf("missing ) in %s", field)
+		}
+		name, arguments = field[:open], field[open+1:len(field)-1]
+	}
+	create, ok := tokenNames[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown expression %s", field)
+	}
+	token := create()
+
+	var values []string
+	if _, ok := token.(*CountMonstersOfType); ok {
+		// the operand is a list itself, eg. monstersOfType(3:1,4:2)
+		values = []string{arguments}
+	} else if arguments != "" {
+		var err error
+		if values, err = splitList(arguments); err != nil {
+			return nil, err
+		}
+	}
+
+	// the operands depend on the first one, eg. pointerItem(identName,9)
+	for i := 0; ; i++ {
+		_, operands := tokenOperands(token)
+		if i >= len(operands) {
+			if i != len(values) {
+				return nil, fmt.Errorf("%s expects %d arguments", name, len(operands))
+			}
+			return token, nil
+		}
+		if i >= len(values) {
+			return nil, fmt.Errorf("%s expects %d arguments", name, len(operands))
+		}
+		if err := parseOperand(operands[i], values[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+}
+
+func parseMonsterTypes(value string) ([]MonsterTypeValue, error) {
+	var pairs []MonsterTypeValue
+	parts, err := splitList(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		typeText, valueText, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("monster type %s is not type:value", part)
+		}
+		t, err := parseNumber(typeText)
+		if err != nil || t == 0 || t > 0xff || t == int(MonstersAtBlock) {
+			return nil, fmt.Errorf("invalid monster type %s", typeText)
+		}
+		v, err := parseNumber(valueText)
+		if err != nil || v > 0xff {
+			return nil, fmt.Errorf("invalid value %s", valueText)
+		}
+		pairs = append(pairs, MonsterTypeValue{Type: t, Value: v})
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("monstersOfType needs at least one type")
+	}
+	return pairs, nil
+}
+
+func parseSymbol(value string, symbols map[int]string, max int) (int, error) {
+	for number, name := range symbols {
+		if name == value {
+			return number, nil
+		}
+	}
+	number, err := parseNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	if number > max {
+		return 0, fmt.Errorf("%s out of range", value)
+	}
+	return number, nil
+}
+
+// parseNumber reads a decimal, $hex or 0xhex number of at most 16 bits.
+func parseNumber(value string) (int, error) {
+	var number uint64
+	var err error
+	switch {
+	case strings.HasPrefix(value, "$"):
+		number, err = strconv.ParseUint(value[1:], 16, 16)
+	case strings.HasPrefix(value, "0x"):
+		number, err = strconv.ParseUint(value[2:], 16, 16)
+	default:
+		number, err = strconv.ParseUint(value, 10, 16)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", value)
+	}
+	return int(number), nil
+}
+
+func parsePosition(value string) (Position, error) {
+	if value == "none" {
+		return NewPositionFromWord(PositionNone), nil
+	}
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return Position{}, fmt.Errorf("position %s is not [x,y]", value)
+	}
+	xText, yText, ok := strings.Cut(value[1:len(value)-1], ",")
+	if !ok {
+		return Position{}, fmt.Errorf("position %s is not [x,y]", value)
+	}
+	x, err := parseNumber(strings.TrimSpace(xText))
+	if err != nil || x > 31 {
+		return Position{}, fmt.Errorf("invalid x in %s", value)
+	}
+	y, err := parseNumber(strings.TrimSpace(yText))
+	if err != nil || y > 0x7ff {
+		return Position{}, fmt.Errorf("invalid y in %s", value)
+	}
+	return Position{X: x, Y: y}, nil
+}
+
+func parseByteList(value string) ([]byte, error) {
+	parts, err := splitList(value)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, len(parts))
+	for _, part := range parts {
+		b, err := parseNumber(part)
+		if err != nil || b > 0xff {
+			return nil, fmt.Errorf("invalid byte %s", part)
+		}
+		data = append(data, byte(b))
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf(".byte needs at least one value")
+	}
+	return data, nil
+}
+
+// stripComment removes everything after a ; outside of strings.
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case ';':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// splitFields splits at spaces outside of strings, brackets, parentheses and braces.
+func splitFields(line string) ([]string, error) {
+	return split(line, func(c byte) bool { return c == ' ' || c == '\t' })
+}
+
+// splitList splits a comma separated list, the items are trimmed.
+func splitList(line string) ([]string, error) {
+	parts, err := split(line, func(c byte) bool { return c == ',' })
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts, err
+}
+
+func split(line string, separator func(c byte) bool) ([]string, error) {
+	var parts []string
+	var depth []byte
+	inString := false
+	start := 0
+	closing := map[byte]byte{'[': ']', '(': ')', '{': '}'}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case closing[c] != 0:
+			depth = append(depth, closing[c])
+		case c == ']' || c == ')' || c == '}':
+			if len(depth) == 0 || depth[len(depth)-1] != c {
+				return nil, fmt.Errorf("unbalanced %c", c)
+			}
+			depth = depth[:len(depth)-1]
+		case len(depth) == 0 && separator(c):
+			if part := line[start:i]; strings.TrimSpace(part) != "" {
+				parts = append(parts, part)
+			}
+			start = i + 1
+		}
+	}
+	if inString {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	if len(depth) > 0 {
+		return nil, fmt.Errorf("missing %c", depth[len(depth)-1])
+	}
+	if part := line[start:]; strings.TrimSpace(part) != "" {
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// EncodeTriggers returns the trigger table: the number of triggers followed by position, flags and address of
+// every trigger in the order of Index.
+func EncodeTriggers(triggers []Trigger) []byte {
+	data := binary.LittleEndian.AppendUint16(nil, uint16(len(triggers)))
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		data = appendPosition(data, trigger.Pos)
+		data = append(data, byte(trigger.Flags))
+		data = appendWord(data, uint16(trigger.Address))
+	}
+	return data
+}
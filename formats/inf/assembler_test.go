@@ -0,0 +1,201 @@
+package inf
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testOffset is where the test scripts start, jumps, calls and conditionals target it.
+const testOffset = 0x0100
+
+// pos is the position word of [5,3].
+var pos = []byte{0x65, 0x00}
+
+func code(parts ...any) []byte {
+	var data []byte
+	for _, part := range parts {
+		switch p := part.(type) {
+		case byte:
+			data = append(data, p)
+		case int:
+			data = append(data, byte(p))
+		case []byte:
+			data = append(data, p...)
+		case string:
+			data = append(data, p...)
+		}
+	}
+	return data
+}
+
+var instructionTests = []struct {
+	name string
+	code []byte
+}{
+	{"SetWall all sides", code(OpSetWall, WallAllSides, pos, 3)},
+	{"SetWall one side", code(OpSetWall, WallOneSide, pos, 2, 3)},
+	{"SetWall party direction", code(OpSetWall, WallPartyDirection, 2)},
+	{"ChangeWall all sides", code(OpChangeWall, WallAllSides, pos, 3, 4)},
+	{"ChangeWall one side", code(OpChangeWall, WallOneSide, pos, 1, 3, 4)},
+	{"ChangeWall door", code(OpChangeWall, WallOpenDoor, pos)},
+	{"OpenDoor", code(OpOpenDoor, pos)},
+	{"CloseDoor", code(OpCloseDoor, pos)},
+	{"CreateMonster", code(OpCreateMonster, 1, 2, pos, 3, 1, 4, 5, 6, 7, 8, 0, 9, 0)},
+	{"Teleport party", code(OpTeleport, TargetParty, 0, 0, pos)},
+	{"Teleport monster", code(OpTeleport, TargetMonster, 0x66, 0, pos)},
+	{"Teleport item", code(OpTeleport, TargetItem, 0x66, 0, pos)},
+	{"StealSmallItems random member", code(OpStealSmallItems, StealRandom, pos, 2)},
+	{"StealSmallItems member", code(OpStealSmallItems, 2, pos, 2)},
+	{"Message", code(OpMessage, "Hi there", 0, 15, 0)},
+	{"Message code page", code(OpMessage, "\x81\xe1\r", 0, 1, 0)},
+	{"SetFlag maze", code(OpSetFlag, FlagMaze, 3)},
+	{"SetFlag global", code(OpSetFlag, FlagGlobal, 5)},
+	{"SetFlag monster", code(OpSetFlag, FlagMonster, 2, 1)},
+	{"SetFlag event", code(OpSetFlag, FlagEvent)},
+	{"SetFlag save rest", code(OpSetFlag, FlagSaveRest)},
+	{"ClearFlag maze", code(OpClearFlag, FlagMaze, 3)},
+	{"ClearFlag global", code(OpClearFlag, FlagGlobal, 5)},
+	{"ClearFlag monster", code(OpClearFlag, FlagMonster, 2, 1)},
+	{"ClearFlag event", code(OpClearFlag, FlagEvent)},
+	{"ClearFlag save rest", code(OpClearFlag, FlagSaveRest)},
+	{"Sound", code(OpSound, 10, pos)},
+	{"Heal", code(OpHeal)},
+	{"Damage all", code(OpDamage, DamageAll, 2, 6, 1)},
+	{"Damage member", code(OpDamage, 1, 1, 4, 0)},
+	{"Jump", code(OpJump, 0x00, 0x01)},
+	{"EndCode", code(OpEndCode)},
+	{"Return", code(OpReturn)},
+	{"Call", code(OpCall, 0x00, 0x01)},
+	{"ItemConsume at pointer", code(OpItemConsume, ConsumeAtPointer)},
+	{"ItemConsume any at block", code(OpItemConsume, ConsumeAnyAtBlock, pos)},
+	{"ItemConsume type at block", code(OpItemConsume, 7, pos)},
+	{"ChangeLevel to another level", code(OpChangeLevel, LevelChange, 2, pos, 1)},
+	{"ChangeLevel within the level", code(OpChangeLevel, 0, 3, pos)},
+	{"GiveXP party", code(OpGiveXP, ExperienceParty, 0xe8, 0x03)},
+	{"GiveXP other", code(OpGiveXP, 1)},
+	{"NewItem at block", code(OpNewItem, 0x34, 0, pos, 2)},
+	{"NewItem at pointer", code(OpNewItem, 0x34, 0, 0xff, 0xff, 0)},
+	{"Launcher spell", code(OpLauncher, LauncherSpell, 5, 0, pos, 2, 1)},
+	{"Launcher item", code(OpLauncher, 0, 0x20, 0, pos, 3, 0)},
+	{"Turn party", code(OpTurn, TurnParty, 1)},
+	{"Turn item", code(OpTurn, TurnItem, 2)},
+	{"IdentAllItems", code(OpIdentAllItems, pos)},
+	{"Encounters", code(OpEncounters, 3)},
+	{"Wait", code(OpWait, 0x10, 0)},
+	{"UpdateScreen", code(OpUpdateScreen)},
+	{"TextMenu", code(OpTextMenu)},
+	{"SpecialWindowPictures", code(OpSpecialWindowPictures)},
+}
+
+var tokenTests = []struct {
+	name string
+	code []byte
+}{
+	{"constant", code(5)},
+	{"equal", code(1, 2, ExprEqual)},
+	{"not equal", code(1, 2, ExprNotEqual)},
+	{"less", code(1, 2, ExprLess)},
+	{"less or equal", code(1, 2, ExprLessOrEqual)},
+	{"greater", code(1, 2, ExprGreater)},
+	{"greater or equal", code(1, 2, ExprGreaterOrEqual)},
+	{"and", code(1, 2, ExprAnd)},
+	{"or", code(1, 2, ExprOr)},
+	{"monsters at block", code(ExprMonsters, MonstersAtBlock, pos)},
+	{"monsters of type", code(ExprMonsters, 2, 3, 4, 5, 0)},
+	{"party visible", code(ExprPartyVisible)},
+	{"roll dice", code(ExprRollDice, 1, 6, 2)},
+	{"race", code(ExprRace, 3)},
+	{"alignment", code(ExprAlignment, 4)},
+	{"class", code(ExprClass, 5)},
+	{"trigger flags", code(ExprTriggerFlags)},
+	{"party direction", code(ExprPartyDirection)},
+	{"global flag", code(ExprGlobalFlag, 3)},
+	{"pointer item type", code(ExprPointerItem, PointerItemType)},
+	{"pointer item any", code(ExprPointerItem, PointerItemAny)},
+	{"pointer item value", code(ExprPointerItem, PointerItemValue)},
+	{"pointer item unidentified name", code(ExprPointerItem, PointerItemUnidentName, 7)},
+	{"pointer item identified name", code(ExprPointerItem, PointerItemIdentName, 7)},
+	{"wall side", code(ExprWallSide, 2, pos)},
+	{"party inventory count", code(ExprParty, PartyItemCount, 0x34, 0x12, 1)},
+	{"party at", code(ExprParty, pos)},
+	{"items at of any type", code(ExprItemsAt, AnyItemType, pos)},
+	{"items at of a type", code(ExprItemsAt, 7, pos)},
+	{"wall number", code(ExprWallNumber, pos)},
+	{"maze flag", code(ExprMazeFlag, 4)},
+}
+
+// decode parses bytecode placed at testOffset with a trigger starting it.
+func decode(t *testing.T, bytecode []byte) (*Script, []Trigger) {
+	t.Helper()
+	data := append(make([]byte, testOffset), bytecode...)
+	buffer := bytes.NewReader(data)
+	buffer.Seek(testOffset, io.SeekStart)
+	triggers := []Trigger{*NewTrigger(Position{X: 5, Y: 3}, 0x08, testOffset)}
+	script, err := ParseScripts(buffer, &triggers, uint16(len(data)))
+	if err != nil {
+		t.Fatalf("cannot decode % x: %s", bytecode, err)
+	}
+	return script, triggers
+}
+
+// reassemble disassembles a script and assembles the text again.
+func reassemble(t *testing.T, script *Script, triggers []Trigger) (*Script, []Trigger) {
+	t.Helper()
+	var source strings.Builder
+	if err := WriteText(&source, script, triggers); err != nil {
+		t.Fatal(err)
+	}
+	assembled, assembledTriggers, err := Assemble(strings.NewReader(source.String()))
+	if err != nil {
+		t.Fatalf("cannot assemble\n%s: %s", source.String(), err)
+	}
+	return assembled, assembledTriggers
+}
+
+func checkRoundTrip(t *testing.T, bytecode []byte) {
+	t.Helper()
+	script, triggers := decode(t, bytecode)
+	if encoded := script.Encode(); !bytes.Equal(encoded, bytecode) {
+		t.Fatalf("decoding and encoding gives % x instead of % x", encoded, bytecode)
+	}
+
+	assembled, assembledTriggers := reassemble(t, script, triggers)
+	if assembled.Offset != testOffset {
+		t.Errorf("reassembled script starts at $%04x", assembled.Offset)
+	}
+	if encoded := assembled.Encode(); !bytes.Equal(encoded, bytecode) {
+		t.Errorf("reassembling gives % x instead of % x", encoded, bytecode)
+	}
+	if len(assembledTriggers) != 1 || assembledTriggers[0] != triggers[0] {
+		t.Errorf("reassembled triggers %v instead of %v", assembledTriggers, triggers)
+	}
+}
+
+func TestInstructionRoundTrip(t *testing.T) {
+	for _, test := range instructionTests {
+		t.Run(test.name, func(t *testing.T) {
+			checkRoundTrip(t, test.code)
+		})
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	for _, test := range tokenTests {
+		t.Run(test.name, func(t *testing.T) {
+			checkRoundTrip(t, code(OpConditional, test.code, OpConditional, 0x00, 0x01))
+		})
+	}
+}
+
+func TestScriptRoundTrip(t *testing.T) {
+	var bytecode []byte
+	for _, test := range instructionTests {
+		bytecode = append(bytecode, test.code...)
+	}
+	for _, test := range tokenTests {
+		bytecode = append(bytecode, code(OpConditional, test.code, OpConditional, 0x00, 0x01)...)
+	}
+	checkRoundTrip(t, bytecode)
+}
@@ -0,0 +1,177 @@
+package inf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OpFallDown is the opcode of the C64 port replacing a ChangeLevel into the block below, a hole in the floor.
+const OpFallDown byte = 0xe4
+
+// WriteCA65 writes the script as ca65 source of the bytecode of the C64 port, level is the number of the level
+// the script belongs to. Jumps and the false targets of conditionals become relative forward branches of one byte
+// checked by .assert, calls keep absolute addresses. Conditionals start with the length of their expression
+// instead of an opcode if it is shorter than 128 bytes, constant conditions are folded. The trigger table follows
+// the script in the layout of the INF file. Constructs the port cannot express, like backward branches, are
+// returned as an error and nothing is written.
+func WriteCA65(w io.Writer, script *Script, triggers []Trigger, level int) error {
+	c := &ca65Converter{script: script, level: level, reaching: reachingTriggers(script, triggers)}
+	for _, instruction := range script.Instructions {
+		c.convert(instruction)
+	}
+	if len(c.errs) > 0 {
+		return fmt.Errorf("level %d cannot be converted to the C64 bytecode:\n%w", level, errors.Join(c.errs...))
+	}
+
+	ew := &errWriter{w: w}
+	ew.printf("; Level %d scripts in the C64 bytecode, assemble with ca65\n", level)
+	for _, line := range c.lines {
+		ew.printf("%s\n", line)
+	}
+	ew.printf("\n%s: ; trigger table: count, then position, flags and address of every trigger\n", Label(script.End))
+	ew.printf(".word %d\n", len(triggers))
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		ew.printf(".word $%04x\n.byte $%02x\n.word %s ; trigger %d at [%d,%d] on %s\n",
+			trigger.Pos.Y*32+trigger.Pos.X, trigger.Flags, Label(trigger.Address), trigger.Index, trigger.Pos.X, trigger.Pos.Y, trigger.Events())
+	}
+	return ew.err
+}
+
+type ca65Converter struct {
+	script   *Script
+	level    int
+	reaching map[int][]Trigger
+	lines    []string
+	errs     []error
+}
+
+// reachingTriggers returns the triggers reaching every instruction address.
+func reachingTriggers(script *Script, triggers []Trigger) map[int][]Trigger {
+	graph := BuildGraph(script, triggers)
+	reaching := map[int][]Trigger{}
+	for _, trigger := range triggers {
+		for _, block := range graph.reachableFrom([]int{trigger.Address}) {
+			for _, instruction := range block.Instructions {
+				reaching[instruction.GetOffset()] = append(reaching[instruction.GetOffset()], trigger)
+			}
+		}
+	}
+	return reaching
+}
+
+func (c *ca65Converter) emit(format string, args ...any) {
+	c.lines = append(c.lines, fmt.Sprintf(format, args...))
+}
+
+func (c *ca65Converter) fail(instruction Instruction, format string, args ...any) {
+	c.errs = append(c.errs, fmt.Errorf("%s: %s", Label(instruction.GetOffset()), fmt.Sprintf(format, args...)))
+}
+
+func (c *ca65Converter) convert(instruction Instruction) {
+	c.emit("%s: ; %s", Label(instruction.GetOffset()), FormatInstruction(instruction))
+	switch i := instruction.(type) {
+	case *Jump:
+		c.branch(instruction, i.Target, "$f2, ")
+	case *Call:
+		if c.checkTarget(instruction, i.Target) {
+			c.emit(".byte $%02x,<%s,>%s", OpCall, Label(i.Target), Label(i.Target))
+		}
+	case *Conditional:
+		c.conditional(i)
+	case *ChangeLevel:
+		if c.fallsDown(i) {
+			c.emit(".byte $%02x ; falling down", OpFallDown)
+			return
+		}
+		c.emit("%s", formatBytes(i.Encode()))
+	case *RawBytes:
+		c.fail(instruction, "undecoded bytes %s", formatBytes(i.Bytes))
+	default:
+		c.emit("%s", formatBytes(instruction.Encode()))
+	}
+}
+
+func (c *ca65Converter) conditional(conditional *Conditional) {
+	if value, ok := constantValue(conditional.Expression); ok {
+		if value != 0 {
+			c.emit("; always true")
+			return
+		}
+		c.emit("; always false")
+		c.branch(conditional, conditional.FalseTarget, "$f2, ")
+		return
+	}
+
+	var expression []byte
+	for _, token := range conditional.Expression {
+		expression = append(expression, token.Encode()...)
+	}
+	// the length byte counts itself and the expression, the branch offset follows
+	if len(expression)+1 < 128 {
+		c.emit("%s", formatBytes(append([]byte{byte(len(expression) + 1)}, expression...)))
+	} else {
+		c.emit("%s", formatBytes(append(append([]byte{OpConditional}, expression...), OpConditional)))
+	}
+	c.branch(conditional, conditional.FalseTarget, "")
+}
+
+// branch emits a relative forward branch to target after a prefix of bytes.
+func (c *ca65Converter) branch(instruction Instruction, target int, prefix string) {
+	if !c.checkTarget(instruction, target) {
+		return
+	}
+	if target <= instruction.GetOffset() {
+		c.fail(instruction, "backward branch to %s", Label(target))
+		return
+	}
+	c.emit(".assert %s - * <= 255, error, \"Illegal branch\"", Label(target))
+	c.emit(".byte %s<(%s - *)", prefix, Label(target))
+}
+
+// checkTarget reports whether the target is an instruction or the end of the script.
+func (c *ca65Converter) checkTarget(instruction Instruction, target int) bool {
+	if _, ok := c.script.IndexOf(target); !ok && target != c.script.End {
+		c.fail(instruction, "%s is not the address of an instruction", Label(target))
+		return false
+	}
+	return true
+}
+
+// fallsDown reports whether a ChangeLevel moves the party into the block below the triggers reaching it, keeping
+// its direction.
+func (c *ca65Converter) fallsDown(changeLevel *ChangeLevel) bool {
+	if changeLevel.Type != LevelChange || changeLevel.Level != c.level+1 || changeLevel.Direction != 255 {
+		return false
+	}
+	reaching := c.reaching[changeLevel.GetOffset()]
+	for _, trigger := range reaching {
+		if trigger.Pos != changeLevel.Pos {
+			return false
+		}
+	}
+	return len(reaching) > 0
+}
+
+// constantValue evaluates an expression of constants and operators.
+func constantValue(expression []Token) (int, bool) {
+	var stack []int
+	for _, token := range expression {
+		switch t := token.(type) {
+		case *Constant:
+			stack = append(stack, int(t.Value))
+		case *Operator:
+			if len(stack) < 2 {
+				return 0, false
+			}
+			a, b := stack[len(stack)-1], stack[len(stack)-2]
+			stack = append(stack[:len(stack)-2], t.Apply(a, b))
+		default:
+			return 0, false
+		}
+	}
+	if len(stack) != 1 {
+		return 0, false
+	}
+	return stack[0], true
+}
@@ -0,0 +1,124 @@
+package inf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// longCondition returns a condition of a flag and the given number of "1 &&", 2 bytes each.
+func longCondition(ands int) string {
+	return "mazeFlag(1)" + strings.Repeat(" 1 &&", ands)
+}
+
+func writeTestCA65(t *testing.T, source string) (string, error) {
+	t.Helper()
+	script, triggers, err := Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	err = WriteCA65(output, script, triggers, 1)
+	return output.String(), err
+}
+
+func TestWriteCA65(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"short conditional", `_start: Conditional { mazeFlag(1) } falseTarget=_end
+Message text="set" color=1
+_end: EndCode`, []string{".byte $03,$ef,$01\n", ".byte <(_0x001d - *)"}},
+		{"long conditional", `_start: Conditional { ` + longCondition(63) + ` } falseTarget=_end
+_end: EndCode`, []string{".byte $ee,$ef,$01,$01,$f9,", ",$01,$f9,$ee\n", ".byte <(_0x0094 - *)"}},
+		{"longest short conditional", `_start: Conditional { ` + longCondition(62) + ` } falseTarget=_end
+_end: EndCode`, []string{".byte $7f,$ef,$01,$01,$f9,", ",$01,$f9\n"}},
+		{"always true", `_start: Conditional { 1 } falseTarget=_end
+Message text="set" color=1
+_end: EndCode`, []string{"; always true\n_0x0015: ; Message"}},
+		{"always false", `_start: Conditional { 0 2 == } falseTarget=_end
+Message text="set" color=1
+_end: EndCode`, []string{"; always false\n.assert _0x001e - * <= 255", ".byte $f2, <(_0x001e - *)"}},
+		{"forward jump", `_start: Jump target=_end
+Message text="skipped" color=1
+_end: EndCode`, []string{".byte $f2, <(_0x001e - *)"}},
+		{"fall down", `_start: ChangeLevel type=level level=2 pos=[5,5] direction=255
+EndCode`, []string{".byte $e4 ; falling down"}},
+		{"level change elsewhere", `_start: ChangeLevel type=level level=2 pos=[6,5] direction=255
+EndCode`, []string{".byte $ec,$e5,$02,$a6,$00,$ff"}},
+		{"level change turning", `_start: ChangeLevel type=level level=2 pos=[5,5] direction=1
+EndCode`, []string{".byte $ec,$e5,$02,$a5,$00,$01"}},
+		{"level change skipping a level", `_start: ChangeLevel type=level level=3 pos=[5,5] direction=255
+EndCode`, []string{".byte $ec,$e5,$03,$a5,$00,$ff"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := writeTestCA65(t, ".trigger 0, [5,5], $08, _start\n.org $0010\n"+test.source+"\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("%q missing in\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCA65FallDownReachedFromElsewhere(t *testing.T) {
+	output, err := writeTestCA65(t, `.trigger 0, [5,5], $08, _start
+.trigger 1, [6,6], $08, _other
+.org $0010
+_start: ChangeLevel type=level level=2 pos=[5,5] direction=255
+EndCode
+_other: Jump target=_start
+`)
+	if err == nil {
+		t.Fatalf("backward jump converted:\n%s", output)
+	}
+
+	output, err = writeTestCA65(t, `.trigger 0, [5,5], $08, _start
+.trigger 1, [6,6], $08, _other
+.org $0010
+_other: Jump target=_start
+_start: ChangeLevel type=level level=2 pos=[5,5] direction=255
+EndCode
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(output, "falling down") {
+		t.Errorf("level change reached from [6,6] converted to falling down:\n%s", output)
+	}
+}
+
+func TestWriteCA65Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"backward jump", `_start: Message text="loop" color=1
+Jump target=_start`, "backward branch to _0x0010"},
+		{"jump to itself", `_start: Jump target=_start`, "backward branch to _0x0010"},
+		{"backward conditional", `_start: Message text="loop" color=1
+Conditional { mazeFlag(1) } falseTarget=_start
+EndCode`, "backward branch to _0x0010"},
+		{"raw bytes", `_start: EndCode
+.byte $ff,$fe`, "undecoded bytes .byte $ff,$fe"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := writeTestCA65(t, ".trigger 0, [5,5], $08, _start\n.org $0010\n"+test.source+"\n")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error %v instead of %q", err, test.want)
+			}
+			if output != "" {
+				t.Errorf("written despite the error:\n%s", output)
+			}
+		})
+	}
+}
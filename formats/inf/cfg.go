@@ -0,0 +1,384 @@
+package inf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// EdgeKind is the way control passes from a basic block to another.
+type EdgeKind int
+
+const (
+	EdgeFallThrough EdgeKind = iota
+	EdgeJump
+	EdgeTrue
+	EdgeFalse
+	EdgeCall
+	EdgeReturn
+)
+
+var edgeKindNames = [...]string{"fallThrough", "jump", "true", "false", "call", "return"}
+
+func (k EdgeKind) String() string {
+	return edgeKindNames[k]
+}
+
+// Edge leads to the basic block starting at Target.
+type Edge struct {
+	Kind   EdgeKind
+	Target int
+}
+
+// BasicBlock is a run of instructions entered at the first and left after the last one. Roots are the triggers
+// starting at the block. FallsIntoTable is set if an edge leads to the end of the script, where the trigger
+// table would be executed as code.
+type BasicBlock struct {
+	Start          int
+	End            int
+	Instructions   []Instruction
+	Edges          []Edge
+	Roots          []Trigger
+	Reachable      bool
+	FallsIntoTable bool
+}
+
+// Graph is the control-flow graph of a script, the blocks are in address order.
+type Graph struct {
+	Script   *Script
+	Triggers []Trigger
+	Blocks   []*BasicBlock
+	blocks   map[int]*BasicBlock
+	// returning tells whether a subroutine entry reaches a Return, see findReturningSubroutines
+	returning map[int]bool
+}
+
+// BuildGraph splits a script into basic blocks and links them. Jumps, conditionals, calls and returns end a block,
+// a return has an edge to the instruction after every call of the subroutine containing it. Blocks are reachable
+// if a path leads from a trigger to them. The return edges are only drawn: a path goes on after a call if the
+// subroutine can return, not from a return to every call of the subroutine.
+func BuildGraph(script *Script, triggers []Trigger) *Graph {
+	g := &Graph{Script: script, Triggers: triggers, blocks: make(map[int]*BasicBlock), returning: make(map[int]bool)}
+
+	leaders := map[int]bool{script.Offset: true}
+	for _, trigger := range triggers {
+		leaders[trigger.Address] = true
+	}
+	for _, instruction := range script.Instructions {
+		if endsBlock(instruction) {
+			leaders[instruction.GetOffset()+len(instruction.Encode())] = true
+		}
+		for _, target := range Targets(instruction) {
+			leaders[target] = true
+		}
+	}
+
+	var block *BasicBlock
+	for _, instruction := range script.Instructions {
+		offset := instruction.GetOffset()
+		if block == nil || leaders[offset] {
+			block = &BasicBlock{Start: offset}
+			g.Blocks = append(g.Blocks, block)
+			g.blocks[offset] = block
+		}
+		block.Instructions = append(block.Instructions, instruction)
+		block.End = offset + len(instruction.Encode())
+	}
+
+	for _, block := range g.Blocks {
+		g.link(block)
+	}
+	g.findReturningSubroutines()
+	g.linkReturns()
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		if block := g.blocks[trigger.Address]; block != nil {
+			block.Roots = append(block.Roots, trigger)
+		}
+	}
+	g.markReachable(triggers)
+	return g
+}
+
+// endsBlock reports whether an instruction leaves the straight line of code.
+func endsBlock(instruction Instruction) bool {
+	switch instruction.(type) {
+	case *Jump, *Conditional, *Call, *Return, *EndCode, *RawBytes:
+		return true
+	}
+	return false
+}
+
+func (g *Graph) link(block *BasicBlock) {
+	last := block.Instructions[len(block.Instructions)-1]
+	switch i := last.(type) {
+	case *Jump:
+		block.Edges = append(block.Edges, Edge{Kind: EdgeJump, Target: i.Target})
+	case *Conditional:
+		block.Edges = append(block.Edges, Edge{Kind: EdgeTrue, Target: block.End}, Edge{Kind: EdgeFalse, Target: i.FalseTarget})
+	case *Call:
+		block.Edges = append(block.Edges, Edge{Kind: EdgeCall, Target: i.Target})
+	case *Return, *EndCode, *RawBytes:
+	default:
+		block.Edges = append(block.Edges, Edge{Kind: EdgeFallThrough, Target: block.End})
+	}
+	for _, edge := range block.Edges {
+		if edge.Target == g.Script.End {
+			block.FallsIntoTable = true
+		}
+	}
+}
+
+// linkReturns adds the return edges from the returns of every subroutine to the instructions after its calls.
+func (g *Graph) linkReturns() {
+	for _, block := range g.Blocks {
+		call, ok := block.Instructions[len(block.Instructions)-1].(*Call)
+		if !ok || !g.canReturn(call.Target) {
+			continue
+		}
+		for _, returning := range g.subroutineReturns(call.Target) {
+			returning.Edges = append(returning.Edges, Edge{Kind: EdgeReturn, Target: block.End})
+		}
+	}
+}
+
+// findReturningSubroutines finds the subroutines reaching a Return. Starting with none, a subroutine returns if
+// a Return is reached through calls of the subroutines known to return, until no more are found. This way
+// recursive subroutines, also calling each other, return if one of their paths reaches a Return.
+func (g *Graph) findReturningSubroutines() {
+	var entries []int
+	for _, instruction := range g.Script.Instructions {
+		call, ok := instruction.(*Call)
+		if !ok {
+			continue
+		}
+		if _, found := g.returning[call.Target]; !found {
+			g.returning[call.Target] = false
+			entries = append(entries, call.Target)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, entry := range entries {
+			if !g.returning[entry] && len(g.subroutineReturns(entry)) > 0 {
+				g.returning[entry] = true
+				changed = true
+			}
+		}
+	}
+}
+
+// canReturn reports whether a Return is reached from a subroutine entry.
+func (g *Graph) canReturn(entry int) bool {
+	return g.returning[entry]
+}
+
+// subroutineReturns returns the blocks with a Return reached from a subroutine entry, nested calls are stepped over
+// if they can return.
+func (g *Graph) subroutineReturns(entry int) []*BasicBlock {
+	var returns []*BasicBlock
+	visited := map[int]bool{}
+	queue := []int{entry}
+	for len(queue) > 0 {
+		address := queue[0]
+		queue = queue[1:]
+		block := g.blocks[address]
+		if block == nil || visited[address] {
+			continue
+		}
+		visited[address] = true
+
+		switch i := block.Instructions[len(block.Instructions)-1].(type) {
+		case *Return:
+			returns = append(returns, block)
+		case *Call:
+			if g.canReturn(i.Target) {
+				queue = append(queue, block.End)
+			}
+		}
+		for _, edge := range block.Edges {
+			if edge.Kind != EdgeCall && edge.Kind != EdgeReturn {
+				queue = append(queue, edge.Target)
+			}
+		}
+	}
+	return returns
+}
+
+func (g *Graph) markReachable(triggers []Trigger) {
+	var roots []int
+	for _, trigger := range triggers {
+		roots = append(roots, trigger.Address)
+	}
+	for _, block := range g.reachableFrom(roots) {
+		block.Reachable = true
+	}
+}
+
+// reachableFrom returns the blocks reached from the addresses in address order. Return edges are not followed,
+// a call goes on after the call if the subroutine can return.
+func (g *Graph) reachableFrom(addresses []int) []*BasicBlock {
+	visited := map[int]bool{}
+	queue := append([]int(nil), addresses...)
+	for len(queue) > 0 {
+		address := queue[0]
+		queue = queue[1:]
+		block := g.blocks[address]
+		if block == nil || visited[address] {
+			continue
+		}
+		visited[address] = true
+		if call, ok := block.Instructions[len(block.Instructions)-1].(*Call); ok && g.canReturn(call.Target) {
+			queue = append(queue, block.End)
+		}
+		for _, edge := range block.Edges {
+			if edge.Kind != EdgeReturn {
+				queue = append(queue, edge.Target)
+			}
+		}
+	}
+
+	var blocks []*BasicBlock
+	for _, block := range g.Blocks {
+		if visited[block.Start] {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// Block returns the block starting at an address or nil.
+func (g *Graph) Block(address int) *BasicBlock {
+	return g.blocks[address]
+}
+
+// UnreachableBlocks returns the blocks no trigger leads to.
+func (g *Graph) UnreachableBlocks() []*BasicBlock {
+	var blocks []*BasicBlock
+	for _, block := range g.Blocks {
+		if !block.Reachable {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// InvalidTargets returns the edge targets that are neither the start of a block nor the end of the script.
+func (g *Graph) InvalidTargets() []int {
+	seen := map[int]bool{}
+	for _, block := range g.Blocks {
+		for _, edge := range block.Edges {
+			if g.blocks[edge.Target] == nil && edge.Target != g.Script.End {
+				seen[edge.Target] = true
+			}
+		}
+	}
+	targets := make([]int, 0, len(seen))
+	for target := range seen {
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+	return targets
+}
+
+var edgeStyles = map[EdgeKind]string{
+	EdgeFallThrough: "",
+	EdgeJump:        `label="jump"`,
+	EdgeTrue:        `label="true" color="darkgreen" fontcolor="darkgreen"`,
+	EdgeFalse:       `label="false" color="red" fontcolor="red"`,
+	EdgeCall:        `label="call" style="dashed" color="blue" fontcolor="blue"`,
+	EdgeReturn:      `label="return" style="dotted" color="blue" fontcolor="blue"`,
+}
+
+// WriteDOT writes the blocks reached from the triggers as a Graphviz digraph, all blocks if triggers is nil.
+// Triggers are drawn as ellipses pointing at their blocks, unreachable blocks grey and blocks falling into the
+// trigger table with a red border.
+func (g *Graph) WriteDOT(w io.Writer, name string, triggers []Trigger) error {
+	blocks := g.Blocks
+	if triggers != nil {
+		var roots []int
+		for _, trigger := range triggers {
+			roots = append(roots, trigger.Address)
+		}
+		blocks = g.reachableFrom(roots)
+	} else {
+		triggers = g.Triggers
+	}
+
+	ew := &errWriter{w: w}
+	ew.printf("digraph %s {\n", dotQuote(name, "\\n"))
+	ew.printf("  node [shape=box fontname=\"monospace\" fontsize=10];\n")
+	ew.printf("  edge [fontname=\"monospace\" fontsize=9];\n")
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		ew.printf("  trigger_%d [shape=ellipse label=%s];\n", trigger.Index,
+			dotQuote(fmt.Sprintf("trigger %d\n[%d,%d] $%02x\n%s", trigger.Index, trigger.Pos.X, trigger.Pos.Y, trigger.Flags, trigger.Events()), "\\n"))
+		ew.printf("  trigger_%d -> %s;\n", trigger.Index, blockNode(trigger.Address))
+	}
+
+	drawn := map[int]bool{}
+	for _, block := range blocks {
+		drawn[block.Start] = true
+	}
+	fallsIntoTable, invalid := false, map[int]bool{}
+	for _, block := range blocks {
+		var lines []string
+		if !block.Reachable {
+			lines = append(lines, "; unreachable")
+		}
+		for _, instruction := range block.Instructions {
+			lines = append(lines, Label(instruction.GetOffset())+": "+FormatInstruction(instruction))
+		}
+		attributes := ""
+		if !block.Reachable {
+			attributes += ` style="filled" fillcolor="lightgrey"`
+		}
+		if block.FallsIntoTable {
+			attributes += ` color="red" penwidth=2`
+		}
+		ew.printf("  %s [label=%s%s];\n", blockNode(block.Start), dotQuote(strings.Join(lines, "\n")+"\n", "\\l"), attributes)
+
+		for _, edge := range block.Edges {
+			// the calls of a subroutine from other triggers are not drawn
+			if edge.Kind == EdgeReturn && !drawn[edge.Target] {
+				continue
+			}
+			target := blockNode(edge.Target)
+			switch {
+			case edge.Target == g.Script.End:
+				target, fallsIntoTable = "trigger_table", true
+			case g.blocks[edge.Target] == nil:
+				invalid[edge.Target] = true
+			}
+			style := edgeStyles[edge.Kind]
+			if style != "" {
+				style = " [" + style + "]"
+			}
+			ew.printf("  %s -> %s%s;\n", blockNode(block.Start), target, style)
+		}
+	}
+
+	if fallsIntoTable {
+		ew.printf("  trigger_table [shape=octagon color=\"red\" label=%s];\n", dotQuote(fmt.Sprintf("trigger table\n$%04x", g.Script.End), "\\n"))
+	}
+	targets := make([]int, 0, len(invalid))
+	for target := range invalid {
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+	for _, target := range targets {
+		ew.printf("  %s [shape=octagon color=\"red\" label=%s];\n", blockNode(target), dotQuote(Label(target)+"\nno instruction", "\\n"))
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+func blockNode(address int) string {
+	return fmt.Sprintf("block%s", Label(address))
+}
+
+// dotQuote returns a DOT string with the line breaks replaced by a separator: \n centers the lines, \l
+// left-justifies them.
+func dotQuote(text string, separator string) string {
+	text = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text)
+	return `"` + strings.ReplaceAll(text, "\n", separator) + `"`
+}
@@ -0,0 +1,184 @@
+package inf
+
+import (
+	"strings"
+	"testing"
+)
+
+const graphSource = `.trigger 0, [1,1], $08, _first
+.trigger 1, [2,2], $08, _second
+.trigger 2, [3,3], $08, _third
+.trigger 3, [4,4], $08, _fourth
+.org $0010
+_first: Call target=_sub
+Message text="after first" color=1
+EndCode
+_second: Call target=_sub
+Message text="after second" color=1
+EndCode
+_sub: Message text="sub" color=1
+Return
+_dead: Message text="dead" color=1
+EndCode
+_third: Call target=_stop
+Message text="after stop" color=1
+EndCode
+_stop: Message text="stop" color=1
+EndCode
+_fourth: Call target=_recursive
+Message text="after recursive" color=1
+EndCode
+_recursive: Conditional { mazeFlag(1) } falseTarget=_return
+Call target=_recursive
+Message text="after recursion" color=1
+_return: Return
+_last: Message text="falls into the table" color=1
+Jump target=_last
+Message text="last" color=1
+`
+
+func buildTestGraph(t *testing.T) *Graph {
+	t.Helper()
+	script, triggers, err := Assemble(strings.NewReader(graphSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BuildGraph(script, triggers)
+}
+
+// messageBlock returns the block printing a message.
+func messageBlock(t *testing.T, g *Graph, text string) *BasicBlock {
+	t.Helper()
+	for _, block := range g.Blocks {
+		for _, instruction := range block.Instructions {
+			if message, ok := instruction.(*Message); ok && message.Text == text {
+				return block
+			}
+		}
+	}
+	t.Fatalf("no block prints %q", text)
+	return nil
+}
+
+func TestBuildGraphBlocks(t *testing.T) {
+	g := buildTestGraph(t)
+	for i, block := range g.Blocks {
+		if g.Block(block.Start) != block {
+			t.Errorf("block at $%04x not found", block.Start)
+		}
+		if i > 0 && g.Blocks[i-1].End != block.Start {
+			t.Errorf("block at $%04x does not follow the block ending at $%04x", block.Start, g.Blocks[i-1].End)
+		}
+	}
+	if first, last := g.Blocks[0], g.Blocks[len(g.Blocks)-1]; first.Start != g.Script.Offset || last.End != g.Script.End {
+		t.Errorf("blocks from $%04x to $%04x instead of the script", first.Start, last.End)
+	}
+
+	sub := messageBlock(t, g, "sub")
+	var returns []int
+	for _, edge := range sub.Edges {
+		if edge.Kind == EdgeReturn {
+			returns = append(returns, edge.Target)
+		}
+	}
+	if len(returns) != 2 || returns[0] != messageBlock(t, g, "after first").Start || returns[1] != messageBlock(t, g, "after second").Start {
+		t.Errorf("return edges to %v", returns)
+	}
+	if len(messageBlock(t, g, "stop").Edges) != 0 {
+		t.Error("a subroutine without a return has return edges")
+	}
+	if roots := g.Block(g.Triggers[0].Address).Roots; len(roots) != 1 || roots[0].Index != 0 {
+		t.Errorf("roots %v", roots)
+	}
+	if !messageBlock(t, g, "last").FallsIntoTable || messageBlock(t, g, "sub").FallsIntoTable {
+		t.Error("falling into the trigger table not detected")
+	}
+	if targets := g.InvalidTargets(); len(targets) != 0 {
+		t.Errorf("invalid targets %v", targets)
+	}
+}
+
+func TestBuildGraphReachable(t *testing.T) {
+	g := buildTestGraph(t)
+	unreachable := map[string]bool{"dead": true, "after stop": true, "falls into the table": true, "last": true}
+	for _, text := range []string{"after first", "after second", "sub", "dead", "after stop", "stop", "after recursive",
+		"after recursion", "falls into the table", "last"} {
+		if block := messageBlock(t, g, text); block.Reachable == unreachable[text] {
+			t.Errorf("block printing %q reachable %v", text, block.Reachable)
+		}
+	}
+
+	var blocks []string
+	for _, block := range g.UnreachableBlocks() {
+		blocks = append(blocks, Label(block.Start))
+	}
+	if len(blocks) != 4 {
+		t.Errorf("unreachable blocks %v", blocks)
+	}
+}
+
+func TestReachableFromTrigger(t *testing.T) {
+	g := buildTestGraph(t)
+	reached := map[*BasicBlock]bool{}
+	for _, block := range g.reachableFrom([]int{g.Triggers[1].Address}) {
+		reached[block] = true
+	}
+	if !reached[messageBlock(t, g, "sub")] || !reached[messageBlock(t, g, "after second")] {
+		t.Error("subroutine or return address of trigger 1 not reached")
+	}
+	if reached[messageBlock(t, g, "after first")] {
+		t.Error("return address of the call of trigger 0 reached from trigger 1")
+	}
+	if len(reached) != 3 {
+		t.Errorf("%d blocks reached", len(reached))
+	}
+
+	var dot strings.Builder
+	if err := g.WriteDOT(&dot, "test", g.Triggers[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	first := blockNode(messageBlock(t, g, "after first").Start)
+	if strings.Contains(dot.String(), first) || !strings.Contains(dot.String(), "after second") {
+		t.Errorf("graph of trigger 1:\n%s", dot.String())
+	}
+}
+
+func TestBuildGraphMutualRecursion(t *testing.T) {
+	// _b only returns through _a, which is searched first
+	script, triggers, err := Assemble(strings.NewReader(`.trigger 0, [1,1], $08, _main
+.org $0010
+_main: Call target=_a
+Message text="after a" color=1
+Call target=_b
+Message text="after b" color=1
+EndCode
+_a: Conditional { mazeFlag(1) } falseTarget=_return
+Call target=_b
+Message text="after a calls b" color=1
+_return: Return
+_b: Call target=_a
+Message text="after b calls a" color=1
+Return
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := BuildGraph(script, triggers)
+	for _, text := range []string{"after a", "after b", "after a calls b", "after b calls a"} {
+		if !messageBlock(t, g, text).Reachable {
+			t.Errorf("block printing %q unreachable", text)
+		}
+	}
+
+	// the return of _b leads back to both of its calls
+	afterB, afterACallsB := messageBlock(t, g, "after b"), messageBlock(t, g, "after a calls b")
+	returns := map[int]bool{}
+	for _, edge := range messageBlock(t, g, "after b calls a").Edges {
+		if edge.Kind == EdgeReturn {
+			returns[edge.Target] = true
+		}
+	}
+	if !returns[afterB.Start] || !returns[afterACallsB.Start] {
+		t.Errorf("return edges of _b %v", returns)
+	}
+}
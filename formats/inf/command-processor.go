@@ -5,7 +5,6 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
 )
 
 func rb(buffer *bytes.Reader) byte {
@@ -19,921 +18,341 @@ func rw(buffer *bytes.Reader) uint16 {
 	return word
 }
 
-func ParseScripts(buffer *bytes.Reader, triggers *[]Trigger, triggerOffset uint16) error {
-	//error := false
-	offset := getOffset(buffer)
+func rp(buffer *bytes.Reader) Position {
+	return NewPositionFromWord(rw(buffer))
+}
 
-	var error = false
-	for offset < int64(triggerOffset) && !error {
-		trigger := checkTriggerReference(triggers, offset)
-		fmt.Printf("_0x%04x: ;", offset)
+// ParseScripts decodes the bytecode from the current position of the buffer up to the trigger table. After an
+// unknown opcode the rest of the bytecode is kept as RawBytes and an error is returned together with the script.
+func ParseScripts(buffer *bytes.Reader, triggers *[]Trigger, triggerOffset uint16) (*Script, error) {
+	start := getOffset(buffer)
+	end := int64(triggerOffset)
+	var instructions []Instruction
 
-		lastOffset := offset
-		command := rb(buffer)
-		switch command {
-		case 0xff:
-			parseSetWall(buffer)
-		case 0xfe:
-			parseChangeWall(buffer)
-		case 0xfd:
-			parseOpenDoor(buffer)
-		case 0xfc:
-			parseCloseDoor(buffer)
-		case 0xfb:
-			parseCreateMonster(buffer)
-		case 0xfa:
-			parseTeleport(buffer)
-		case 0xf9:
-			parseStealSmallItems(buffer)
-		case 0xf8:
-			parseMessage(buffer)
-		case 0xf7:
-			parseSetFlag(buffer)
-		case 0xf6:
-			parseSound(buffer)
-		case 0xf5:
-			parseClearFlag(buffer)
-		case 0xf4:
-			// parseHeal (never used in EobI)
-			continue
-		case 0xf3:
-			parseDamage(buffer)
-		case 0xf2:
-			error = !parseJump(buffer, lastOffset)
-		case 0xf1:
-			parseEndCode(buffer)
-		case 0xf0:
-			parseReturn(buffer)
-		case 0xef:
-			parseCall(buffer)
-		case 0xee:
-			error = !parseConditional(buffer, lastOffset)
-		case 0xed:
-			parseItemConsume(buffer)
-		case 0xec:
-			currentLevel := 0
-			parseChangeLevel(buffer, trigger, currentLevel)
-		case 0xeb:
-			parseGiveXP(buffer)
-		case 0xea:
-			parseNewItem(buffer)
-		case 0xe9:
-			parseLauncher(buffer)
-		case 0xe8:
-			parseTurn(buffer)
-		case 0xe7:
-			parseIdentAllItems(buffer)
-		case 0xe6:
-			parseEncounters(buffer)
-		case 0xe5:
-			parseWait(buffer)
-		case 0xe4:
-			// parseUpdateScreen (never used in EobI)
-			continue
-		case 0xe3:
-			// parseTextMenu (never used in EobI)
-			continue
-		case 0xe2:
-			// parseSpecialWindowPictures (never used in EobI)
-			continue
-		default:
-			fmt.Printf("Unknown code 0x%02x offset: %d\n", command, offset-1)
-			offset = lastOffset + 16
-			error = true
+	for offset := start; offset < end; offset = getOffset(buffer) {
+		instruction, err := parseInstruction(buffer, int(offset))
+		if err == nil && getOffset(buffer) > end {
+			err = fmt.Errorf("instruction at 0x%04x overlaps the trigger table", offset)
+		}
+		if err != nil {
+			raw := make([]byte, end-offset)
+			buffer.ReadAt(raw, offset)
+			buffer.Seek(end, io.SeekStart)
+			instructions = append(instructions, &RawBytes{Location: Location{Offset: int(offset)}, Bytes: raw})
+			return NewScript(int(start), int(end), instructions, *triggers), err
 		}
-		offset = getOffset(buffer)
+		instructions = append(instructions, instruction)
 	}
-	return nil
-}
-
-func parseWait(buffer *bytes.Reader) {
-	fmt.Printf("Wait\n;{\n")
-
-	ticks := rw(buffer)
-	fmt.Printf(";   Ticks = %d\n", ticks)
-
-	fmt.Printf("}\n")
-}
-
-func parseIdentAllItems(buffer *bytes.Reader) {
-	fmt.Printf("IdentAllItems\n;{\n")
-
-	pos := rw(buffer)
-
-	fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-
-	fmt.Printf(";}\n")
-}
-
-func parseTurn(buffer *bytes.Reader) {
-	fmt.Printf("Turn\n;{\n")
-
-	t := rb(buffer)
-	dir := rb(buffer)
-
-	if t == 0xf1 {
-		fmt.Printf(";   Type = Party (0x%02x)\n", t)
-	} else if t == 0xf5 {
-		fmt.Printf(";   Type = Item (0x%02x)\n", t)
-	} else {
-		fmt.Printf(";   Type = Unknown (0x%02x)\n", t)
-		fmt.Printf(";   Direction = %d\n", dir)
+	return NewScript(int(start), int(end), instructions, *triggers), nil
+}
+
+func parseInstruction(buffer *bytes.Reader, offset int) (Instruction, error) {
+	location := Location{Offset: offset}
+	command := rb(buffer)
+	switch command {
+	case OpSetWall:
+		return parseSetWall(buffer, location), nil
+	case OpChangeWall:
+		return parseChangeWall(buffer, location), nil
+	case OpOpenDoor:
+		return &OpenDoor{Location: location, Pos: rp(buffer)}, nil
+	case OpCloseDoor:
+		return &CloseDoor{Location: location, Pos: rp(buffer)}, nil
+	case OpCreateMonster:
+		return parseCreateMonster(buffer, location), nil
+	case OpTeleport:
+		return parseTeleport(buffer, location), nil
+	case OpStealSmallItems:
+		return parseStealSmallItems(buffer, location), nil
+	case OpMessage:
+		return parseMessage(buffer, location)
+	case OpSetFlag:
+		return &SetFlag{Location: location, FlagOperand: parseFlag(buffer)}, nil
+	case OpSound:
+		return &Sound{Location: location, Id: int(rb(buffer)), Pos: rp(buffer)}, nil
+	case OpClearFlag:
+		return &ClearFlag{Location: location, FlagOperand: parseFlag(buffer)}, nil
+	case OpHeal:
+		// never used in EobI
+		return &Heal{Location: location}, nil
+	case OpDamage:
+		return parseDamage(buffer, location), nil
+	case OpJump:
+		return &Jump{Location: location, Target: int(rw(buffer))}, nil
+	case OpEndCode:
+		return &EndCode{Location: location}, nil
+	case OpReturn:
+		return &Return{Location: location}, nil
+	case OpCall:
+		return &Call{Location: location, Target: int(rw(buffer))}, nil
+	case OpConditional:
+		return parseConditional(buffer, location)
+	case OpItemConsume:
+		return parseItemConsume(buffer, location), nil
+	case OpChangeLevel:
+		return parseChangeLevel(buffer, location), nil
+	case OpGiveXP:
+		return parseGiveXP(buffer, location), nil
+	case OpNewItem:
+		return parseNewItem(buffer, location), nil
+	case OpLauncher:
+		return parseLauncher(buffer, location), nil
+	case OpTurn:
+		return &Turn{Location: location, Type: rb(buffer), Direction: int(rb(buffer))}, nil
+	case OpIdentAllItems:
+		return &IdentAllItems{Location: location, Pos: rp(buffer)}, nil
+	case OpEncounters:
+		return &Encounters{Location: location, Id: int(rb(buffer))}, nil
+	case OpWait:
+		return &Wait{Location: location, Ticks: int(rw(buffer))}, nil
+	case OpUpdateScreen:
+		// never used in EobI
+		return &UpdateScreen{Location: location}, nil
+	case OpTextMenu:
+		// never used in EobI
+		return &TextMenu{Location: location}, nil
+	case OpSpecialWindowPictures:
+		// never used in EobI
+		return &SpecialWindowPictures{Location: location}, nil
 	}
-
-	fmt.Printf(";}\n")
+	return nil, fmt.Errorf("unknown code 0x%02x at 0x%04x", command, offset)
 }
 
-func parseLauncher(buffer *bytes.Reader) {
-	fmt.Printf("Launcher\n;{\n")
-
+func parseLauncher(buffer *bytes.Reader, location Location) *Launcher {
 	kind := rb(buffer)
-	itemno := rw(buffer)
-	pos := rw(buffer)
+	id := rw(buffer)
+	pos := rp(buffer)
 	dir := rb(buffer)
 	subpos := rb(buffer)
-
-	var t = kind == 0xdf
-	var n = ""
-	if t {
-		n = "Spell"
-	} else {
-		n = "Item"
-	}
-
-	fmt.Printf(";   Kind = %s\n", n)
-	fmt.Printf(";   Item#/Spell# = %d\n", itemno)
-	fmt.Printf(";   Pos = [%d,%d:%d]\n", pos&31, pos/32, subpos)
-	fmt.Printf(";   Direction = %d\n", dir)
-
-	fmt.Printf(";}\n")
+	return &Launcher{Location: location, Kind: kind, Id: int(id), Pos: pos, Direction: int(dir), SubPos: int(subpos)}
 }
 
-func parseNewItem(buffer *bytes.Reader) {
-	fmt.Printf("NewItem\n;{\n")
-
+func parseNewItem(buffer *bytes.Reader, location Location) *NewItem {
 	itemno := rw(buffer)
-	pos := rw(buffer)
+	pos := rp(buffer)
 	subpos := rb(buffer)
-	fmt.Printf(";   Item# = $%04x\n", itemno)
-	if pos != 0xffff {
-		fmt.Printf(";   Position = [%d,%d:%d]\n", pos&31, pos/32, subpos)
-	} else {
-		fmt.Printf(";   Position = n/a\n")
-	}
-
-	fmt.Printf(";}\n")
+	return &NewItem{Location: location, Item: int(itemno), Pos: pos, SubPos: int(subpos)}
 }
 
-func parseGiveXP(buffer *bytes.Reader) {
-	fmt.Printf("GiveExperience\n;{\n")
-
-	t := rb(buffer)
-	if t == 0xe2 {
-		amount := rw(buffer)
-		fmt.Printf(";   Type = Party\n")
-		fmt.Printf(";   Amount = %d\n", amount)
-	} else {
-		fmt.Printf(";   Type = Unknown (0x%02x)\n", t)
+func parseGiveXP(buffer *bytes.Reader, location Location) *GiveXP {
+	result := &GiveXP{Location: location, Type: rb(buffer)}
+	if result.Type == ExperienceParty {
+		result.Amount = int(rw(buffer))
 	}
-
-	fmt.Printf(";}\n")
+	return result
 }
 
-func parseChangeLevel(buffer *bytes.Reader, trigger *Trigger, currentLevel int) {
-	fmt.Printf("ChangeLevel\n;{\n")
-
-	t := rb(buffer)
-	if t == 0xe5 {
-		fmt.Printf(";   Type = Real level change\n")
-		level := rb(buffer)
-		position := rw(buffer)
-		direction := rb(buffer)
-		x := position & 31
-		y := position / 32
-
-		fmt.Printf(";   Target =   X:%d\n", x)
-		fmt.Printf(";              Y:%d\n", y)
-		fmt.Printf(";            Dir:%d\n", direction)
-		fmt.Printf(";            Lvl:%d\n", level)
-
-		fmt.Printf(";}\n")
-
-		// A simple hole!
-		if (int(level) == currentLevel+1) && (direction == 255) && (x == uint16(trigger.Pos.X)) && (y == uint16(trigger.Pos.Y)) {
-			fmt.Printf(".byte $e4; New C64 byte code for falling down.\n")
-			return
-		} else {
-			//emitC64Raw();
-		}
+func parseChangeLevel(buffer *bytes.Reader, location Location) *ChangeLevel {
+	result := &ChangeLevel{Location: location, Type: rb(buffer)}
+	if result.Type == LevelChange {
+		// Real level change
+		result.Level = int(rb(buffer))
+		result.Pos = rp(buffer)
+		result.Direction = int(rb(buffer))
 	} else {
-		fmt.Printf(";   Type = Inter level change\n")
-		direction := rb(buffer)
-		position := rw(buffer)
-		fmt.Printf(";   Target =   X:%d\n", position&31)
-		fmt.Printf(";              Y:%d\n", position/32)
-		fmt.Printf(";            Dir:%d\n", direction)
-
-		fmt.Printf(";}\n")
-		//emitC64Raw();
+		// Inter level change
+		result.Direction = int(rb(buffer))
+		result.Pos = rp(buffer)
 	}
-
+	return result
 }
 
-func parseItemConsume(buffer *bytes.Reader) {
-	fmt.Printf("ItemConsume\n;{\n")
-
-	loci := rb(buffer)
-
-	if loci == 0xff {
-		fmt.Printf(";   Location = Mouse pointer\n")
-	} else if loci == 0xfe {
-		pos := rw(buffer)
-		fmt.Printf(";   Position = [%d,%d:*]\n", pos&31, pos/32)
-	} else {
-		pos := rw(buffer)
-		fmt.Printf(";   Position = [%d,%d]. Item.type=$%02x\n", pos&31, pos/32, loci)
+func parseItemConsume(buffer *bytes.Reader, location Location) *ItemConsume {
+	result := &ItemConsume{Location: location, ItemType: rb(buffer)}
+	if result.ItemType != ConsumeAtPointer {
+		result.Pos = rp(buffer)
 	}
-
-	fmt.Printf(";}\n")
-}
-
-func parseCall(buffer *bytes.Reader) {
-	address := rw(buffer)
-	fmt.Printf("Call 0x%04x\n", address)
-
-	fmt.Printf(".byte $ef,<_0x%04x,>_0x%04x\n", address, address)
-
-}
-
-func parseReturn(buffer *bytes.Reader) {
-	fmt.Printf("Return\n")
-}
-
-func parseEndCode(buffer *bytes.Reader) {
-	fmt.Printf("Abort event\n")
+	return result
 }
 
-func parseJump(buffer *bytes.Reader, lastOffset int64) bool {
-	address := rw(buffer)
-	fmt.Printf("jump 0x%04x\n", address)
-
-	if lastOffset < int64(address) {
-		fmt.Printf(".assert _0x%04x - * <= 255, error, \"Illegal branch\"\n", address)
-		fmt.Printf(".byte $f2, <(_0x%04x - *)\n", address)
-	} else {
-		fmt.Printf("ERROR: Jump is negative at 0x%04x\n", lastOffset)
-		return false
-	}
-	return true
-
-}
-
-func parseDamage(buffer *bytes.Reader) {
-	fmt.Printf("Damage\n;{\n")
-
+func parseDamage(buffer *bytes.Reader, location Location) *Damage {
 	whom := rb(buffer)
-	flag1 := rb(buffer)
-	flag2 := rb(buffer)
-	flag3 := rb(buffer)
-
-	fmt.Printf(";   Whom = ")
-	if whom == 0xff {
-		fmt.Printf("All\n")
-	} else {
-		fmt.Printf("Memeber %d\n", whom)
-	}
-
-	fmt.Printf(";   Flag1 = 0x%02x\n", flag1)
-	fmt.Printf(";   Flag2 = 0x%02x\n", flag2)
-	fmt.Printf(";   Flag3 = 0x%02x\n", flag3)
-
-	fmt.Printf(";}\n")
-}
-
-func parseClearFlag(buffer *bytes.Reader) {
-	fmt.Printf("ClearFlag\n;{\n")
-
-	target := rb(buffer)
-	fmt.Printf(";   Target = ")
-	if target == 0xef {
-		fmt.Printf("Maze\n")
-		flag := rb(buffer)
-		fmt.Printf(";   Flag = %d\n", flag)
-	} else if target == 0xf0 {
-		fmt.Printf("Global\n")
-		flag := rb(buffer)
-		fmt.Printf(";   Flag = %d\n", flag)
-	} else if target == 0xf3 {
-		fmt.Printf("Monster\n")
-		id := rb(buffer)
-		flag := rb(buffer)
-		fmt.Printf(";   Monster = %d\n", id)
-		fmt.Printf(";   Flag = %d\n", flag)
-	} else if target == 0xe4 {
-		fmt.Printf("Event\n")
-	} else if target == 0xd1 {
-		fmt.Printf("Party_Function(FUNC_SETVAL, PARTY_SAVEREST, 0);\n")
+	rolls := rb(buffer)
+	sides := rb(buffer)
+	base := rb(buffer)
+	return &Damage{Location: location, Whom: int(whom), Rolls: int(rolls), Sides: int(sides), Base: int(base)}
+}
+
+func parseFlag(buffer *bytes.Reader) FlagOperand {
+	result := FlagOperand{Target: rb(buffer)}
+	switch result.Target {
+	case FlagMaze, FlagGlobal:
+		result.Flag = int(rb(buffer))
+	case FlagMonster:
+		result.Monster = int(rb(buffer))
+		result.Flag = int(rb(buffer))
 	}
-	fmt.Printf(";}\n")
+	return result
 }
 
-func parseSound(buffer *bytes.Reader) {
-	fmt.Printf("Sound\n;{\n")
-
-	id := rb(buffer)
-	pos := rw(buffer)
-
-	if pos > 0 {
-		fmt.Printf(";   ID: $%02x\n", id)
-		fmt.Printf(";   Position: [%d,%d]\n", pos&31, pos/32)
-	} else {
-		fmt.Printf(";   ID: $%02x\n", id)
-	}
-
-	fmt.Printf(";}\n")
-}
-
-func parseStealSmallItems(buffer *bytes.Reader) {
-	fmt.Printf("StealSmallItems\n;{\n")
-
+func parseStealSmallItems(buffer *bytes.Reader, location Location) *StealSmallItems {
 	whom := rb(buffer)
-
-	fmt.Printf(";   Whom = ")
-	if whom == 0xff {
-		fmt.Printf("Random\n")
-	} else {
-		fmt.Printf("Member %d\n", whom)
-	}
-
-	pos := rw(buffer)
-	subpos := rb(buffer)
-	fmt.Printf(";   Drop position = [%d,%d:%d]\n", pos&31, pos/32, subpos)
-
-	fmt.Printf(";}\n")
-}
-
-func parseTeleport(buffer *bytes.Reader) {
-	fmt.Printf("Teleport\n;{\n")
-
-	t := rb(buffer)
-	var source uint16 = 0
-	var dest uint16 = 0
-
-	switch t {
-	case 0xe8: // Teleport party
-		rw(buffer)
-		dest = rw(buffer)
-		fmt.Printf(";   Type = Party\n")
-		fmt.Printf(";   Dest = [%d,%d]\n", dest&31, dest/32)
-	case 0xf3: // Monster
-		source = rw(buffer)
-		dest = rw(buffer)
-		fmt.Printf(";   Type = Monster\n")
-		fmt.Printf(";   Source = [%d,%d]\n", source&31, source/32)
-		fmt.Printf(";   Dest = [%d,%d]\n", dest&31, dest/32)
-	case 0xf5: // Item
-		source = rw(buffer)
-		dest = rw(buffer)
-		fmt.Printf(";   Type = Item\n")
-		fmt.Printf(";   Source = [%d,%d]\n", source&31, source/32)
-		fmt.Printf(";   Dest = [%d,%d]\n", dest&31, dest/32)
-	default:
-		source = rw(buffer)
-		dest = rw(buffer)
-		fmt.Printf(";   Type = Unknown ($%02x)\n", t)
-		fmt.Printf(";   Source = [%d,%d]\n", source&31, source/32)
-		fmt.Printf(";   Dest = [%d,%d]\n", dest&31, dest/32)
-	}
-
-	fmt.Printf(";}\n")
-
-}
-
-func parseCreateMonster(buffer *bytes.Reader) {
-	fmt.Printf("CreateMonster\n;{\n")
-	rb(buffer)
-
-	movetime := rb(buffer)
-	pos := rw(buffer)
+	pos := rp(buffer)
 	subpos := rb(buffer)
-	dir := rb(buffer)
-	t := rb(buffer)
-	pic := rb(buffer)
-	phase := rb(buffer)
-	pause := rb(buffer)
-	pocket := rw(buffer)
-	weapon := rw(buffer)
-
-	fmt.Printf(";   Move time = %d\n", movetime)
-	fmt.Printf(";   Position = [%d,%d:%d]\n", pos&31, pos/32, subpos)
-	fmt.Printf(";   Direction = %d\n", dir)
-	fmt.Printf(";   Type = %d\n", t)
-	fmt.Printf(";   Pic = %d\n", pic)
-	fmt.Printf(";   Phase = %d\n", phase)
-	fmt.Printf(";   Pause = %d\n", pause)
-	fmt.Printf(";   Pocket = %d\n", pocket)
-	fmt.Printf(";   Weapon = %d\n", weapon)
-
-	fmt.Printf(";}\n")
+	return &StealSmallItems{Location: location, Whom: int(whom), Pos: pos, SubPos: int(subpos)}
 }
 
-func parseCloseDoor(buffer *bytes.Reader) {
-	fmt.Printf("OpenDoor\n;{\n")
-
-	pos := rw(buffer)
-	fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-
-	fmt.Printf(";}\n")
-}
-
-func parseOpenDoor(buffer *bytes.Reader) {
-	fmt.Printf("OpenDoor\n;{\n")
-
-	pos := rw(buffer)
-	fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-
-	fmt.Printf(";}\n")
-}
-
-func parseChangeWall(buffer *bytes.Reader) {
-	fmt.Printf("ChangeWall\n;{\n")
-	forcedSpecialMazeList := make(map[uint16]bool)
-
+func parseTeleport(buffer *bytes.Reader, location Location) *Teleport {
 	t := rb(buffer)
-	if t == 0xf7 {
-		fmt.Printf(";   Type = Change all sides\n")
-		pos := rw(buffer)
-		to := rb(buffer)
-		from := rb(buffer)
-		forcedSpecialMazeList[pos] = true
-		fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-		fmt.Printf(";   Change from = %d\n", from)
-		fmt.Printf(";   Change to = %d\n", to)
-	} else if t == 0xe9 {
-		fmt.Printf(";   Type = Change one side\n")
-		pos := rw(buffer)
-		side := rb(buffer)
-		to := rb(buffer)
-		from := rb(buffer)
-		forcedSpecialMazeList[pos] = true
-		fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-		fmt.Printf(";   Side= %d\n", side)
-		fmt.Printf(";   Change from = %d\n", from)
-		fmt.Printf(";   Change to = %d\n", to)
-	} else if t == 0xea {
-		fmt.Printf(";   Type = Open door\n")
-		pos := rw(buffer)
-		forcedSpecialMazeList[pos] = true
-		fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
+	// the source is unused when the party is teleported
+	source := rp(buffer)
+	dest := rp(buffer)
+	return &Teleport{Location: location, Type: t, Source: source, Destination: dest}
+}
+
+func parseCreateMonster(buffer *bytes.Reader, location Location) *CreateMonster {
+	result := &CreateMonster{Location: location}
+	result.Index = int(rb(buffer))
+	result.MoveTime = int(rb(buffer))
+	result.Pos = rp(buffer)
+	result.SubPos = int(rb(buffer))
+	result.Direction = int(rb(buffer))
+	result.Type = int(rb(buffer))
+	result.Picture = int(rb(buffer))
+	result.Phase = int(rb(buffer))
+	result.Pause = int(rb(buffer))
+	result.Pocket = int(rw(buffer))
+	result.Weapon = int(rw(buffer))
+	return result
+}
+
+func parseChangeWall(buffer *bytes.Reader, location Location) *ChangeWall {
+	result := &ChangeWall{Location: location, Type: rb(buffer)}
+	switch result.Type {
+	case WallAllSides:
+		result.Pos = rp(buffer)
+		result.To = int(rb(buffer))
+		result.From = int(rb(buffer))
+	case WallOneSide:
+		result.Pos = rp(buffer)
+		result.Side = int(rb(buffer))
+		result.To = int(rb(buffer))
+		result.From = int(rb(buffer))
+	case WallOpenDoor:
+		result.Pos = rp(buffer)
 	}
-
-	fmt.Printf(";}\n")
-}
-
-func parseSetWall(buffer *bytes.Reader) {
-	fmt.Printf("SetWall\n;{\n")
-	forcedSpecialMazeList := make(map[uint16]bool)
-
-	t := rb(buffer)
-	if t == 0xf7 {
-		fmt.Printf(";   Type = Change all sides\n")
-		pos := rw(buffer)
-		to := rb(buffer)
-		forcedSpecialMazeList[pos] = true
-		fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-		fmt.Printf(";   Change to = %d\n", to)
-	} else if t == 0xe9 {
-		fmt.Printf(";   Type = Change one side\n")
-		pos := rw(buffer)
-		side := rb(buffer)
-		to := rb(buffer)
-		forcedSpecialMazeList[pos] = true
-		fmt.Printf(";   Position = [%d,%d]\n", pos&31, pos/32)
-		fmt.Printf(";   Side = %d\n", side)
-		fmt.Printf(";   Change to = %d\n", to)
-	} else if t == 0xed {
-		fmt.Printf(";   Type = Change party direction\n")
-		direction := rb(buffer)
-		fmt.Printf(";   Position = %d\n", direction)
+	return result
+}
+
+func parseSetWall(buffer *bytes.Reader, location Location) *SetWall {
+	result := &SetWall{Location: location, Type: rb(buffer)}
+	switch result.Type {
+	case WallAllSides:
+		result.Pos = rp(buffer)
+		result.To = int(rb(buffer))
+	case WallOneSide:
+		result.Pos = rp(buffer)
+		result.Side = int(rb(buffer))
+		result.To = int(rb(buffer))
+	case WallPartyDirection:
+		result.Direction = int(rb(buffer))
 	}
-
-	fmt.Printf(";}\n")
+	return result
 }
 
-func checkTriggerReference(triggers *[]Trigger, address int64) *Trigger {
-	for i, trigger := range *triggers {
-		if int64(trigger.Address) == address {
-			fmt.Printf("\n\n\n; --------------------------------------------------------------------\n")
-			fmt.Printf("; Referenced by trigger $%02x. Pos:[%d,%d] Flags: ", i, trigger.Pos.X, trigger.Pos.Y)
-			for b := 7; b >= 0; b-- {
-				bitSet := (1<<b)&trigger.Flags != 0
-				fmt.Printf("%+v ", bitSet)
-			}
-			fmt.Printf("\n; --------------------------------------------------------------------\n")
-			return &trigger
-		}
-	}
-	return nil
-}
-
-func parseSetFlag(buffer *bytes.Reader) {
-	fmt.Printf("SetFlag\n;{\n")
-
-	target := rb(buffer)
-	fmt.Printf(";   Target = ")
-	if target == 0xef {
-		fmt.Printf("Maze\n")
-		flag := rb(buffer)
-		fmt.Printf(";   Flag = %d\n", flag)
-	} else if target == 0xf0 {
-		fmt.Printf("Global\n")
-		flag := rb(buffer)
-		fmt.Printf(";   Flag = %d\n", flag)
-	} else if target == 0xf3 {
-		fmt.Printf("Monster\n")
-		id := rb(buffer)
-		flag := rb(buffer)
-		fmt.Printf(";   Monster = %d\n", id)
-		fmt.Printf(";   Flag = %d\n", flag)
-	} else if target == 0xe4 {
-		fmt.Printf("Event\n")
-	} else if target == 0xd1 {
-		fmt.Printf("Party_Function(FUNC_SETVAL, PARTY_SAVEREST, 0);\n")
-	}
-	fmt.Printf(";}\n")
-}
-
-func parseMessage(buffer *bytes.Reader) {
+func parseMessage(buffer *bytes.Reader, location Location) (*Message, error) {
 	var result bytes.Buffer
-	buf := make([]byte, 1)
-
 	for {
-		_, err := buffer.Read(buf)
+		b, err := buffer.ReadByte()
 		if err != nil {
-			if err == io.EOF {
-				panic("Reached end of file while reading a null terminated string")
-			}
+			return nil, fmt.Errorf("reached end of file while reading the message at 0x%04x", location.Offset)
 		}
-
-		if buf[0] == 0 {
+		if b == 0 {
 			break
 		}
-
-		result.WriteByte(buf[0])
+		result.WriteByte(b)
 	}
-	color := rb(buffer)
-	rb(buffer)
-	fmt.Printf("Message: %s Color: %d\n", result.String(), color)
-}
-
-func parseEncounters(buffer *bytes.Reader) {
-	fmt.Printf("Encounters\n;{\n")
-
-	unknown := rb(buffer)
-	fmt.Printf(";   Encounter#: $%02x\n", unknown)
-
-	fmt.Printf(";}\n")
+	color := rw(buffer)
+	return &Message{Location: location, Text: result.String(), Color: int(color)}, nil
 }
 
-func parseConditional(buffer *bytes.Reader, lastOffset int64) bool {
-	dynamicStack := false
-	simulatedStack := NewSimulatedStack()
-	stackDepth := 0
-	fmt.Println("Conditional\n{")
+func parseConditional(buffer *bytes.Reader, location Location) (*Conditional, error) {
+	result := &Conditional{Location: location}
 
 	for {
-		command := rb(buffer)
-		if command == 0xee {
-			break
-		}
-
-		fmt.Print(";   ")
-		for i := 0; i < stackDepth; i++ {
-			fmt.Print(">")
+		command, err := buffer.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reached end of file while reading the conditional at 0x%04x", location.Offset)
 		}
-
-		if command >= 0x80 && command <= 0xf7 {
-			dynamicStack = true
+		if command == OpConditional {
+			break
 		}
 
-		switch command {
-		case 0xf3:
-			subcode := rb(buffer)
-			if subcode == 0xff {
-				pos := rw(buffer)
-				fmt.Printf("push(countMonstersAt([%d,%d]))\n", pos&31, pos/32)
-				stackDepth++
-			} else {
-				for {
-					fmt.Printf("push(countMonstersOfType(%d)); ", subcode)
-					comparator := rb(buffer)
-					fmt.Printf("push(0x%02x)", comparator)
-					stackDepth += 2
-
-					subcode = rb(buffer)
-					if subcode == 0 {
-						break
-					} else {
-						fmt.Print("; ")
-					}
-				}
-				fmt.Println()
-			}
-		case 0xda:
-			fmt.Printf("push(isPartyVisible())\n")
-			stackDepth++
-		case 0xdb:
-			rolls := rb(buffer)
-			sides := rb(buffer)
-			base := rb(buffer)
-			fmt.Printf("push(rollDice(%dT%d+%d))\n", rolls, sides, base)
-			stackDepth++
-		case 0xdd:
-			fmt.Printf("push(party.containsRace(%d))\n", rb(buffer))
-			stackDepth++
-		case 0xce:
-			fmt.Printf("push(party.containsAlignment(%d))\n", rb(buffer))
-			stackDepth++
-		case 0xdc:
-			fmt.Printf("push(party.containsClass(%d))\n", rb(buffer))
-			stackDepth++
-		case 0xe0:
-			fmt.Printf("push(trigger.Flags)\n")
-			stackDepth++
-		case 0xed:
-			fmt.Printf("push(party.getDirection())\n")
-			stackDepth++
-		case 0xf0:
-			fmt.Printf("push(getFlag(Global, %d))\n", rb(buffer))
-			stackDepth++
-		case 0xe7:
-			subcode := rb(buffer)
-			if subcode == 0xe1 {
-				fmt.Printf("push(party.pointeritem.type)\n")
-			} else if subcode == 0xf5 {
-				fmt.Printf("push(party.pointeritem)\n")
-			} else if subcode == 0xf6 {
-				fmt.Printf("push(party.pointeritem.value)\n")
-			} else if subcode == 0xd0 {
-				value := rb(buffer)
-				fmt.Printf("push(party.pointeritem.unidname==%d)\n", value)
-			} else if subcode == 0xcf {
-				value := rb(buffer)
-				fmt.Printf("push(party.pointeritem.idname==%d)\n", value)
-			} else {
-				fmt.Printf("push(party.pointeritem.???\n")
-				os.Exit(0)
-			}
-			stackDepth++
-		case 0xe9:
-			side := rb(buffer)
-			pos := rw(buffer)
-			fmt.Printf("push(maze.getWallSide(%d, [%d, %d])\n", side, pos&31, pos/32)
-			stackDepth++
-		case 0xf1: // Party
-			subcode := rb(buffer)
-			if subcode == 0xf5 { // Count items
-				t := rw(buffer)
-				flags := rb(buffer)
-				fmt.Printf("push(party.inventory.count(0x%04x(type), 0x%02x(Flags?))\n", t, flags)
-			} else { // Check party position
-				pos := (rb(buffer) << 8) | subcode
-				fmt.Printf("push(party.getPos()==[%d,%d])\n", pos&31, pos/32)
-			}
-			stackDepth++
-		case 0xf5:
-			itemtype := rb(buffer)
-			pos := rw(buffer)
-
-			if itemtype == 0xff {
-				fmt.Printf("push(maze.countItems([%d,%d], item.type=ANY))\n", pos&31, pos/32)
-			} else {
-				fmt.Printf("push(maze.countItems([%d,%d], item.type=$%02x))\n", pos&31, pos/32, itemtype)
-			}
-
-			stackDepth++
-		case 0xf7:
-			pos := rw(buffer)
-			fmt.Printf("push(maze.getWallNumber([%d, %d]))\n", pos&31, pos/32)
-			stackDepth++
-		case 0xef:
-			flag := rb(buffer)
-			fmt.Printf("push(maze.getFlag(%d))\n", flag)
-			stackDepth++
-		case 0xff:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.push_back(a==b);
-				if a == b {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()==pop())\n")
-			stackDepth--
-		case 0xfe:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.push_back(a!=b);
-				if a != b {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()!=pop())\n")
-			stackDepth--
-		case 0xfd:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.PushBack(a<b);
-				if a < b {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()<pop())\n")
-			stackDepth--
-		case 0xfc:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.PushBack(a<=b);
-				if a <= b {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()<=pop())\n")
-			stackDepth--
-		case 0xfb:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.PushBack(a>b);
-				if a > b {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()>pop())\n")
-			stackDepth--
-		case 0xfa:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.PushBack(a>=b);
-				if a >= b {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()>=pop())\n")
-			stackDepth--
-		case 0xf9:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.PushBack(a&&b);
-				aBool := a != 0
-				bBool := b != 0
-				if aBool && bBool {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()&&pop())\n")
-			stackDepth--
-		case 0xf8:
-			if !dynamicStack {
-				a := simulatedStack.Back()
-				simulatedStack.PopBack()
-				b := simulatedStack.Back()
-				simulatedStack.PopBack()
-				//simulatedStack.PushBack(a||b);
-				aBool := a != 0
-				bBool := b != 0
-				if aBool || bBool {
-					simulatedStack.PushBack(1)
-				} else {
-					simulatedStack.PushBack(0)
-				}
-			}
-			fmt.Printf("push(pop()||pop())\n")
-			stackDepth--
-		case 0x00:
-			if !dynamicStack {
-				simulatedStack.PushBack(0)
-			}
-
-			fmt.Printf("push(false/0)\n")
-			stackDepth++
-		case 0x01:
-			if !dynamicStack {
-				simulatedStack.PushBack(1)
-			}
-
-			fmt.Printf("push(true/1)\n")
-			stackDepth++
-		default:
-			if !dynamicStack {
-				simulatedStack.PushBack(int(command))
-			}
-
-			fmt.Printf("push(0x%02x)\n", command)
-			stackDepth++
-
+		token, err := parseToken(buffer, command)
+		if err != nil {
+			return nil, fmt.Errorf("%w in the conditional at 0x%04x", err, location.Offset)
 		}
+		result.Expression = append(result.Expression, token)
 	}
 
-	// Handle other cases as per the original C++ code.
-
-	falseAddress := rw(buffer)
-	fmt.Printf(";   if (!pop()) then jump 0x%04x\n", falseAddress)
-	fmt.Println(";}")
-	stackDepth--
-
-	if stackDepth != 0 {
-		fmt.Printf("CONDITIONAL PARSE ERROR: StackDepth was %d at exit!\n", stackDepth)
-		return false
-	}
+	result.FalseTarget = int(rw(buffer))
+	return result, nil
+}
 
-	// Handle the dynamicStack and simulatedStack logic.
-	if (!dynamicStack) && (simulatedStack.Size() == 1) {
-		returnValue := simulatedStack.Back()
-		simulatedStack.PopBack()
-		if returnValue == 0 {
-			fmt.Printf("; Always false\n")
-			fmt.Printf(".assert _0x%04x - * <= 255, error, \"Illegal branch\"\n", falseAddress)
-			fmt.Printf(".byte $f2, <(_0x%04x - *)\n", falseAddress)
-		} else {
-			fmt.Printf("; Always true\n")
+func parseToken(buffer *bytes.Reader, command byte) (Token, error) {
+	switch command {
+	case ExprMonsters:
+		subcode := rb(buffer)
+		if subcode == MonstersAtBlock {
+			return &CountMonstersAt{Pos: rp(buffer)}, nil
 		}
-	} else {
-		offset := getOffset(buffer)
-		endOffset := offset - 3
-		if endOffset-lastOffset < 128 {
-			fmt.Printf(".byte $%02x", offset-3-lastOffset)
-			endOffset--
-		} else {
-			fmt.Printf(".byte $ee")
+		result := &CountMonstersOfType{}
+		for subcode != 0 {
+			value := rb(buffer)
+			result.Types = append(result.Types, MonsterTypeValue{Type: int(subcode), Value: int(value)})
+			subcode = rb(buffer)
 		}
-
-		for i := lastOffset + 1; i <= endOffset; i++ {
-			fmt.Printf(",$%02x", readByteFrom(buffer, i))
+		return result, nil
+	case ExprPartyVisible:
+		return &PartyVisible{}, nil
+	case ExprRollDice:
+		rolls := rb(buffer)
+		sides := rb(buffer)
+		base := rb(buffer)
+		return &RollDice{Rolls: int(rolls), Sides: int(sides), Base: int(base)}, nil
+	case ExprRace, ExprAlignment, ExprClass:
+		return &PartyContains{Code: command, Value: int(rb(buffer))}, nil
+	case ExprTriggerFlags:
+		return &TriggerFlags{}, nil
+	case ExprPartyDirection:
+		return &PartyDirection{}, nil
+	case ExprGlobalFlag:
+		return &GlobalFlag{Flag: int(rb(buffer))}, nil
+	case ExprPointerItem:
+		subcode := rb(buffer)
+		switch subcode {
+		case PointerItemType, PointerItemAny, PointerItemValue:
+			return &PointerItem{Property: subcode}, nil
+		case PointerItemUnidentName, PointerItemIdentName:
+			return &PointerItem{Property: subcode, Value: int(rb(buffer))}, nil
 		}
-		fmt.Printf("\n", readByteFrom(buffer, endOffset))
-
-		// Previously we changed the end offset to x-3 and read from there
-		// this is why we need to step 3 bytes in the buffer. Sorry, awful solution,
-		// will be fixed later.
-		rb(buffer)
-		rb(buffer)
-		rb(buffer)
-
-		//printf("<_0x%04x,>_0x%04x", falseAddress, falseAddress);
-		if lastOffset < int64(falseAddress) {
-			fmt.Printf(".assert _0x%04x - * <= 255, error, \"Illegal branch\"\n", falseAddress)
-			fmt.Printf(".byte <(_0x%04x - *)\n", falseAddress)
-		} else {
-			fmt.Printf("Conditional branch makes a negative jump at 0x%04x\n", lastOffset)
-			return false
+		return nil, fmt.Errorf("unknown pointer item property 0x%02x", subcode)
+	case ExprWallSide:
+		side := rb(buffer)
+		return &WallSide{Side: int(side), Pos: rp(buffer)}, nil
+	case ExprParty:
+		subcode := rb(buffer)
+		if subcode == PartyItemCount {
+			t := rw(buffer)
+			return &PartyInventoryCount{ItemType: int(t), Flags: int(rb(buffer))}, nil
 		}
-		fmt.Printf("\n")
+		// check party position, the sub code is the low byte of the position
+		return &PartyAt{Pos: NewPositionFromWord(uint16(rb(buffer))<<8 | uint16(subcode))}, nil
+	case ExprItemsAt:
+		itemtype := rb(buffer)
+		return &ItemsAt{ItemType: itemtype, Pos: rp(buffer)}, nil
+	case ExprWallNumber:
+		return &WallNumber{Pos: rp(buffer)}, nil
+	case ExprMazeFlag:
+		return &MazeFlag{Flag: int(rb(buffer))}, nil
 	}
-
-	return true
+	if isOperator(command) {
+		return &Operator{Code: command}, nil
+	}
+	return &Constant{Value: command}, nil
 }
 
 func getOffset(buffer *bytes.Reader) int64 {
 	offset, _ := buffer.Seek(0, io.SeekCurrent)
 	return offset
 }
-
-func readByteFrom(buffer *bytes.Reader, i int64) any {
-	buffer.Seek(i, io.SeekStart)
-	return rb(buffer)
-}
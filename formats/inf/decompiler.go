@@ -0,0 +1,258 @@
+package inf
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// statement is a line of the pseudo-code or an if statement with its branches.
+type statement struct {
+	address   int
+	text      string
+	condition string
+	then      []statement
+	otherwise []statement
+}
+
+// function is the code from an entry point up to the next one. Triggers are the triggers starting it, called
+// tells whether a Call jumps to it.
+type function struct {
+	address   int
+	end       int
+	triggers  []Trigger
+	called    bool
+	body      []statement
+	fallsInto int
+}
+
+type decompiler struct {
+	script *Script
+	labels map[int]bool
+	starts map[int]bool
+}
+
+// Decompile writes the script as structured pseudo-code: a function for every trigger entry point and every
+// called subroutine, conditionals as if/else blocks with infix expressions, calls and returns as function calls.
+// Jumps that do not form an if/else are written as gotos to labels, the end of the script is labeled as the trigger
+// table.
+func Decompile(w io.Writer, script *Script, triggers []Trigger) error {
+	d := &decompiler{script: script, labels: make(map[int]bool), starts: make(map[int]bool)}
+	functions := d.functions(triggers)
+	for i := range functions {
+		d.decompileFunction(&functions[i])
+	}
+
+	ew := &errWriter{w: w}
+	for i, f := range functions {
+		if i > 0 {
+			ew.printf("\n")
+		}
+		for _, trigger := range f.triggers {
+			ew.printf("// trigger %d at [%d,%d] on %s, flags $%02x\n", trigger.Index, trigger.Pos.X, trigger.Pos.Y, trigger.Events(), trigger.Flags)
+		}
+		kind := "sub"
+		switch {
+		case len(f.triggers) > 0:
+			kind = "trigger"
+		case !f.called:
+			kind = "unreferenced"
+		}
+		ew.printf("%s %s() {\n", kind, Label(f.address))
+		d.writeStatements(ew, f.body, 1)
+		if f.fallsInto >= 0 {
+			ew.printf("    goto %s // falls through\n", Label(f.fallsInto))
+		}
+		// a jump to the end of the script runs into the trigger table
+		if i == len(functions)-1 && d.labels[script.End] {
+			ew.printf("%s: // trigger table\n", Label(script.End))
+		}
+		ew.printf("}\n")
+	}
+	return ew.err
+}
+
+// functions splits the script at the trigger entry points and the call targets.
+func (d *decompiler) functions(triggers []Trigger) []function {
+	starts := map[int]*function{}
+	add := func(address int) *function {
+		if f, ok := starts[address]; ok {
+			return f
+		}
+		if _, ok := d.script.IndexOf(address); !ok {
+			return nil
+		}
+		starts[address] = &function{address: address, fallsInto: -1}
+		return starts[address]
+	}
+
+	if len(d.script.Instructions) > 0 {
+		add(d.script.Offset)
+	}
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		if f := add(trigger.Address); f != nil {
+			f.triggers = append(f.triggers, trigger)
+		}
+	}
+	for _, instruction := range d.script.Instructions {
+		if call, ok := instruction.(*Call); ok {
+			if f := add(call.Target); f != nil {
+				f.called = true
+			}
+		}
+	}
+
+	functions := make([]function, 0, len(starts))
+	for _, f := range starts {
+		functions = append(functions, *f)
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].address < functions[j].address })
+	for i := range functions {
+		d.starts[functions[i].address] = true
+		functions[i].end = d.script.End
+		if i+1 < len(functions) {
+			functions[i].end = functions[i+1].address
+		}
+	}
+	return functions
+}
+
+func (d *decompiler) decompileFunction(f *function) {
+	start, _ := d.index(f.address)
+	end, _ := d.index(f.end)
+	f.body = d.block(start, end)
+
+	if end < len(d.script.Instructions) && d.reachesEnd(start, end) {
+		f.fallsInto = f.end
+	}
+}
+
+// reachesEnd reports whether the code of a function continues at its end address.
+func (d *decompiler) reachesEnd(start, end int) bool {
+	endAddress := d.address(end)
+	switch d.script.Instructions[end-1].(type) {
+	case *EndCode, *Return, *Jump:
+	default:
+		return true
+	}
+	for _, instruction := range d.script.Instructions[start:end] {
+		for _, target := range Targets(instruction) {
+			if _, isCall := instruction.(*Call); !isCall && target == endAddress {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// block structures the instructions from index start up to end. A conditional skipping forward within the block
+// becomes an if, a jump over the following code at the end of its branch an else.
+func (d *decompiler) block(start, end int) []statement {
+	var body []statement
+	for i := start; i < end; {
+		instruction := d.script.Instructions[i]
+		conditional, ok := instruction.(*Conditional)
+		if !ok {
+			body = append(body, d.simpleStatement(instruction))
+			i++
+			continue
+		}
+
+		condition, _ := infix(conditional.Expression)
+		falseIndex, ok := d.index(conditional.FalseTarget)
+		if !ok || falseIndex <= i || falseIndex > end {
+			d.labels[conditional.FalseTarget] = true
+			body = append(body, statement{address: conditional.GetOffset(),
+				text: "if (" + negate(condition) + ") goto " + Label(conditional.FalseTarget)})
+			i++
+			continue
+		}
+
+		s := statement{address: conditional.GetOffset(), condition: condition.text}
+		thenEnd, next := falseIndex, falseIndex
+		if falseIndex-1 > i {
+			if jump, ok := d.script.Instructions[falseIndex-1].(*Jump); ok {
+				if jumpIndex, ok := d.index(jump.Target); ok && jumpIndex > falseIndex && jumpIndex <= end {
+					thenEnd, next = falseIndex-1, jumpIndex
+					s.otherwise = d.block(falseIndex, jumpIndex)
+				}
+			}
+		}
+		s.then = d.block(i+1, thenEnd)
+		body = append(body, s)
+		i = next
+	}
+	return body
+}
+
+func (d *decompiler) simpleStatement(instruction Instruction) statement {
+	s := statement{address: instruction.GetOffset()}
+	switch i := instruction.(type) {
+	case *Jump:
+		d.labels[i.Target] = true
+		s.text = "goto " + Label(i.Target)
+	case *Call:
+		s.text = Label(i.Target) + "()"
+	case *Return:
+		s.text = "return"
+	case *EndCode:
+		s.text = "end"
+	case *Message:
+		s.text = "message(" + strconv.Quote(i.Text) + ", color=" + strconv.Itoa(i.Color) + ")"
+	case *RawBytes:
+		s.text = formatBytes(i.Bytes)
+	default:
+		var operands []string
+		for _, op := range instructionOperands(instruction) {
+			operands = append(operands, op.name+"="+op.format())
+		}
+		s.text = lowerFirst(instruction.Mnemonic()) + "(" + strings.Join(operands, ", ") + ")"
+	}
+	return s
+}
+
+func (d *decompiler) writeStatements(ew *errWriter, statements []statement, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, s := range statements {
+		// the label of a function start is its name
+		if d.labels[s.address] && !d.starts[s.address] {
+			ew.printf("%s%s:\n", strings.Repeat("    ", depth-1), Label(s.address))
+		}
+		if s.text != "" {
+			ew.printf("%s%s\n", indent, s.text)
+			continue
+		}
+
+		ew.printf("%sif (%s) {\n", indent, s.condition)
+		d.writeStatements(ew, s.then, depth+1)
+		if len(s.otherwise) > 0 {
+			ew.printf("%s} else {\n", indent)
+			d.writeStatements(ew, s.otherwise, depth+1)
+		}
+		ew.printf("%s}\n", indent)
+	}
+}
+
+// index returns the index of the instruction at an address, the end of the script has the index after the last
+// instruction.
+func (d *decompiler) index(address int) (int, bool) {
+	if address == d.script.End {
+		return len(d.script.Instructions), true
+	}
+	return d.script.IndexOf(address)
+}
+
+func (d *decompiler) address(index int) int {
+	if index == len(d.script.Instructions) {
+		return d.script.End
+	}
+	return d.script.Instructions[index].GetOffset()
+}
+
+func lowerFirst(text string) string {
+	runes := []rune(text)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
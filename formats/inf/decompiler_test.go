@@ -0,0 +1,90 @@
+package inf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const decompileSource = `.trigger 0, [1,1], $08, _first
+.trigger 1, [2,2], $10, _second
+.org $0010
+_first: Conditional { mazeFlag(1) } falseTarget=_else
+Message text="then" color=1
+Jump target=_after
+_else: Message text="else" color=2
+_after: Conditional { mazeFlag(2) } falseTarget=_end
+Message text="flag 2" color=3
+_second: Message text="second" color=4
+Conditional { mazeFlag(3) } falseTarget=_skip
+Jump target=_end
+_skip: EndCode
+_end:
+`
+
+func decompile(t *testing.T, source string) string {
+	t.Helper()
+	script, triggers, err := Assemble(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	if err := Decompile(output, script, triggers); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestDecompile(t *testing.T) {
+	output := decompile(t, decompileSource)
+	for _, want := range []string{
+		`trigger _0x0010() {
+    if (mazeFlag(1)) {
+        message("then", color=1)
+    } else {
+        message("else", color=2)
+    }
+    if (!mazeFlag(2)) goto _0x004d
+    message("flag 2", color=3)
+    goto _0x0039 // falls through
+}`,
+		`// trigger 1 at [2,2] on partyLeave, flags $10
+trigger _0x0039() {
+    message("second", color=4)`,
+		`_0x004d: // trigger table
+}`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("%s\nmissing in\n%s", want, output)
+		}
+	}
+
+	// every label of a goto is printed
+	for _, line := range strings.Split(output, "\n") {
+		if _, label, ok := strings.Cut(line, "goto "); ok {
+			label, _, _ = strings.Cut(label, " ")
+			if !strings.Contains(output, "\n"+label+":") && !strings.Contains(output, " "+label+"() {") {
+				t.Errorf("label %s of %q missing", label, line)
+			}
+		}
+	}
+}
+
+func TestDecompileWithoutJumpToEnd(t *testing.T) {
+	output := decompile(t, `.trigger 0, [1,1], $08, _first
+.org $0010
+_first: Conditional { mazeFlag(1) } falseTarget=_end
+Message text="then" color=1
+_end: EndCode
+`)
+	want := `trigger _0x0010() {
+    if (mazeFlag(1)) {
+        message("then", color=1)
+    }
+    end
+}
+`
+	if !strings.HasSuffix(output, want) || strings.Contains(output, "trigger table") {
+		t.Errorf("decompiled as\n%s", output)
+	}
+}
@@ -0,0 +1,132 @@
+package inf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Listing is a disassembled script, the JSON output of the disassembler.
+type Listing struct {
+	ScriptOffset int                  `json:"scriptOffset"`
+	End          int                  `json:"end"`
+	Triggers     []ListingTrigger     `json:"triggers"`
+	Instructions []ListingInstruction `json:"instructions"`
+}
+
+type ListingTrigger struct {
+	Index          int          `json:"index"`
+	Pos            Position     `json:"pos"`
+	Flags          int          `json:"flags"`
+	Events         TriggerEvent `json:"events"`
+	CollapsedFlags int          `json:"collapsedFlags"`
+	Address        int          `json:"address"`
+	Label          string       `json:"label"`
+}
+
+type ListingInstruction struct {
+	Offset   int            `json:"offset"`
+	Label    string         `json:"label"`
+	Opcode   int            `json:"opcode"`
+	Mnemonic string         `json:"mnemonic"`
+	Operands map[string]any `json:"operands,omitempty"`
+	Text     string         `json:"text"`
+	Bytes    string         `json:"bytes"`
+	Triggers []int          `json:"triggers,omitempty"`
+}
+
+// SortTriggersByIndex returns the triggers in the order of the trigger table of the file.
+func SortTriggersByIndex(triggers []Trigger) []Trigger {
+	sorted := append([]Trigger(nil), triggers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+	return sorted
+}
+
+func NewListing(script *Script, triggers []Trigger) *Listing {
+	listing := &Listing{ScriptOffset: script.Offset, End: script.End}
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		listing.Triggers = append(listing.Triggers, ListingTrigger{Index: trigger.Index, Pos: trigger.Pos, Flags: trigger.Flags,
+			Events: trigger.Events(), CollapsedFlags: trigger.CollapseFlags, Address: trigger.Address, Label: Label(trigger.Address)})
+	}
+
+	for _, instruction := range script.Instructions {
+		item := ListingInstruction{
+			Offset:   instruction.GetOffset(),
+			Label:    Label(instruction.GetOffset()),
+			Opcode:   int(instruction.Opcode()),
+			Mnemonic: instruction.Mnemonic(),
+			Text:     FormatInstruction(instruction),
+			Bytes:    fmt.Sprintf("%x", instruction.Encode()),
+		}
+		if operands := instructionOperands(instruction); len(operands) > 0 {
+			item.Operands = make(map[string]any)
+			for _, op := range operands {
+				name := op.name
+				if name == "" {
+					name = "expression"
+				}
+				item.Operands[name] = op.operandValue()
+			}
+		}
+		for _, trigger := range script.GetTriggers(instruction.GetOffset()) {
+			item.Triggers = append(item.Triggers, trigger.Index)
+		}
+		listing.Instructions = append(listing.Instructions, item)
+	}
+	return listing
+}
+
+// WriteText writes the script in the assembly syntax read by the assembler: the trigger table as .trigger
+// directives, then every instruction after its label. Entry points are preceded by the triggers starting them.
+func WriteText(w io.Writer, script *Script, triggers []Trigger) error {
+	ew := &errWriter{w: w}
+	ew.printf("; Trigger table: index, position, flags, address\n")
+	for _, trigger := range SortTriggersByIndex(triggers) {
+		ew.printf(".trigger %d, [%d,%d], $%02x, %s ; on %s, collapsed flags %d\n",
+			trigger.Index, trigger.Pos.X, trigger.Pos.Y, trigger.Flags, Label(trigger.Address), trigger.Events(), trigger.CollapseFlags)
+	}
+
+	ew.printf("\n.org $%04x\n", script.Offset)
+	for _, instruction := range script.Instructions {
+		offset := instruction.GetOffset()
+		if referencing := script.GetTriggers(offset); len(referencing) > 0 {
+			ew.printf("\n; --------------------------------------------------------------------\n")
+			for _, trigger := range referencing {
+				ew.printf("; Referenced by trigger $%02x. Pos:[%d,%d] Flags: $%02x (%s)\n", trigger.Index, trigger.Pos.X, trigger.Pos.Y, trigger.Flags, trigger.Events())
+			}
+			ew.printf("; --------------------------------------------------------------------\n")
+		}
+		ew.printf("%s: %s\n", Label(offset), FormatInstruction(instruction))
+		for _, target := range Targets(instruction) {
+			if _, ok := script.IndexOf(target); !ok {
+				ew.printf("; warning: %s is not the address of an instruction\n", Label(target))
+			}
+		}
+	}
+	ew.printf("; trigger table at $%04x\n", script.End)
+	return ew.err
+}
+
+// Targets returns the addresses an instruction may continue at besides the next instruction.
+func Targets(instruction Instruction) []int {
+	switch i := instruction.(type) {
+	case *Jump:
+		return []int{i.Target}
+	case *Call:
+		return []int{i.Target}
+	case *Conditional:
+		return []int{i.FalseTarget}
+	}
+	return nil
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
@@ -0,0 +1,276 @@
+package inf
+
+// Codes of the conditional expressions. Every other byte pushes itself as a constant.
+const (
+	ExprMonsters       byte = 0xf3
+	ExprPartyVisible   byte = 0xda
+	ExprRollDice       byte = 0xdb
+	ExprRace           byte = 0xdd
+	ExprAlignment      byte = 0xce
+	ExprClass          byte = 0xdc
+	ExprTriggerFlags   byte = 0xe0
+	ExprPartyDirection byte = 0xed
+	ExprGlobalFlag     byte = 0xf0
+	ExprPointerItem    byte = 0xe7
+	ExprWallSide       byte = 0xe9
+	ExprParty          byte = 0xf1
+	ExprItemsAt        byte = 0xf5
+	ExprWallNumber     byte = 0xf7
+	ExprMazeFlag       byte = 0xef
+
+	ExprEqual          byte = 0xff
+	ExprNotEqual       byte = 0xfe
+	ExprLess           byte = 0xfd
+	ExprLessOrEqual    byte = 0xfc
+	ExprGreater        byte = 0xfb
+	ExprGreaterOrEqual byte = 0xfa
+	ExprAnd            byte = 0xf9
+	ExprOr             byte = 0xf8
+
+	// sub codes of ExprMonsters, ExprParty and ExprPointerItem
+	MonstersAtBlock        byte = 0xff
+	PartyItemCount         byte = 0xf5
+	PointerItemType        byte = 0xe1
+	PointerItemAny         byte = 0xf5
+	PointerItemValue       byte = 0xf6
+	PointerItemUnidentName byte = 0xd0
+	PointerItemIdentName   byte = 0xcf
+
+	// ItemsAt item type matching any item
+	AnyItemType byte = 0xff
+)
+
+// Token is an element of a postfix conditional expression. Push returns the number of values it pushes, the
+// operators pop two values and push the result.
+type Token interface {
+	Encode() []byte
+	Push() int
+}
+
+// Constant pushes its code, every byte that is not another token is a constant.
+type Constant struct {
+	Value byte
+}
+
+func (t *Constant) Encode() []byte { return []byte{t.Value} }
+func (t *Constant) Push() int      { return 1 }
+
+// Operator compares or combines the two topmost values.
+type Operator struct {
+	Code byte
+}
+
+var operatorSymbols = map[byte]string{
+	ExprEqual: "==", ExprNotEqual: "!=", ExprLess: "<", ExprLessOrEqual: "<=",
+	ExprGreater: ">", ExprGreaterOrEqual: ">=", ExprAnd: "&&", ExprOr: "||",
+}
+
+// isTokenCode reports whether a byte starts a token other than a constant.
+func isTokenCode(code byte) bool {
+	switch code {
+	case ExprMonsters, ExprPartyVisible, ExprRollDice, ExprRace, ExprAlignment, ExprClass, ExprTriggerFlags, ExprPartyDirection,
+		ExprGlobalFlag, ExprPointerItem, ExprWallSide, ExprParty, ExprItemsAt, ExprWallNumber, ExprMazeFlag, OpConditional:
+		return true
+	}
+	return isOperator(code)
+}
+
+func isOperator(code byte) bool {
+	_, ok := operatorSymbols[code]
+	return ok
+}
+
+func (t *Operator) Encode() []byte { return []byte{t.Code} }
+func (t *Operator) Push() int      { return -1 }
+
+// Symbol returns the Go operator of the code, eg. "<=".
+func (t *Operator) Symbol() string {
+	return operatorSymbols[t.Code]
+}
+
+// Apply evaluates the operator with a, the last pushed value, and b, the value below it.
+func (t *Operator) Apply(a, b int) int {
+	var result bool
+	switch t.Code {
+	case ExprEqual:
+		result = a == b
+	case ExprNotEqual:
+		result = a != b
+	case ExprLess:
+		result = a < b
+	case ExprLessOrEqual:
+		result = a <= b
+	case ExprGreater:
+		result = a > b
+	case ExprGreaterOrEqual:
+		result = a >= b
+	case ExprAnd:
+		result = a != 0 && b != 0
+	case ExprOr:
+		result = a != 0 || b != 0
+	}
+	if result {
+		return 1
+	}
+	return 0
+}
+
+// CountMonstersAt pushes the number of monsters at a block.
+type CountMonstersAt struct {
+	Pos Position
+}
+
+func (t *CountMonstersAt) Encode() []byte {
+	return appendPosition([]byte{ExprMonsters, MonstersAtBlock}, t.Pos)
+}
+func (t *CountMonstersAt) Push() int { return 1 }
+
+// MonsterTypeValue is a monster type and the value pushed after its count.
+type MonsterTypeValue struct {
+	Type  int
+	Value int
+}
+
+// CountMonstersOfType pushes the number of monsters of each type followed by the value of the pair.
+type CountMonstersOfType struct {
+	Types []MonsterTypeValue
+}
+
+func (t *CountMonstersOfType) Encode() []byte {
+	data := []byte{ExprMonsters}
+	for _, pair := range t.Types {
+		data = append(data, byte(pair.Type), byte(pair.Value))
+	}
+	return append(data, 0)
+}
+func (t *CountMonstersOfType) Push() int { return 2 * len(t.Types) }
+
+type PartyVisible struct{}
+
+func (t *PartyVisible) Encode() []byte { return []byte{ExprPartyVisible} }
+func (t *PartyVisible) Push() int      { return 1 }
+
+type RollDice struct {
+	Rolls int
+	Sides int
+	Base  int
+}
+
+func (t *RollDice) Encode() []byte {
+	return []byte{ExprRollDice, byte(t.Rolls), byte(t.Sides), byte(t.Base)}
+}
+func (t *RollDice) Push() int { return 1 }
+
+// PartyContains pushes whether a party member has the race, alignment or class of Code.
+type PartyContains struct {
+	Code  byte
+	Value int
+}
+
+func (t *PartyContains) Encode() []byte { return []byte{t.Code, byte(t.Value)} }
+func (t *PartyContains) Push() int      { return 1 }
+
+type TriggerFlags struct{}
+
+func (t *TriggerFlags) Encode() []byte { return []byte{ExprTriggerFlags} }
+func (t *TriggerFlags) Push() int      { return 1 }
+
+type PartyDirection struct{}
+
+func (t *PartyDirection) Encode() []byte { return []byte{ExprPartyDirection} }
+func (t *PartyDirection) Push() int      { return 1 }
+
+type GlobalFlag struct {
+	Flag int
+}
+
+func (t *GlobalFlag) Encode() []byte { return []byte{ExprGlobalFlag, byte(t.Flag)} }
+func (t *GlobalFlag) Push() int      { return 1 }
+
+type MazeFlag struct {
+	Flag int
+}
+
+func (t *MazeFlag) Encode() []byte { return []byte{ExprMazeFlag, byte(t.Flag)} }
+func (t *MazeFlag) Push() int      { return 1 }
+
+// PointerItem pushes a property of the item in the mouse pointer. Value is the name compared by
+// PointerItemUnidentName and PointerItemIdentName.
+type PointerItem struct {
+	Property byte
+	Value    int
+}
+
+func (t *PointerItem) Encode() []byte {
+	data := []byte{ExprPointerItem, t.Property}
+	if t.Property == PointerItemUnidentName || t.Property == PointerItemIdentName {
+		data = append(data, byte(t.Value))
+	}
+	return data
+}
+func (t *PointerItem) Push() int { return 1 }
+
+type WallSide struct {
+	Side int
+	Pos  Position
+}
+
+func (t *WallSide) Encode() []byte { return appendPosition([]byte{ExprWallSide, byte(t.Side)}, t.Pos) }
+func (t *WallSide) Push() int      { return 1 }
+
+// PartyInventoryCount pushes the number of items of a type in the inventories of the party.
+type PartyInventoryCount struct {
+	ItemType int
+	Flags    int
+}
+
+func (t *PartyInventoryCount) Encode() []byte {
+	return append(appendWord([]byte{ExprParty, PartyItemCount}, uint16(t.ItemType)), byte(t.Flags))
+}
+func (t *PartyInventoryCount) Push() int { return 1 }
+
+// PartyAt pushes whether the party stands on a block.
+type PartyAt struct {
+	Pos Position
+}
+
+func (t *PartyAt) Encode() []byte { return appendPosition([]byte{ExprParty}, t.Pos) }
+func (t *PartyAt) Push() int      { return 1 }
+
+// ItemsAt pushes the number of items of a type at a block, ItemType AnyItemType counts all items.
+type ItemsAt struct {
+	ItemType byte
+	Pos      Position
+}
+
+func (t *ItemsAt) Encode() []byte { return appendPosition([]byte{ExprItemsAt, t.ItemType}, t.Pos) }
+func (t *ItemsAt) Push() int      { return 1 }
+
+type WallNumber struct {
+	Pos Position
+}
+
+func (t *WallNumber) Encode() []byte { return appendPosition([]byte{ExprWallNumber}, t.Pos) }
+func (t *WallNumber) Push() int      { return 1 }
+
+// IsConstant reports whether the expression only consists of constants and operators, its result is known
+// without running the game.
+func IsConstant(expression []Token) bool {
+	for _, token := range expression {
+		switch token.(type) {
+		case *Constant, *Operator:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// StackDepth returns the number of values left by the expression, a valid condition leaves one.
+func StackDepth(expression []Token) int {
+	depth := 0
+	for _, token := range expression {
+		depth += token.Push()
+	}
+	return depth
+}
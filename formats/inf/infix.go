@@ -0,0 +1,162 @@
+package inf
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Names of the race, alignment and class codes in the pseudo-code, other codes are printed as numbers.
+var raceNames = map[int]string{0: "Human", 1: "Elf", 2: "HalfElf", 3: "Dwarf", 4: "Gnome", 5: "Halfling"}
+var alignmentNames = map[int]string{0: "LawfulGood", 1: "NeutralGood", 2: "ChaoticGood", 3: "LawfulNeutral", 4: "TrueNeutral",
+	5: "ChaoticNeutral", 6: "LawfulEvil", 7: "NeutralEvil", 8: "ChaoticEvil"}
+var classNames = map[int]string{0: "Fighter", 1: "Ranger", 2: "Paladin", 3: "Mage", 4: "Cleric", 5: "Thief"}
+
+// Precedences of the infix operators, atoms bind strongest.
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceCompare
+	precedenceAtom
+)
+
+// mirroredSymbols turns "top op below" into "below op top" for the ordered comparisons.
+var mirroredSymbols = map[byte]string{ExprLess: ">", ExprLessOrEqual: ">=", ExprGreater: "<", ExprGreaterOrEqual: "<="}
+
+// invertedSymbols negates a comparison.
+var invertedSymbols = map[string]string{"==": "!=", "!=": "==", "<": ">=", ">=": "<", ">": "<=", "<=": ">"}
+
+// infixNode is an infix expression, comparisons keep their operands to be negated.
+type infixNode struct {
+	text        string
+	precedence  int
+	left, right string
+	symbol      string
+}
+
+// FormatInfix returns a postfix expression as an infix expression, eg. `partyAt(10,15) && globalFlag(3)`.
+// Operands are written in push order, the comparisons are mirrored accordingly. Expressions that do not leave
+// exactly one value are returned in the postfix syntax and false.
+func FormatInfix(expression []Token) (string, bool) {
+	node, ok := infix(expression)
+	return node.text, ok
+}
+
+func infix(expression []Token) (infixNode, bool) {
+	var stack []infixNode
+	for _, token := range expression {
+		operator, ok := token.(*Operator)
+		if !ok {
+			stack = append(stack, atomNodes(token)...)
+			continue
+		}
+		if len(stack) < 2 {
+			return infixNode{text: FormatExpression(expression), precedence: precedenceAtom}, false
+		}
+		top, below := stack[len(stack)-1], stack[len(stack)-2]
+		stack = append(stack[:len(stack)-2], combine(operator, below, top))
+	}
+	if len(stack) != 1 {
+		return infixNode{text: FormatExpression(expression), precedence: precedenceAtom}, false
+	}
+	return stack[0], true
+}
+
+func combine(operator *Operator, left, right infixNode) infixNode {
+	symbol, precedence := operator.Symbol(), precedenceCompare
+	switch operator.Code {
+	case ExprAnd:
+		precedence = precedenceAnd
+	case ExprOr:
+		precedence = precedenceOr
+	}
+	if mirrored, ok := mirroredSymbols[operator.Code]; ok {
+		symbol = mirrored
+	}
+
+	// && and || are associative, a comparison of comparisons needs parentheses on both sides
+	leftText, rightText := left.text, right.text
+	if left.precedence < precedence || left.precedence == precedenceCompare && precedence == precedenceCompare {
+		leftText = "(" + leftText + ")"
+	}
+	if right.precedence < precedence || right.precedence == precedence && precedence == precedenceCompare {
+		rightText = "(" + rightText + ")"
+	}
+	return infixNode{text: leftText + " " + symbol + " " + rightText, precedence: precedence, left: leftText, right: rightText, symbol: symbol}
+}
+
+// atomNodes returns the values pushed by a token other than an operator.
+func atomNodes(token Token) []infixNode {
+	atom := func(format string, args ...any) []infixNode {
+		return []infixNode{{text: fmt.Sprintf(format, args...), precedence: precedenceAtom}}
+	}
+
+	switch t := token.(type) {
+	case *Constant:
+		return atom("%d", t.Value)
+	case *CountMonstersAt:
+		return atom("monstersAt(%s)", infixPosition(t.Pos))
+	case *CountMonstersOfType:
+		var nodes []infixNode
+		for _, pair := range t.Types {
+			nodes = append(nodes, atom("monstersOfType(%d)", pair.Type)[0], atom("%d", pair.Value)[0])
+		}
+		return nodes
+	case *PartyVisible:
+		return atom("party.visible")
+	case *RollDice:
+		if t.Base == 0 {
+			return atom("roll(%dd%d)", t.Rolls, t.Sides)
+		}
+		return atom("roll(%dd%d+%d)", t.Rolls, t.Sides, t.Base)
+	case *PartyContains:
+		names := map[byte]map[int]string{ExprRace: raceNames, ExprAlignment: alignmentNames, ExprClass: classNames}[t.Code]
+		value, ok := names[t.Value]
+		if !ok {
+			value = strconv.Itoa(t.Value)
+		}
+		return atom("party.contains(%s=%s)", partyContainsNames[t.Code], value)
+	case *TriggerFlags:
+		return atom("triggerFlags")
+	case *PartyDirection:
+		return atom("party.direction")
+	case *GlobalFlag:
+		return atom("globalFlag(%d)", t.Flag)
+	case *MazeFlag:
+		return atom("mazeFlag(%d)", t.Flag)
+	case *PointerItem:
+		property := operand{value: &t.Property, symbols: pointerItemSymbols}.format()
+		if t.Property == PointerItemUnidentName || t.Property == PointerItemIdentName {
+			return atom("pointerItem.%s(%d)", property, t.Value)
+		}
+		return atom("pointerItem.%s", property)
+	case *WallSide:
+		return atom("wallSide(%s,side=%d)", infixPosition(t.Pos), t.Side)
+	case *PartyInventoryCount:
+		return atom("party.count(item=%d,flags=%d)", t.ItemType, t.Flags)
+	case *PartyAt:
+		return atom("partyAt(%s)", infixPosition(t.Pos))
+	case *ItemsAt:
+		if t.ItemType == AnyItemType {
+			return atom("itemsAt(%s)", infixPosition(t.Pos))
+		}
+		return atom("itemsAt(%s,type=%d)", infixPosition(t.Pos), t.ItemType)
+	case *WallNumber:
+		return atom("wallNumber(%s)", infixPosition(t.Pos))
+	}
+	return atom("%s", FormatToken(token))
+}
+
+func infixPosition(position Position) string {
+	return fmt.Sprintf("%d,%d", position.X, position.Y)
+}
+
+// negate returns the negation of an infix expression, comparisons are inverted.
+func negate(node infixNode) string {
+	switch {
+	case node.precedence == precedenceCompare:
+		return node.left + " " + invertedSymbols[node.symbol] + " " + node.right
+	case node.precedence == precedenceAtom:
+		return "!" + node.text
+	}
+	return "!(" + node.text + ")"
+}
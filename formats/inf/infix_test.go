@@ -0,0 +1,69 @@
+package inf
+
+import "testing"
+
+var infixTests = []struct {
+	postfix string
+	infix   string
+	negated string
+}{
+	{"{ 5 }", "5", "!5"},
+	{"{ partyAt([5,5]) }", "partyAt(5,5)", "!partyAt(5,5)"},
+	{"{ triggerFlags 1 == }", "triggerFlags == 1", "triggerFlags != 1"},
+	{"{ globalFlag(2) 0 != }", "globalFlag(2) != 0", "globalFlag(2) == 0"},
+	{"{ partyDirection 2 < }", "party.direction > 2", "party.direction <= 2"},
+	{"{ partyDirection 2 <= }", "party.direction >= 2", "party.direction < 2"},
+	{"{ partyDirection 2 > }", "party.direction < 2", "party.direction >= 2"},
+	{"{ partyDirection 2 >= }", "party.direction <= 2", "party.direction > 2"},
+	{"{ partyAt([5,5]) globalFlag(3) && }", "partyAt(5,5) && globalFlag(3)", "!(partyAt(5,5) && globalFlag(3))"},
+	{"{ partyAt([5,5]) triggerFlags 1 == && }", "partyAt(5,5) && triggerFlags == 1", "!(partyAt(5,5) && triggerFlags == 1)"},
+	{"{ mazeFlag(1) mazeFlag(2) && mazeFlag(3) && }", "mazeFlag(1) && mazeFlag(2) && mazeFlag(3)",
+		"!(mazeFlag(1) && mazeFlag(2) && mazeFlag(3))"},
+	{"{ mazeFlag(1) mazeFlag(2) mazeFlag(3) && && }", "mazeFlag(1) && mazeFlag(2) && mazeFlag(3)",
+		"!(mazeFlag(1) && mazeFlag(2) && mazeFlag(3))"},
+	{"{ mazeFlag(1) mazeFlag(2) || mazeFlag(3) && }", "(mazeFlag(1) || mazeFlag(2)) && mazeFlag(3)",
+		"!((mazeFlag(1) || mazeFlag(2)) && mazeFlag(3))"},
+	{"{ mazeFlag(1) mazeFlag(2) mazeFlag(3) && || }", "mazeFlag(1) || mazeFlag(2) && mazeFlag(3)",
+		"!(mazeFlag(1) || mazeFlag(2) && mazeFlag(3))"},
+	{"{ mazeFlag(1) mazeFlag(2) == 1 == }", "(mazeFlag(1) == mazeFlag(2)) == 1", "(mazeFlag(1) == mazeFlag(2)) != 1"},
+	{"{ 1 mazeFlag(1) mazeFlag(2) == == }", "1 == (mazeFlag(1) == mazeFlag(2))", "1 != (mazeFlag(1) == mazeFlag(2))"},
+	{"{ monstersOfType(2:3,4:5) }", "", ""},
+}
+
+func TestFormatInfix(t *testing.T) {
+	for _, test := range infixTests {
+		t.Run(test.postfix, func(t *testing.T) {
+			expression, err := parseExpression(test.postfix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, ok := FormatInfix(expression)
+			if test.infix == "" {
+				if ok {
+					t.Errorf("unbalanced expression formatted as %s", text)
+				}
+				return
+			}
+			if !ok || text != test.infix {
+				t.Errorf("%s, %v instead of %s", text, ok, test.infix)
+			}
+			node, _ := infix(expression)
+			if negated := negate(node); negated != test.negated {
+				t.Errorf("negated %s instead of %s", negated, test.negated)
+			}
+		})
+	}
+}
+
+func TestFormatInfixUnbalanced(t *testing.T) {
+	for _, postfix := range []string{"{ 1 2 }", "{ 1 == }", "{ && }"} {
+		expression, err := parseExpression(postfix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, ok := FormatInfix(expression)
+		if ok || text != FormatExpression(expression) {
+			t.Errorf("%s formatted as %s, %v instead of the postfix syntax", postfix, text, ok)
+		}
+	}
+}
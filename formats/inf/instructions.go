@@ -0,0 +1,534 @@
+package inf
+
+import (
+	"encoding/binary"
+)
+
+// Opcodes of the trigger scripts
+const (
+	OpSetWall               byte = 0xff
+	OpChangeWall            byte = 0xfe
+	OpOpenDoor              byte = 0xfd
+	OpCloseDoor             byte = 0xfc
+	OpCreateMonster         byte = 0xfb
+	OpTeleport              byte = 0xfa
+	OpStealSmallItems       byte = 0xf9
+	OpMessage               byte = 0xf8
+	OpSetFlag               byte = 0xf7
+	OpSound                 byte = 0xf6
+	OpClearFlag             byte = 0xf5
+	OpHeal                  byte = 0xf4
+	OpDamage                byte = 0xf3
+	OpJump                  byte = 0xf2
+	OpEndCode               byte = 0xf1
+	OpReturn                byte = 0xf0
+	OpCall                  byte = 0xef
+	OpConditional           byte = 0xee
+	OpItemConsume           byte = 0xed
+	OpChangeLevel           byte = 0xec
+	OpGiveXP                byte = 0xeb
+	OpNewItem               byte = 0xea
+	OpLauncher              byte = 0xe9
+	OpTurn                  byte = 0xe8
+	OpIdentAllItems         byte = 0xe7
+	OpEncounters            byte = 0xe6
+	OpWait                  byte = 0xe5
+	OpUpdateScreen          byte = 0xe4
+	OpTextMenu              byte = 0xe3
+	OpSpecialWindowPictures byte = 0xe2
+)
+
+// Sub types selecting the operands of SetWall, ChangeWall, Teleport, Turn, SetFlag, ClearFlag, ItemConsume,
+// GiveXP, ChangeLevel and Launcher
+const (
+	WallAllSides       byte = 0xf7
+	WallOneSide        byte = 0xe9
+	WallOpenDoor       byte = 0xea
+	WallPartyDirection byte = 0xed
+
+	TargetParty   byte = 0xe8
+	TargetMonster byte = 0xf3
+	TargetItem    byte = 0xf5
+
+	TurnParty byte = 0xf1
+	TurnItem  byte = 0xf5
+
+	FlagMaze     byte = 0xef
+	FlagGlobal   byte = 0xf0
+	FlagMonster  byte = 0xf3
+	FlagEvent    byte = 0xe4
+	FlagSaveRest byte = 0xd1
+
+	ConsumeAtPointer  byte = 0xff
+	ConsumeAnyAtBlock byte = 0xfe
+
+	ExperienceParty byte = 0xe2
+
+	LevelChange   byte = 0xe5
+	LauncherSpell byte = 0xdf
+	DamageAll     byte = 0xff
+	StealRandom   byte = 0xff
+)
+
+// Instruction is a decoded script instruction. Encode returns its bytecode including the opcode, decoding and
+// encoding an instruction reproduces the original bytes.
+type Instruction interface {
+	GetOffset() int
+	Opcode() byte
+	Mnemonic() string
+	Encode() []byte
+}
+
+// Location is the address of an instruction in the INF data, the addresses used by triggers, jumps and calls.
+type Location struct {
+	Offset int
+}
+
+func (l Location) GetOffset() int {
+	return l.Offset
+}
+
+func (l *Location) setOffset(offset int) {
+	l.Offset = offset
+}
+
+// NewPositionFromWord decodes a block position of the bytecode, y*32+x.
+func NewPositionFromWord(word uint16) Position {
+	return Position{X: int(word & 31), Y: int(word >> 5)}
+}
+
+func (p Position) Word() uint16 {
+	return uint16(p.Y*32 + p.X)
+}
+
+// PositionNone is the position word of NewItem for the mouse pointer.
+const PositionNone uint16 = 0xffff
+
+// IsNone reports whether the position is PositionNone.
+func (p Position) IsNone() bool {
+	return p.Word() == PositionNone
+}
+
+func appendWord(data []byte, word uint16) []byte {
+	return binary.LittleEndian.AppendUint16(data, word)
+}
+
+func appendPosition(data []byte, position Position) []byte {
+	return appendWord(data, position.Word())
+}
+
+// SetWall sets the wall mapping of all sides or one side of a block, or changes the party direction.
+type SetWall struct {
+	Location
+	Type      byte
+	Pos       Position
+	Side      int
+	To        int
+	Direction int
+}
+
+func (i *SetWall) Opcode() byte     { return OpSetWall }
+func (i *SetWall) Mnemonic() string { return "SetWall" }
+func (i *SetWall) Encode() []byte {
+	data := []byte{OpSetWall, i.Type}
+	switch i.Type {
+	case WallAllSides:
+		data = append(appendPosition(data, i.Pos), byte(i.To))
+	case WallOneSide:
+		data = append(appendPosition(data, i.Pos), byte(i.Side), byte(i.To))
+	case WallPartyDirection:
+		data = append(data, byte(i.Direction))
+	}
+	return data
+}
+
+// ChangeWall toggles the walls of a block between two wall mappings, or opens a door.
+type ChangeWall struct {
+	Location
+	Type byte
+	Pos  Position
+	Side int
+	To   int
+	From int
+}
+
+func (i *ChangeWall) Opcode() byte     { return OpChangeWall }
+func (i *ChangeWall) Mnemonic() string { return "ChangeWall" }
+func (i *ChangeWall) Encode() []byte {
+	data := []byte{OpChangeWall, i.Type}
+	switch i.Type {
+	case WallAllSides:
+		data = append(appendPosition(data, i.Pos), byte(i.To), byte(i.From))
+	case WallOneSide:
+		data = append(appendPosition(data, i.Pos), byte(i.Side), byte(i.To), byte(i.From))
+	case WallOpenDoor:
+		data = appendPosition(data, i.Pos)
+	}
+	return data
+}
+
+type OpenDoor struct {
+	Location
+	Pos Position
+}
+
+func (i *OpenDoor) Opcode() byte     { return OpOpenDoor }
+func (i *OpenDoor) Mnemonic() string { return "OpenDoor" }
+func (i *OpenDoor) Encode() []byte   { return appendPosition([]byte{OpOpenDoor}, i.Pos) }
+
+type CloseDoor struct {
+	Location
+	Pos Position
+}
+
+func (i *CloseDoor) Opcode() byte     { return OpCloseDoor }
+func (i *CloseDoor) Mnemonic() string { return "CloseDoor" }
+func (i *CloseDoor) Encode() []byte   { return appendPosition([]byte{OpCloseDoor}, i.Pos) }
+
+type CreateMonster struct {
+	Location
+	Index     int
+	MoveTime  int
+	Pos       Position
+	SubPos    int
+	Direction int
+	Type      int
+	Picture   int
+	Phase     int
+	Pause     int
+	Pocket    int
+	Weapon    int
+}
+
+func (i *CreateMonster) Opcode() byte     { return OpCreateMonster }
+func (i *CreateMonster) Mnemonic() string { return "CreateMonster" }
+func (i *CreateMonster) Encode() []byte {
+	data := appendPosition([]byte{OpCreateMonster, byte(i.Index), byte(i.MoveTime)}, i.Pos)
+	data = append(data, byte(i.SubPos), byte(i.Direction), byte(i.Type), byte(i.Picture), byte(i.Phase), byte(i.Pause))
+	data = appendWord(data, uint16(i.Pocket))
+	return appendWord(data, uint16(i.Weapon))
+}
+
+// Teleport moves the party, the monsters or the items of Source to Destination. Source is unused for the party.
+type Teleport struct {
+	Location
+	Type        byte
+	Source      Position
+	Destination Position
+}
+
+func (i *Teleport) Opcode() byte     { return OpTeleport }
+func (i *Teleport) Mnemonic() string { return "Teleport" }
+func (i *Teleport) Encode() []byte {
+	return appendPosition(appendPosition([]byte{OpTeleport, i.Type}, i.Source), i.Destination)
+}
+
+type StealSmallItems struct {
+	Location
+	Whom   int
+	Pos    Position
+	SubPos int
+}
+
+func (i *StealSmallItems) Opcode() byte     { return OpStealSmallItems }
+func (i *StealSmallItems) Mnemonic() string { return "StealSmallItems" }
+func (i *StealSmallItems) Encode() []byte {
+	return append(appendPosition([]byte{OpStealSmallItems, byte(i.Whom)}, i.Pos), byte(i.SubPos))
+}
+
+// Message prints Text in the text area. Text holds the raw bytes of the DOS code page, Color is a palette index.
+type Message struct {
+	Location
+	Text  string
+	Color int
+}
+
+func (i *Message) Opcode() byte     { return OpMessage }
+func (i *Message) Mnemonic() string { return "Message" }
+func (i *Message) Encode() []byte {
+	data := append([]byte{OpMessage}, i.Text...)
+	return appendWord(append(data, 0), uint16(i.Color))
+}
+
+// FlagOperand is the flag changed by SetFlag and ClearFlag. Monster is only used by monster flags, Flag by maze,
+// global and monster flags.
+type FlagOperand struct {
+	Target  byte
+	Monster int
+	Flag    int
+}
+
+func (f FlagOperand) encode(opcode byte) []byte {
+	data := []byte{opcode, f.Target}
+	switch f.Target {
+	case FlagMaze, FlagGlobal:
+		data = append(data, byte(f.Flag))
+	case FlagMonster:
+		data = append(data, byte(f.Monster), byte(f.Flag))
+	}
+	return data
+}
+
+type SetFlag struct {
+	Location
+	FlagOperand
+}
+
+func (i *SetFlag) Opcode() byte     { return OpSetFlag }
+func (i *SetFlag) Mnemonic() string { return "SetFlag" }
+func (i *SetFlag) Encode() []byte   { return i.encode(OpSetFlag) }
+
+type ClearFlag struct {
+	Location
+	FlagOperand
+}
+
+func (i *ClearFlag) Opcode() byte     { return OpClearFlag }
+func (i *ClearFlag) Mnemonic() string { return "ClearFlag" }
+func (i *ClearFlag) Encode() []byte   { return i.encode(OpClearFlag) }
+
+// Sound plays a sound effect, at a block if Pos is not 0.
+type Sound struct {
+	Location
+	Id  int
+	Pos Position
+}
+
+func (i *Sound) Opcode() byte     { return OpSound }
+func (i *Sound) Mnemonic() string { return "Sound" }
+func (i *Sound) Encode() []byte   { return appendPosition([]byte{OpSound, byte(i.Id)}, i.Pos) }
+
+// Heal is never used in EOB1 and has no operands here.
+type Heal struct {
+	Location
+}
+
+func (i *Heal) Opcode() byte     { return OpHeal }
+func (i *Heal) Mnemonic() string { return "Heal" }
+func (i *Heal) Encode() []byte   { return []byte{OpHeal} }
+
+// Damage hurts one member or the whole party by Rolls dice with Sides plus Base.
+type Damage struct {
+	Location
+	Whom  int
+	Rolls int
+	Sides int
+	Base  int
+}
+
+func (i *Damage) Opcode() byte     { return OpDamage }
+func (i *Damage) Mnemonic() string { return "Damage" }
+func (i *Damage) Encode() []byte {
+	return []byte{OpDamage, byte(i.Whom), byte(i.Rolls), byte(i.Sides), byte(i.Base)}
+}
+
+type Jump struct {
+	Location
+	Target int
+}
+
+func (i *Jump) Opcode() byte     { return OpJump }
+func (i *Jump) Mnemonic() string { return "Jump" }
+func (i *Jump) Encode() []byte   { return appendWord([]byte{OpJump}, uint16(i.Target)) }
+
+// EndCode aborts the event.
+type EndCode struct {
+	Location
+}
+
+func (i *EndCode) Opcode() byte     { return OpEndCode }
+func (i *EndCode) Mnemonic() string { return "EndCode" }
+func (i *EndCode) Encode() []byte   { return []byte{OpEndCode} }
+
+type Return struct {
+	Location
+}
+
+func (i *Return) Opcode() byte     { return OpReturn }
+func (i *Return) Mnemonic() string { return "Return" }
+func (i *Return) Encode() []byte   { return []byte{OpReturn} }
+
+type Call struct {
+	Location
+	Target int
+}
+
+func (i *Call) Opcode() byte     { return OpCall }
+func (i *Call) Mnemonic() string { return "Call" }
+func (i *Call) Encode() []byte   { return appendWord([]byte{OpCall}, uint16(i.Target)) }
+
+// Conditional evaluates the postfix Expression and continues at FalseTarget if the result is false.
+type Conditional struct {
+	Location
+	Expression  []Token
+	FalseTarget int
+}
+
+func (i *Conditional) Opcode() byte     { return OpConditional }
+func (i *Conditional) Mnemonic() string { return "Conditional" }
+func (i *Conditional) Encode() []byte {
+	data := []byte{OpConditional}
+	for _, token := range i.Expression {
+		data = append(data, token.Encode()...)
+	}
+	return appendWord(append(data, OpConditional), uint16(i.FalseTarget))
+}
+
+// ItemConsume removes the item of the mouse pointer, any item at a block or the items of ItemType at a block.
+type ItemConsume struct {
+	Location
+	ItemType byte
+	Pos      Position
+}
+
+func (i *ItemConsume) Opcode() byte     { return OpItemConsume }
+func (i *ItemConsume) Mnemonic() string { return "ItemConsume" }
+func (i *ItemConsume) Encode() []byte {
+	data := []byte{OpItemConsume, i.ItemType}
+	if i.ItemType != ConsumeAtPointer {
+		data = appendPosition(data, i.Pos)
+	}
+	return data
+}
+
+// ChangeLevel moves the party to another level, or within the level if Type is not LevelChange.
+type ChangeLevel struct {
+	Location
+	Type      byte
+	Level     int
+	Pos       Position
+	Direction int
+}
+
+func (i *ChangeLevel) Opcode() byte     { return OpChangeLevel }
+func (i *ChangeLevel) Mnemonic() string { return "ChangeLevel" }
+func (i *ChangeLevel) Encode() []byte {
+	if i.Type == LevelChange {
+		return append(appendPosition([]byte{OpChangeLevel, i.Type, byte(i.Level)}, i.Pos), byte(i.Direction))
+	}
+	return appendPosition([]byte{OpChangeLevel, i.Type, byte(i.Direction)}, i.Pos)
+}
+
+type GiveXP struct {
+	Location
+	Type   byte
+	Amount int
+}
+
+func (i *GiveXP) Opcode() byte     { return OpGiveXP }
+func (i *GiveXP) Mnemonic() string { return "GiveXP" }
+func (i *GiveXP) Encode() []byte {
+	data := []byte{OpGiveXP, i.Type}
+	if i.Type == ExperienceParty {
+		data = appendWord(data, uint16(i.Amount))
+	}
+	return data
+}
+
+// NewItem creates an item at a block, or in the mouse pointer if Pos is PositionNone.
+type NewItem struct {
+	Location
+	Item   int
+	Pos    Position
+	SubPos int
+}
+
+func (i *NewItem) Opcode() byte     { return OpNewItem }
+func (i *NewItem) Mnemonic() string { return "NewItem" }
+func (i *NewItem) Encode() []byte {
+	return append(appendPosition(appendWord([]byte{OpNewItem}, uint16(i.Item)), i.Pos), byte(i.SubPos))
+}
+
+// Launcher throws an item or casts a spell from a block.
+type Launcher struct {
+	Location
+	Kind      byte
+	Id        int
+	Pos       Position
+	Direction int
+	SubPos    int
+}
+
+func (i *Launcher) Opcode() byte     { return OpLauncher }
+func (i *Launcher) Mnemonic() string { return "Launcher" }
+func (i *Launcher) Encode() []byte {
+	data := appendPosition(appendWord([]byte{OpLauncher, i.Kind}, uint16(i.Id)), i.Pos)
+	return append(data, byte(i.Direction), byte(i.SubPos))
+}
+
+type Turn struct {
+	Location
+	Type      byte
+	Direction int
+}
+
+func (i *Turn) Opcode() byte     { return OpTurn }
+func (i *Turn) Mnemonic() string { return "Turn" }
+func (i *Turn) Encode() []byte   { return []byte{OpTurn, i.Type, byte(i.Direction)} }
+
+type IdentAllItems struct {
+	Location
+	Pos Position
+}
+
+func (i *IdentAllItems) Opcode() byte     { return OpIdentAllItems }
+func (i *IdentAllItems) Mnemonic() string { return "IdentAllItems" }
+func (i *IdentAllItems) Encode() []byte   { return appendPosition([]byte{OpIdentAllItems}, i.Pos) }
+
+type Encounters struct {
+	Location
+	Id int
+}
+
+func (i *Encounters) Opcode() byte     { return OpEncounters }
+func (i *Encounters) Mnemonic() string { return "Encounters" }
+func (i *Encounters) Encode() []byte   { return []byte{OpEncounters, byte(i.Id)} }
+
+type Wait struct {
+	Location
+	Ticks int
+}
+
+func (i *Wait) Opcode() byte     { return OpWait }
+func (i *Wait) Mnemonic() string { return "Wait" }
+func (i *Wait) Encode() []byte   { return appendWord([]byte{OpWait}, uint16(i.Ticks)) }
+
+// UpdateScreen, TextMenu and SpecialWindowPictures are never used in EOB1 and have no operands here.
+type UpdateScreen struct {
+	Location
+}
+
+func (i *UpdateScreen) Opcode() byte     { return OpUpdateScreen }
+func (i *UpdateScreen) Mnemonic() string { return "UpdateScreen" }
+func (i *UpdateScreen) Encode() []byte   { return []byte{OpUpdateScreen} }
+
+type TextMenu struct {
+	Location
+}
+
+func (i *TextMenu) Opcode() byte     { return OpTextMenu }
+func (i *TextMenu) Mnemonic() string { return "TextMenu" }
+func (i *TextMenu) Encode() []byte   { return []byte{OpTextMenu} }
+
+type SpecialWindowPictures struct {
+	Location
+}
+
+func (i *SpecialWindowPictures) Opcode() byte     { return OpSpecialWindowPictures }
+func (i *SpecialWindowPictures) Mnemonic() string { return "SpecialWindowPictures" }
+func (i *SpecialWindowPictures) Encode() []byte   { return []byte{OpSpecialWindowPictures} }
+
+// RawBytes keeps the bytes after an unknown opcode, the rest of the script cannot be decoded.
+type RawBytes struct {
+	Location
+	Bytes []byte
+}
+
+func (i *RawBytes) Opcode() byte {
+	if len(i.Bytes) == 0 {
+		return 0
+	}
+	return i.Bytes[0]
+}
+func (i *RawBytes) Mnemonic() string { return ".byte" }
+func (i *RawBytes) Encode() []byte   { return i.Bytes }
@@ -0,0 +1,51 @@
+package inf
+
+import "fmt"
+
+// Relocate places the instructions of a script one after the other from its offset again, after instructions
+// changed their length. Jump, call and conditional targets and the trigger addresses follow the instructions
+// they referenced, a target at the end of the script stays at the end. The instructions are moved in place, the
+// returned script and triggers replace the old ones.
+func Relocate(script *Script, triggers []Trigger) (*Script, []Trigger, error) {
+	moved := make(map[int]int, len(script.Instructions)+1)
+	offset := script.Offset
+	for _, instruction := range script.Instructions {
+		moved[instruction.GetOffset()] = offset
+		offset += len(instruction.Encode())
+	}
+	moved[script.End] = offset
+	if offset > 0xffff {
+		return nil, nil, fmt.Errorf("script too long, the trigger table would start at $%x", offset)
+	}
+
+	// check every target before moving anything
+	for _, instruction := range script.Instructions {
+		for _, target := range Targets(instruction) {
+			if _, ok := moved[target]; !ok {
+				return nil, nil, fmt.Errorf("%s: %s is not the address of an instruction", Label(instruction.GetOffset()), Label(target))
+			}
+		}
+	}
+	relocated := make([]Trigger, len(triggers))
+	for i, trigger := range triggers {
+		address, ok := moved[trigger.Address]
+		if !ok {
+			return nil, nil, fmt.Errorf("trigger %d: %s is not the address of an instruction", trigger.Index, Label(trigger.Address))
+		}
+		relocated[i] = trigger
+		relocated[i].Address = address
+	}
+
+	for _, instruction := range script.Instructions {
+		switch i := instruction.(type) {
+		case *Jump:
+			i.Target = moved[i.Target]
+		case *Call:
+			i.Target = moved[i.Target]
+		case *Conditional:
+			i.FalseTarget = moved[i.FalseTarget]
+		}
+		instruction.(interface{ setOffset(int) }).setOffset(moved[instruction.GetOffset()])
+	}
+	return NewScript(script.Offset, offset, script.Instructions, relocated), relocated, nil
+}
@@ -0,0 +1,140 @@
+package inf
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+const relocateSource = `.trigger 0, [5,5], $08, _start
+.trigger 1, [6,6], $10, _second
+.org $0100
+_start: Conditional { partyAt([5,5]) } falseTarget=_false
+Message text="Hello" color=15
+Call target=_sub
+Jump target=_end
+_false: Message text="not here" color=1
+_second: SetFlag target=maze flag=1
+_end: EndCode
+_sub: Message text="sub" color=2
+Return
+Jump target=_table
+_table:
+`
+
+func assembleForRelocation(t *testing.T) (*Script, []Trigger) {
+	t.Helper()
+	script, triggers, err := Assemble(strings.NewReader(relocateSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script, triggers
+}
+
+func TestRelocate(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		text string
+	}{
+		{"longer", "Hello, adventurers"},
+		{"shorter", "Hi"},
+		{"same length", "Howdy"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			script, triggers := assembleForRelocation(t)
+			shift := len(test.text) - len("Hello")
+			message := script.Instructions[1].(*Message)
+			before := map[int]int{}
+			for _, instruction := range script.Instructions {
+				before[instruction.GetOffset()] = instruction.GetOffset()
+				if instruction.GetOffset() > message.GetOffset() {
+					before[instruction.GetOffset()] += shift
+				}
+			}
+			before[script.End] = script.End + shift
+			targets := map[Instruction][]int{}
+			for _, instruction := range script.Instructions {
+				for _, target := range Targets(instruction) {
+					targets[instruction] = append(targets[instruction], before[target])
+				}
+			}
+			message.Text = test.text
+
+			relocated, relocatedTriggers, err := Relocate(script, triggers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if relocated.Offset != script.Offset || relocated.End != script.End+shift {
+				t.Errorf("relocated script from $%04x to $%04x", relocated.Offset, relocated.End)
+			}
+			offset := relocated.Offset
+			for _, instruction := range relocated.Instructions {
+				if instruction.GetOffset() != offset {
+					t.Errorf("%s at $%04x instead of $%04x", FormatInstruction(instruction), instruction.GetOffset(), offset)
+				}
+				offset += len(instruction.Encode())
+				for i, target := range Targets(instruction) {
+					if target != targets[instruction][i] {
+						t.Errorf("%s targets $%04x instead of $%04x", FormatInstruction(instruction), target, targets[instruction][i])
+					}
+				}
+			}
+			for i, trigger := range relocatedTriggers {
+				if want := before[triggers[i].Address]; trigger.Address != want {
+					t.Errorf("trigger %d at $%04x instead of $%04x", trigger.Index, trigger.Address, want)
+				}
+				if relocated.At(trigger.Address) == nil {
+					t.Errorf("trigger %d does not start at an instruction", trigger.Index)
+				}
+			}
+
+			// the relocated script decodes to the same instructions
+			data := append(make([]byte, relocated.Offset), relocated.Encode()...)
+			reader := bytes.NewReader(data)
+			reader.Seek(int64(relocated.Offset), io.SeekStart)
+			decodedTriggers := append([]Trigger(nil), relocatedTriggers...)
+			decoded, err := ParseScripts(reader, &decodedTriggers, uint16(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want, got strings.Builder
+			WriteText(&want, relocated, relocatedTriggers)
+			WriteText(&got, decoded, decodedTriggers)
+			if got.String() != want.String() {
+				t.Errorf("decoded\n%s\ninstead of\n%s", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestRelocateInvalidTargets(t *testing.T) {
+	script, triggers := assembleForRelocation(t)
+	message := script.Instructions[1].(*Message)
+	offset := message.GetOffset()
+	message.Text = "Hello, adventurers"
+
+	jump := script.Instructions[3].(*Jump)
+	target := jump.Target
+	jump.Target = jump.GetOffset() + 1
+	if _, _, err := Relocate(script, triggers); err == nil {
+		t.Error("jump into an instruction relocated")
+	}
+	if jump.Target != jump.GetOffset()+1 || script.Instructions[2].GetOffset() != offset+len("Hello")+4 {
+		t.Error("instructions moved by a failed relocation")
+	}
+	jump.Target = target
+
+	triggers[1].Address++
+	if _, _, err := Relocate(script, triggers); err == nil {
+		t.Error("trigger into an instruction relocated")
+	}
+}
+
+func TestRelocateTooLong(t *testing.T) {
+	script, triggers := assembleForRelocation(t)
+	script.Instructions[1].(*Message).Text = strings.Repeat("x", 0x10000)
+	if _, _, err := Relocate(script, triggers); err == nil {
+		t.Error("script beyond $ffff relocated")
+	}
+}
@@ -0,0 +1,73 @@
+package inf
+
+import (
+	"sort"
+)
+
+// Script is the decoded bytecode of a level, from Offset up to the trigger table at End. Instructions are in
+// address order, entry points are the addresses referenced by triggers.
+type Script struct {
+	Offset       int
+	End          int
+	Instructions []Instruction
+	indices      map[int]int
+	entries      map[int][]Trigger
+}
+
+func NewScript(offset, end int, instructions []Instruction, triggers []Trigger) *Script {
+	s := &Script{
+		Offset:       offset,
+		End:          end,
+		Instructions: instructions,
+		indices:      make(map[int]int, len(instructions)),
+		entries:      make(map[int][]Trigger),
+	}
+	for i, instruction := range instructions {
+		s.indices[instruction.GetOffset()] = i
+	}
+	for _, trigger := range triggers {
+		s.entries[trigger.Address] = append(s.entries[trigger.Address], trigger)
+	}
+	for _, referencing := range s.entries {
+		sort.Slice(referencing, func(i, j int) bool { return referencing[i].Index < referencing[j].Index })
+	}
+	return s
+}
+
+// IndexOf returns the index of the instruction starting at an address, false if no instruction starts there.
+func (s *Script) IndexOf(address int) (int, bool) {
+	index, ok := s.indices[address]
+	return index, ok
+}
+
+// At returns the instruction starting at an address or nil.
+func (s *Script) At(address int) Instruction {
+	if index, ok := s.indices[address]; ok {
+		return s.Instructions[index]
+	}
+	return nil
+}
+
+// GetTriggers returns the triggers starting the script at an address in the order of the trigger table.
+func (s *Script) GetTriggers(address int) []Trigger {
+	return s.entries[address]
+}
+
+// GetEntryPoints returns the addresses referenced by triggers in ascending order.
+func (s *Script) GetEntryPoints() []int {
+	addresses := make([]int, 0, len(s.entries))
+	for address := range s.entries {
+		addresses = append(addresses, address)
+	}
+	sort.Ints(addresses)
+	return addresses
+}
+
+// Encode returns the bytecode of all instructions, for an unchanged script the bytes from Offset to End.
+func (s *Script) Encode() []byte {
+	var data []byte
+	for _, instruction := range s.Instructions {
+		data = append(data, instruction.Encode()...)
+	}
+	return data
+}
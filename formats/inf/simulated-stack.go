@@ -39,3 +39,8 @@ func (s *SimulatedStack) PopBack() int {
 func (s *SimulatedStack) Size() int {
 	return len(s.items)
 }
+
+// Values returns a copy of the items from the bottom to the top of the stack.
+func (s *SimulatedStack) Values() []int {
+	return append([]int(nil), s.items...)
+}
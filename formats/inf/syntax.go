@@ -0,0 +1,298 @@
+package inf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// operand is a named operand of an instruction or an expression token in the assembly syntax. value points into
+// the instruction: *int, *byte, *Position, *string, *[]Token or *[]MonsterTypeValue. Addresses are written as
+// labels, values with a symbol by name.
+type operand struct {
+	name    string
+	value   any
+	address bool
+	symbols map[int]string
+}
+
+var wallTypeSymbols = map[int]string{int(WallAllSides): "all", int(WallOneSide): "side", int(WallOpenDoor): "door", int(WallPartyDirection): "direction"}
+var targetSymbols = map[int]string{int(TargetParty): "party", int(TargetMonster): "monster", int(TargetItem): "item"}
+var turnSymbols = map[int]string{int(TurnParty): "party", int(TurnItem): "item"}
+var flagSymbols = map[int]string{int(FlagMaze): "maze", int(FlagGlobal): "global", int(FlagMonster): "monster", int(FlagEvent): "event", int(FlagSaveRest): "saveRest"}
+var consumeSymbols = map[int]string{int(ConsumeAtPointer): "pointer", int(ConsumeAnyAtBlock): "any"}
+var experienceSymbols = map[int]string{int(ExperienceParty): "party"}
+var levelSymbols = map[int]string{int(LevelChange): "level"}
+var launcherSymbols = map[int]string{int(LauncherSpell): "spell"}
+var memberSymbols = map[int]string{0xff: "all"}
+var pointerItemSymbols = map[int]string{int(PointerItemType): "type", int(PointerItemAny): "any", int(PointerItemValue): "value",
+	int(PointerItemUnidentName): "unidentName", int(PointerItemIdentName): "identName"}
+var itemTypeSymbols = map[int]string{int(AnyItemType): "any"}
+
+var partyContainsNames = map[byte]string{ExprRace: "race", ExprAlignment: "alignment", ExprClass: "class"}
+
+func instructionOperands(instruction Instruction) []operand {
+	switch i := instruction.(type) {
+	case *SetWall:
+		operands := []operand{{name: "type", value: &i.Type, symbols: wallTypeSymbols}}
+		switch i.Type {
+		case WallAllSides:
+			return append(operands, operand{name: "pos", value: &i.Pos}, operand{name: "to", value: &i.To})
+		case WallOneSide:
+			return append(operands, operand{name: "pos", value: &i.Pos}, operand{name: "side", value: &i.Side}, operand{name: "to", value: &i.To})
+		case WallPartyDirection:
+			return append(operands, operand{name: "direction", value: &i.Direction})
+		}
+		return operands
+	case *ChangeWall:
+		operands := []operand{{name: "type", value: &i.Type, symbols: wallTypeSymbols}}
+		switch i.Type {
+		case WallAllSides:
+			return append(operands, operand{name: "pos", value: &i.Pos}, operand{name: "to", value: &i.To}, operand{name: "from", value: &i.From})
+		case WallOneSide:
+			return append(operands, operand{name: "pos", value: &i.Pos}, operand{name: "side", value: &i.Side},
+				operand{name: "to", value: &i.To}, operand{name: "from", value: &i.From})
+		case WallOpenDoor:
+			return append(operands, operand{name: "pos", value: &i.Pos})
+		}
+		return operands
+	case *OpenDoor:
+		return []operand{{name: "pos", value: &i.Pos}}
+	case *CloseDoor:
+		return []operand{{name: "pos", value: &i.Pos}}
+	case *CreateMonster:
+		return []operand{{name: "index", value: &i.Index}, {name: "moveTime", value: &i.MoveTime}, {name: "pos", value: &i.Pos},
+			{name: "subPos", value: &i.SubPos}, {name: "direction", value: &i.Direction}, {name: "type", value: &i.Type},
+			{name: "picture", value: &i.Picture}, {name: "phase", value: &i.Phase}, {name: "pause", value: &i.Pause},
+			{name: "pocket", value: &i.Pocket}, {name: "weapon", value: &i.Weapon}}
+	case *Teleport:
+		return []operand{{name: "type", value: &i.Type, symbols: targetSymbols}, {name: "source", value: &i.Source},
+			{name: "destination", value: &i.Destination}}
+	case *StealSmallItems:
+		return []operand{{name: "whom", value: &i.Whom, symbols: memberSymbols}, {name: "pos", value: &i.Pos}, {name: "subPos", value: &i.SubPos}}
+	case *Message:
+		return []operand{{name: "text", value: &i.Text}, {name: "color", value: &i.Color}}
+	case *SetFlag:
+		return flagOperands(&i.FlagOperand)
+	case *ClearFlag:
+		return flagOperands(&i.FlagOperand)
+	case *Sound:
+		return []operand{{name: "id", value: &i.Id}, {name: "pos", value: &i.Pos}}
+	case *Damage:
+		return []operand{{name: "whom", value: &i.Whom, symbols: memberSymbols}, {name: "rolls", value: &i.Rolls},
+			{name: "sides", value: &i.Sides}, {name: "base", value: &i.Base}}
+	case *Jump:
+		return []operand{{name: "target", value: &i.Target, address: true}}
+	case *Call:
+		return []operand{{name: "target", value: &i.Target, address: true}}
+	case *Conditional:
+		return []operand{{value: &i.Expression}, {name: "falseTarget", value: &i.FalseTarget, address: true}}
+	case *ItemConsume:
+		operands := []operand{{name: "itemType", value: &i.ItemType, symbols: consumeSymbols}}
+		if i.ItemType != ConsumeAtPointer {
+			operands = append(operands, operand{name: "pos", value: &i.Pos})
+		}
+		return operands
+	case *ChangeLevel:
+		if i.Type == LevelChange {
+			return []operand{{name: "type", value: &i.Type, symbols: levelSymbols}, {name: "level", value: &i.Level},
+				{name: "pos", value: &i.Pos}, {name: "direction", value: &i.Direction}}
+		}
+		return []operand{{name: "type", value: &i.Type, symbols: levelSymbols}, {name: "direction", value: &i.Direction},
+			{name: "pos", value: &i.Pos}}
+	case *GiveXP:
+		operands := []operand{{name: "type", value: &i.Type, symbols: experienceSymbols}}
+		if i.Type == ExperienceParty {
+			operands = append(operands, operand{name: "amount", value: &i.Amount})
+		}
+		return operands
+	case *NewItem:
+		return []operand{{name: "item", value: &i.Item}, {name: "pos", value: &i.Pos}, {name: "subPos", value: &i.SubPos}}
+	case *Launcher:
+		return []operand{{name: "kind", value: &i.Kind, symbols: launcherSymbols}, {name: "id", value: &i.Id},
+			{name: "pos", value: &i.Pos}, {name: "direction", value: &i.Direction}, {name: "subPos", value: &i.SubPos}}
+	case *Turn:
+		return []operand{{name: "type", value: &i.Type, symbols: turnSymbols}, {name: "direction", value: &i.Direction}}
+	case *IdentAllItems:
+		return []operand{{name: "pos", value: &i.Pos}}
+	case *Encounters:
+		return []operand{{name: "id", value: &i.Id}}
+	case *Wait:
+		return []operand{{name: "ticks", value: &i.Ticks}}
+	}
+	return nil
+}
+
+func flagOperands(f *FlagOperand) []operand {
+	operands := []operand{{name: "target", value: &f.Target, symbols: flagSymbols}}
+	switch f.Target {
+	case FlagMaze, FlagGlobal:
+		return append(operands, operand{name: "flag", value: &f.Flag})
+	case FlagMonster:
+		return append(operands, operand{name: "monster", value: &f.Monster}, operand{name: "flag", value: &f.Flag})
+	}
+	return operands
+}
+
+// tokenOperands returns the name of an expression token and its operands, constants and operators have no name.
+func tokenOperands(token Token) (string, []operand) {
+	switch t := token.(type) {
+	case *CountMonstersAt:
+		return "monstersAt", []operand{{value: &t.Pos}}
+	case *CountMonstersOfType:
+		return "monstersOfType", []operand{{value: &t.Types}}
+	case *PartyVisible:
+		return "partyVisible", nil
+	case *RollDice:
+		return "rollDice", []operand{{value: &t.Rolls}, {value: &t.Sides}, {value: &t.Base}}
+	case *PartyContains:
+		return partyContainsNames[t.Code], []operand{{value: &t.Value}}
+	case *TriggerFlags:
+		return "triggerFlags", nil
+	case *PartyDirection:
+		return "partyDirection", nil
+	case *GlobalFlag:
+		return "globalFlag", []operand{{value: &t.Flag}}
+	case *MazeFlag:
+		return "mazeFlag", []operand{{value: &t.Flag}}
+	case *PointerItem:
+		operands := []operand{{value: &t.Property, symbols: pointerItemSymbols}}
+		if t.Property == PointerItemUnidentName || t.Property == PointerItemIdentName {
+			operands = append(operands, operand{value: &t.Value})
+		}
+		return "pointerItem", operands
+	case *WallSide:
+		return "wallSide", []operand{{value: &t.Side}, {value: &t.Pos}}
+	case *PartyInventoryCount:
+		return "inventoryCount", []operand{{value: &t.ItemType}, {value: &t.Flags}}
+	case *PartyAt:
+		return "partyAt", []operand{{value: &t.Pos}}
+	case *ItemsAt:
+		return "itemsAt", []operand{{value: &t.ItemType, symbols: itemTypeSymbols}, {value: &t.Pos}}
+	case *WallNumber:
+		return "wallNumber", []operand{{value: &t.Pos}}
+	}
+	return "", nil
+}
+
+// Label returns the label of an address in the assembly syntax.
+func Label(address int) string {
+	return fmt.Sprintf("_0x%04x", address)
+}
+
+// FormatInstruction returns an instruction in the assembly syntax, eg. `SetWall type=side pos=[10,15] side=2 to=3`.
+func FormatInstruction(instruction Instruction) string {
+	if raw, ok := instruction.(*RawBytes); ok {
+		return formatBytes(raw.Bytes)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(instruction.Mnemonic())
+	for _, op := range instructionOperands(instruction) {
+		builder.WriteByte(' ')
+		if op.name != "" {
+			builder.WriteString(op.name + "=")
+		}
+		builder.WriteString(op.format())
+	}
+	return builder.String()
+}
+
+// FormatToken returns an expression token in the assembly syntax, eg. `partyAt([10,15])`, `3` or `&&`.
+func FormatToken(token Token) string {
+	switch t := token.(type) {
+	case *Constant:
+		return strconv.Itoa(int(t.Value))
+	case *Operator:
+		return t.Symbol()
+	}
+
+	name, operands := tokenOperands(token)
+	if len(operands) == 0 {
+		return name
+	}
+	values := make([]string, len(operands))
+	for i, op := range operands {
+		values[i] = op.format()
+	}
+	return name + "(" + strings.Join(values, ",") + ")"
+}
+
+// FormatExpression returns the postfix tokens of a conditional in braces.
+func FormatExpression(expression []Token) string {
+	tokens := make([]string, len(expression))
+	for i, token := range expression {
+		tokens[i] = FormatToken(token)
+	}
+	return "{ " + strings.Join(append(tokens, "}"), " ")
+}
+
+func formatBytes(data []byte) string {
+	values := make([]string, len(data))
+	for i, b := range data {
+		values[i] = fmt.Sprintf("$%02x", b)
+	}
+	return ".byte " + strings.Join(values, ",")
+}
+
+func (op operand) format() string {
+	switch v := op.value.(type) {
+	case *int:
+		if op.address {
+			return Label(*v)
+		}
+		return op.formatNumber(*v)
+	case *byte:
+		if name, ok := op.symbols[int(*v)]; ok {
+			return name
+		}
+		return fmt.Sprintf("$%02x", *v)
+	case *Position:
+		if v.IsNone() {
+			return "none"
+		}
+		return fmt.Sprintf("[%d,%d]", v.X, v.Y)
+	case *string:
+		return strconv.Quote(*v)
+	case *[]Token:
+		return FormatExpression(*v)
+	case *[]MonsterTypeValue:
+		pairs := make([]string, len(*v))
+		for i, pair := range *v {
+			pairs[i] = fmt.Sprintf("%d:%d", pair.Type, pair.Value)
+		}
+		return strings.Join(pairs, ",")
+	}
+	return "?"
+}
+
+func (op operand) formatNumber(value int) string {
+	if name, ok := op.symbols[value]; ok {
+		return name
+	}
+	return strconv.Itoa(value)
+}
+
+// operandValue returns the value of an operand for the JSON output: numbers, {"X","Y"} positions, strings and
+// expressions as a list of tokens in the assembly syntax.
+func (op operand) operandValue() any {
+	switch v := op.value.(type) {
+	case *int:
+		return *v
+	case *byte:
+		return int(*v)
+	case *Position:
+		return *v
+	case *string:
+		return *v
+	case *[]Token:
+		tokens := make([]string, len(*v))
+		for i, token := range *v {
+			tokens[i] = FormatToken(token)
+		}
+		return tokens
+	case *[]MonsterTypeValue:
+		return *v
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package inf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TriggerEvent is a set of events happening at a block. The trigger flags select the events a trigger fires on,
+// shifted left by 3: $08 fires when the party enters, $10 when it leaves, ...
+type TriggerEvent int
+
+const (
+	EventPartyEnter TriggerEvent = 1 << iota
+	EventPartyLeave
+	EventItemDrop
+	EventItemPickup
+	EventMonsterEnter
+	// EventWallClick is not part of the trigger flags, every trigger of a block fires when a wall is clicked and the
+	// scripts check the trigger flags themselves.
+	EventWallClick
+)
+
+// triggerAlwaysFires are the events running every trigger of a block regardless of its flags.
+const triggerAlwaysFires TriggerEvent = 0xe0
+
+var triggerEventNames = []struct {
+	event TriggerEvent
+	name  string
+}{
+	{EventPartyEnter, "partyEnter"}, {EventPartyLeave, "partyLeave"}, {EventItemDrop, "itemDrop"},
+	{EventItemPickup, "itemPickup"}, {EventMonsterEnter, "monsterEnter"}, {EventWallClick, "wallClick"},
+}
+
+// Events returns the events selected by the trigger flags.
+func (t Trigger) Events() TriggerEvent {
+	return TriggerEvent(t.Flags>>3) & 0x1f
+}
+
+// FiresOn reports whether the trigger runs for an event, wall clicks run every trigger.
+func (t Trigger) FiresOn(event TriggerEvent) bool {
+	return event&(t.Events()|triggerAlwaysFires) != 0
+}
+
+// Names returns the names of the events in the set, unknown events as hex numbers.
+func (e TriggerEvent) Names() []string {
+	names := []string{}
+	for _, n := range triggerEventNames {
+		if e&n.event != 0 {
+			names = append(names, n.name)
+			e &^= n.event
+		}
+	}
+	for bit := TriggerEvent(1); e != 0; bit <<= 1 {
+		if e&bit != 0 {
+			names = append(names, fmt.Sprintf("$%02x", int(bit)))
+			e &^= bit
+		}
+	}
+	return names
+}
+
+// String returns the events joined by |, eg. partyEnter|itemDrop, or none.
+func (e TriggerEvent) String() string {
+	if e == 0 {
+		return "none"
+	}
+	return strings.Join(e.Names(), "|")
+}
+
+// ParseTriggerEvent returns the event of a name printed by String, several events may be joined by |.
+func ParseTriggerEvent(text string) (TriggerEvent, error) {
+	var event TriggerEvent
+	if text == "none" {
+		return event, nil
+	}
+	for _, name := range strings.Split(text, "|") {
+		e, err := parseEventName(name)
+		if err != nil {
+			return 0, err
+		}
+		event |= e
+	}
+	return event, nil
+}
+
+func parseEventName(name string) (TriggerEvent, error) {
+	for _, n := range triggerEventNames {
+		if n.name == name {
+			return n.event, nil
+		}
+	}
+	var value int
+	if _, err := fmt.Sscanf(name, "$%x", &value); err == nil {
+		return TriggerEvent(value), nil
+	}
+	return 0, fmt.Errorf("unknown trigger event %s", name)
+}
+
+// MarshalJSON writes the events as an array of names.
+func (e TriggerEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Names())
+}
+
+func (e *TriggerEvent) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*e = 0
+	for _, name := range names {
+		event, err := parseEventName(name)
+		if err != nil {
+			return err
+		}
+		*e |= event
+	}
+	return nil
+}
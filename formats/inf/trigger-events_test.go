@@ -0,0 +1,90 @@
+package inf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var eventTests = []struct {
+	flags  int
+	events TriggerEvent
+	text   string
+}{
+	{0x00, 0, "none"},
+	{0x07, 0, "none"},
+	{0x08, EventPartyEnter, "partyEnter"},
+	{0x10, EventPartyLeave, "partyLeave"},
+	{0x18, EventPartyEnter | EventPartyLeave, "partyEnter|partyLeave"},
+	{0x20, EventItemDrop, "itemDrop"},
+	{0x40, EventItemPickup, "itemPickup"},
+	{0x80, EventMonsterEnter, "monsterEnter"},
+	{0xa8, EventPartyEnter | EventItemDrop | EventMonsterEnter, "partyEnter|itemDrop|monsterEnter"},
+	{0xff, EventPartyEnter | EventPartyLeave | EventItemDrop | EventItemPickup | EventMonsterEnter,
+		"partyEnter|partyLeave|itemDrop|itemPickup|monsterEnter"},
+}
+
+func TestTriggerEvents(t *testing.T) {
+	for _, test := range eventTests {
+		trigger := Trigger{Flags: test.flags}
+		if events := trigger.Events(); events != test.events {
+			t.Errorf("flags $%02x: events %s instead of %s", test.flags, events, test.events)
+		}
+		if text := test.events.String(); text != test.text {
+			t.Errorf("flags $%02x: %s instead of %s", test.flags, text, test.text)
+		}
+	}
+}
+
+func TestTriggerFiresOn(t *testing.T) {
+	events := []TriggerEvent{EventPartyEnter, EventPartyLeave, EventItemDrop, EventItemPickup, EventMonsterEnter, EventWallClick}
+	for _, test := range eventTests {
+		trigger := Trigger{Flags: test.flags}
+		for _, event := range events {
+			fires := event == EventWallClick || test.events&event != 0
+			if trigger.FiresOn(event) != fires {
+				t.Errorf("flags $%02x: fires on %s %v", test.flags, event, !fires)
+			}
+		}
+	}
+}
+
+func TestParseTriggerEvent(t *testing.T) {
+	for _, test := range eventTests {
+		event, err := ParseTriggerEvent(test.text)
+		if err != nil || event != test.events {
+			t.Errorf("%s parsed as %s, %v", test.text, event, err)
+		}
+	}
+	if event, err := ParseTriggerEvent("wallClick|$40"); err != nil || event != EventWallClick|0x40 {
+		t.Errorf("wallClick|$40 parsed as %s, %v", event, err)
+	}
+	if event, err := ParseTriggerEvent("$100"); err != nil || event.String() != "$100" {
+		t.Errorf("$100 parsed as %s, %v", event, err)
+	}
+	if _, err := ParseTriggerEvent("partyEnter|walk"); err == nil {
+		t.Error("unknown event accepted")
+	}
+}
+
+func TestTriggerEventJSON(t *testing.T) {
+	for _, event := range []TriggerEvent{0, EventPartyEnter, EventPartyLeave | EventMonsterEnter, EventWallClick | 0x100} {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded TriggerEvent
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != event {
+			t.Errorf("%s decoded from %s as %s, %v", event, data, decoded, err)
+		}
+	}
+	if data, _ := json.Marshal(EventPartyEnter | EventItemDrop); string(data) != `["partyEnter","itemDrop"]` {
+		t.Errorf("JSON %s", data)
+	}
+	if data, _ := json.Marshal(TriggerEvent(0)); string(data) != `[]` {
+		t.Errorf("JSON of no events %s", data)
+	}
+	var event TriggerEvent
+	if err := json.Unmarshal([]byte(`["partyEnter","walk"]`), &event); err == nil {
+		t.Error("unknown event accepted")
+	}
+}
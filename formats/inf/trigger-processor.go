@@ -9,10 +9,14 @@ import (
 )
 
 type Position struct {
-	X, Y int
+	X int `json:"x"`
+	Y int `json:"y"`
 }
 
+// Trigger starts the script at Address for events at Pos. Index is the position in the trigger table of the file,
+// the triggers are sorted by position when loaded.
 type Trigger struct {
+	Index         int
 	Pos           Position
 	Flags         int
 	CollapseFlags int
@@ -41,6 +45,7 @@ func LoadTriggers(buffer *bytes.Reader, offset uint16) (*[]Trigger, error) {
 	// You can initialize each Trigger in the slice as needed
 	for i := range triggers {
 		t, _ := NewTriggerFromBytesReader(buffer)
+		t.Index = i
 		triggers[i] = *t
 	}
 
@@ -48,7 +53,7 @@ func LoadTriggers(buffer *bytes.Reader, offset uint16) (*[]Trigger, error) {
 	buffer.Seek(currentPosition, io.SeekStart)
 
 	// sort triggers
-	sort.Slice(triggers, func(i, j int) bool {
+	sort.SliceStable(triggers, func(i, j int) bool {
 		if triggers[i].Pos.Y == triggers[j].Pos.Y {
 			return triggers[i].Pos.X < triggers[j].Pos.X
 		}
@@ -62,11 +67,7 @@ func NewTriggerFromBytesReader(r *bytes.Reader) (*Trigger, error) {
 	pos := rw(r)
 	flags := rb(r)
 	address := rw(r)
-	x := pos & 31
-	y := pos / 32
-
-	position := Position{X: int(x), Y: int(y)}
-	return NewTrigger(position, int(flags), int(address)), nil
+	return NewTrigger(NewPositionFromWord(pos), int(flags), int(address)), nil
 }
 
 func NewTrigger(pos Position, flags, address int) *Trigger {
@@ -83,6 +84,6 @@ func NewTrigger(pos Position, flags, address int) *Trigger {
 }
 
 func (t Trigger) String() string {
-	return fmt.Sprintf("Position: (%d, %d), Flags: %d, CollapseFlags: %d, Address: %d",
-		t.Pos.X, t.Pos.Y, t.Flags, t.CollapseFlags, t.Address)
+	return fmt.Sprintf("Index: %d, Position: (%d, %d), Flags: %d, Events: %s, CollapseFlags: %d, Address: %d",
+		t.Index, t.Pos.X, t.Pos.Y, t.Flags, t.Events(), t.CollapseFlags, t.Address)
 }
@@ -0,0 +1,119 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+)
+
+const itemNameLength = 35
+
+// Item is a single entry of the game's item database (ITEM.DAT).
+// Position is the maze block (y*32+x) the item lies on, SubPosition the quarter
+// of the block (0: north-west, 1: north-east, 2: south-west, 3: south-east,
+// 4 and above: wall compartments).
+type Item struct {
+	NameUnidentified byte
+	NameIdentified   byte
+	Flags            byte
+	Picture          int8
+	Type             int8
+	SubPosition      int8
+	Position         int16
+	Next             int16
+	Prev             int16
+	Level            byte
+	Value            int8
+}
+
+type ItemCatalog struct {
+	NbrItems uint16
+	Items    []Item
+	NbrNames uint16
+	Names    []string
+}
+
+// X returns the horizontal maze coordinate of the item.
+func (i Item) X() int {
+	return int(uint16(i.Position) & 31)
+}
+
+// Y returns the vertical maze coordinate of the item.
+func (i Item) Y() int {
+	return int(uint16(i.Position) / 32)
+}
+
+// IsInMaze reports whether the item lies on the floor of a maze block, not in
+// an inventory or in a wall compartment.
+func (i Item) IsInMaze() bool {
+	return i.Position >= 0 && i.SubPosition >= 0 && i.SubPosition < 4
+}
+
+func NewItemCatalogFromByteArray(rawData *[]byte) (*ItemCatalog, error) {
+	reader := bytes.NewReader(*rawData)
+
+	catalog := &ItemCatalog{}
+
+	err := binary.Read(reader, binary.LittleEndian, &catalog.NbrItems)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog.Items = make([]Item, catalog.NbrItems)
+	for i := range catalog.Items {
+		err = binary.Read(reader, binary.LittleEndian, &catalog.Items[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Item names follow the item table, every name is a zero padded 35 byte string
+	err = binary.Read(reader, binary.LittleEndian, &catalog.NbrNames)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog.Names = make([]string, catalog.NbrNames)
+	rawName := make([]byte, itemNameLength)
+	for i := range catalog.Names {
+		_, err = reader.Read(rawName)
+		if err != nil {
+			return nil, err
+		}
+		catalog.Names[i] = string(rawName[:clean(rawName)])
+	}
+
+	return catalog, nil
+}
+
+func NewItemCatalogFromFile(filename string) (*ItemCatalog, error) {
+	rawData, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewItemCatalogFromByteArray(&rawData)
+}
+
+// GetName returns the identified or unidentified name of the item.
+func (c *ItemCatalog) GetName(item Item, identified bool) string {
+	index := int(item.NameUnidentified)
+	if identified {
+		index = int(item.NameIdentified)
+	}
+	if index >= len(c.Names) {
+		return ""
+	}
+	return c.Names[index]
+}
+
+// GetItemsOfLevel returns the indices of the items lying on the floor of the given level.
+func (c *ItemCatalog) GetItemsOfLevel(level int) []int {
+	var result []int
+	for i, item := range c.Items {
+		if int(item.Level) == level && item.IsInMaze() {
+			result = append(result, i)
+		}
+	}
+	return result
+}
@@ -0,0 +1,250 @@
+package formats
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ScriptMessage is a text printed by the scripts of a level. Text is the text of the INF file decoded with the
+// charset of the release, Translation replaces it when the messages are imported again, an empty translation
+// keeps the text. A nil Color keeps the color of the level.
+type ScriptMessage struct {
+	Level       int
+	Offset      int
+	Color       *int
+	Text        string
+	Translation string
+}
+
+// ExtractMessages returns the Message instructions of the script of a level in address order.
+func ExtractMessages(level int, header *InfHeader, charset *Charset) []ScriptMessage {
+	var messages []ScriptMessage
+	for _, instruction := range header.Script.Instructions {
+		if message, ok := instruction.(*inf.Message); ok {
+			color := message.Color
+			messages = append(messages, ScriptMessage{Level: level, Offset: message.GetOffset(), Color: &color,
+				Text: charset.Decode(message.Text)})
+		}
+	}
+	return messages
+}
+
+// EncodeWithMessages returns the decompressed INF data with the translations and colors of the messages of the
+// level replaced. The script is relocated if texts change their length, false is returned if nothing changed.
+// Texts that no longer match the level are rejected, the messages have been extracted from another release.
+// The instructions of the header's script are changed in place.
+func (header *InfHeader) EncodeWithMessages(level int, messages []ScriptMessage, charset *Charset) ([]byte, bool, error) {
+	changed := false
+	for _, m := range messages {
+		if m.Level != level {
+			continue
+		}
+		message, ok := header.Script.At(m.Offset).(*inf.Message)
+		if !ok {
+			return nil, false, fmt.Errorf("level %d has no message at $%04x", level, m.Offset)
+		}
+		if original := charset.Decode(message.Text); original != m.Text {
+			return nil, false, fmt.Errorf("level %d, $%04x: the text %q does not match %q of the level", level, m.Offset, m.Text, original)
+		}
+
+		text := message.Text
+		if m.Translation != "" {
+			var err error
+			if text, err = charset.Encode(m.Translation); err != nil {
+				return nil, false, fmt.Errorf("level %d, $%04x: %w", level, m.Offset, err)
+			}
+			if strings.IndexByte(text, 0) >= 0 {
+				return nil, false, fmt.Errorf("level %d, $%04x: a message cannot contain a zero byte", level, m.Offset)
+			}
+		}
+		color := message.Color
+		if m.Color != nil {
+			color = *m.Color
+		}
+		if text != message.Text || color != message.Color {
+			message.Text, message.Color = text, color
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false, nil
+	}
+
+	script, triggers, err := inf.Relocate(header.Script, header.Triggers)
+	if err != nil {
+		return nil, false, fmt.Errorf("level %d: %w", level, err)
+	}
+	data, err := header.EncodeWithScript(script, triggers)
+	return data, err == nil, err
+}
+
+var messageColumns = []string{"level", "offset", "color", "text", "translation"}
+
+// WriteMessagesCSV writes the messages as CSV with a header line, one message per line. The color cell is empty
+// for messages keeping the color of the level.
+func WriteMessagesCSV(w io.Writer, messages []ScriptMessage) error {
+	writer := csv.NewWriter(w)
+	writer.Write(messageColumns)
+	for _, m := range messages {
+		color := ""
+		if m.Color != nil {
+			color = strconv.Itoa(*m.Color)
+		}
+		writer.Write([]string{strconv.Itoa(m.Level), fmt.Sprintf("0x%04x", m.Offset), color, m.Text, m.Translation})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadMessagesCSV reads the messages written by WriteMessagesCSV.
+func ReadMessagesCSV(r io.Reader) ([]ScriptMessage, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(messageColumns)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []ScriptMessage
+	for i, record := range records {
+		if i == 0 && record[0] == messageColumns[0] {
+			continue
+		}
+		m := ScriptMessage{Text: record[3], Translation: record[4]}
+		if err := parseMessageLocation(&m, record[0], record[1], record[2]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func parseMessageLocation(m *ScriptMessage, level, offset, color string) error {
+	var err error
+	if m.Level, err = strconv.Atoi(level); err != nil {
+		return fmt.Errorf("invalid level %s", level)
+	}
+	offset64, err := strconv.ParseInt(offset, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid offset %s", offset)
+	}
+	m.Offset = int(offset64)
+	if color == "" {
+		return nil
+	}
+	m.Color, err = parseColor(color)
+	return err
+}
+
+func parseColor(text string) (*int, error) {
+	color, err := strconv.Atoi(text)
+	if err != nil || color < 0 || color > 0xffff {
+		return nil, fmt.Errorf("invalid color %s", text)
+	}
+	return &color, nil
+}
+
+// WriteMessagesPO writes the messages as a gettext PO file. The context of every entry names the level and the
+// offset of the message, the color is an extracted comment. Entries without the comment keep the color of the
+// level when they are read back.
+func WriteMessagesPO(w io.Writer, messages []ScriptMessage, charset *Charset) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Messages of the level scripts, decoded with charset %s\n", charset.Name)
+	fmt.Fprintf(bw, "msgid \"\"\nmsgstr \"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+	for _, m := range messages {
+		bw.WriteString("\n")
+		if m.Color != nil {
+			fmt.Fprintf(bw, "#. color %d\n", *m.Color)
+		}
+		fmt.Fprintf(bw, "#: LEVEL%d.INF:0x%04x\n", m.Level, m.Offset)
+		fmt.Fprintf(bw, "msgctxt \"LEVEL%d.INF 0x%04x\"\n", m.Level, m.Offset)
+		fmt.Fprintf(bw, "msgid %s\nmsgstr %s\n", strconv.QuoteToGraphic(m.Text), strconv.QuoteToGraphic(m.Translation))
+	}
+	return bw.Flush()
+}
+
+// ReadMessagesPO reads the messages of a PO file written by WriteMessagesPO, strings may continue on the
+// following lines.
+func ReadMessagesPO(r io.Reader) ([]ScriptMessage, error) {
+	var messages []ScriptMessage
+	var current *ScriptMessage
+	var field *string
+	line := 0
+	finish := func() error {
+		defer func() { current, field = nil, nil }()
+		switch {
+		case current == nil:
+		case current.Level != 0:
+			messages = append(messages, *current)
+		case current.Text != "":
+			// only the header entry has no context
+			return fmt.Errorf("line %d: entry without msgctxt", line)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		keyword, value, _ := strings.Cut(text, " ")
+		switch {
+		case text == "":
+			if err := finish(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(text, "#. color "):
+			if current == nil {
+				current = &ScriptMessage{}
+			}
+			color, err := parseColor(strings.TrimPrefix(text, "#. color "))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			current.Color = color
+		case strings.HasPrefix(text, "#"):
+		case keyword == "msgctxt" || keyword == "msgid" || keyword == "msgstr":
+			if current == nil {
+				current = &ScriptMessage{}
+			}
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, value)
+			}
+			switch keyword {
+			case "msgctxt":
+				if _, err := fmt.Sscanf(unquoted, "LEVEL%d.INF 0x%x", &current.Level, &current.Offset); err != nil {
+					return nil, fmt.Errorf("line %d: invalid context %q", line, unquoted)
+				}
+				field = nil
+			case "msgid":
+				current.Text, field = unquoted, &current.Text
+			case "msgstr":
+				current.Translation, field = unquoted, &current.Translation
+			}
+		case strings.HasPrefix(text, `"`) && field != nil:
+			unquoted, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, text)
+			}
+			*field += unquoted
+		case strings.HasPrefix(text, `"`):
+			// continuation of the header or of a context
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %s", line, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,105 @@
+package formats
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"strings"
+	"testing"
+)
+
+const messagesSource = `.trigger 0, [5,5], $08, _start
+.org $0010
+_start: Message text="Plate" color=12
+Message text="Hello\rworld" color=15
+EndCode
+`
+
+func newMessagesHeader(t *testing.T) *InfHeader {
+	t.Helper()
+	script, triggers, err := inf.Assemble(strings.NewReader(messagesSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &InfHeader{TriggersOffset: uint16(script.End), Script: script, Triggers: triggers, RawData: make([]byte, script.End)}
+}
+
+func messageAt(header *InfHeader, index int) *inf.Message {
+	return header.Script.Instructions[index].(*inf.Message)
+}
+
+func TestMessagesPO(t *testing.T) {
+	charset := ASCII
+	messages := ExtractMessages(1, newMessagesHeader(t), charset)
+	if len(messages) != 2 || *messages[0].Color != 12 || messages[1].Text != "Hello\rworld" || messages[1].Offset != 0x0019 {
+		t.Fatalf("extracted %v", messages)
+	}
+	messages[1].Translation = "Hallo\rWelt"
+
+	var po strings.Builder
+	if err := WriteMessagesPO(&po, messages, charset); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadMessagesPO(strings.NewReader(po.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 {
+		t.Fatalf("read %v from\n%s", read, po.String())
+	}
+	for i := range read {
+		if read[i].Level != 1 || read[i].Offset != messages[i].Offset || *read[i].Color != *messages[i].Color ||
+			read[i].Text != messages[i].Text || read[i].Translation != messages[i].Translation {
+			t.Errorf("read %+v instead of %+v", read[i], messages[i])
+		}
+	}
+}
+
+func TestMessagesKeepColor(t *testing.T) {
+	po := `msgid ""
+msgstr "Content-Type: text/plain; charset=UTF-8\n"
+
+#: LEVEL1.INF:0x0010
+msgctxt "LEVEL1.INF 0x0010"
+msgid "Plate"
+msgstr "Platte"
+
+#. color 3
+msgctxt "LEVEL1.INF 0x0019"
+msgid "Hello\rworld"
+msgstr ""
+`
+	csv := "level,offset,color,text,translation\n1,0x0010,,Plate,Platte\n1,0x0019,3,\"Hello\rworld\",\n"
+	for name, read := range map[string]func() ([]ScriptMessage, error){
+		"po":  func() ([]ScriptMessage, error) { return ReadMessagesPO(strings.NewReader(po)) },
+		"csv": func() ([]ScriptMessage, error) { return ReadMessagesCSV(strings.NewReader(csv)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			messages, err := read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != 2 || messages[0].Color != nil || messages[1].Color == nil || *messages[1].Color != 3 {
+				t.Fatalf("read %v", messages)
+			}
+
+			header := newMessagesHeader(t)
+			if _, changed, err := header.EncodeWithMessages(1, messages, ASCII); err != nil || !changed {
+				t.Fatalf("changed %v, %v", changed, err)
+			}
+			if plate := messageAt(header, 0); plate.Text != "Platte" || plate.Color != 12 {
+				t.Errorf("message without a color imported as %q color %d", plate.Text, plate.Color)
+			}
+			if hello := messageAt(header, 1); hello.Text != "Hello\rworld" || hello.Color != 3 {
+				t.Errorf("recolored message imported as %q color %d", hello.Text, hello.Color)
+			}
+		})
+	}
+}
+
+func TestMessagesUnchanged(t *testing.T) {
+	header := newMessagesHeader(t)
+	messages := ExtractMessages(1, header, ASCII)
+	messages[0].Color = nil
+	if data, changed, err := header.EncodeWithMessages(1, messages, ASCII); err != nil || changed || data != nil {
+		t.Errorf("unchanged messages encoded: %v, %v", changed, err)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package formats
 
 import (
 	"fmt"
@@ -13,6 +13,7 @@ type FileEntry struct {
 	FileName string
 }
 
+// UnPak loads every file of the PAK archives in the folder into memory, keyed by the upper case file name.
 func UnPak(folder string) (map[string]*[]byte, error) {
 	files, err := os.ReadDir(folder)
 	if err != nil {
@@ -21,7 +22,6 @@ func UnPak(folder string) (map[string]*[]byte, error) {
 
 	result := make(map[string]*[]byte)
 
-	fmt.Printf("UnPAKing Phase\n")
 	for _, file := range files {
 		if file.IsDir() {
 			// skip folders
@@ -30,7 +30,10 @@ func UnPak(folder string) (map[string]*[]byte, error) {
 
 		ext := strings.ToUpper(filepath.Ext(file.Name()))
 		if ext == ".PAK" {
-			r := unpak(filepath.Join(folder, file.Name()))
+			r, err := unpak(filepath.Join(folder, file.Name()))
+			if err != nil {
+				return nil, err
+			}
 			maps.Copy(result, r)
 		}
 	}
@@ -38,11 +41,10 @@ func UnPak(folder string) (map[string]*[]byte, error) {
 	return result, nil
 }
 
-func unpak(fileName string) map[string]*[]byte {
+func unpak(fileName string) (map[string]*[]byte, error) {
 	data, err := os.ReadFile(fileName)
 	if err != nil {
-		fmt.Printf("Failed to open %s. (%s)\n", fileName, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to open %s: %w", fileName, err)
 	}
 
 	var fileEntries []FileEntry
@@ -68,7 +70,6 @@ func unpak(fileName string) map[string]*[]byte {
 		i++
 
 		fileEntries = append(fileEntries, FileEntry{Offset: offset, FileName: fileName})
-		fmt.Printf("Extracting %s...\n", fileName)
 	}
 
 	for j, fileEntry := range fileEntries {
@@ -85,5 +86,5 @@ func unpak(fileName string) map[string]*[]byte {
 		result[entryName] = &content
 	}
 
-	return result
+	return result, nil
 }
@@ -0,0 +1,86 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ItemSprite is a floor sprite of an item picture, a large one of ITEML1.CPS or a small one of ITEMS1.CPS.
+// The game takes it from a table of its executable, not from the data files, so the table is read from a sprite
+// map file.
+type ItemSprite struct {
+	Large bool
+	Index int
+}
+
+func (s ItemSprite) String() string {
+	if s.Large {
+		return "L" + strconv.Itoa(s.Index)
+	}
+	return "S" + strconv.Itoa(s.Index)
+}
+
+// ReadItemSprites reads a sprite map file, one line per item picture with the picture and its sprite, L or S
+// followed by the index in the sheet, eg. "12 L3". Text after ; is a comment.
+func ReadItemSprites(r io.Reader) (map[int]ItemSprite, error) {
+	sprites := map[int]ItemSprite{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), ";")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a picture and a sprite", line)
+		}
+		picture, err := strconv.Atoi(fields[0])
+		if err != nil || picture < 0 {
+			return nil, fmt.Errorf("line %d: invalid picture %s", line, fields[0])
+		}
+		sprite, err := parseItemSprite(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, ok := sprites[picture]; ok {
+			return nil, fmt.Errorf("line %d: picture %d is mapped twice", line, picture)
+		}
+		sprites[picture] = sprite
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sprites, nil
+}
+
+func parseItemSprite(text string) (ItemSprite, error) {
+	sprite := ItemSprite{}
+	switch {
+	case strings.HasPrefix(text, "L"):
+		sprite.Large = true
+	case !strings.HasPrefix(text, "S"):
+		return sprite, fmt.Errorf("invalid sprite %s, expected L or S and an index", text)
+	}
+	index, err := strconv.Atoi(text[1:])
+	if err != nil || index < 0 {
+		return sprite, fmt.Errorf("invalid sprite %s, expected L or S and an index", text)
+	}
+	sprite.Index = index
+	return sprite, nil
+}
+
+// LoadItemSprites reads a sprite map file, see ReadItemSprites.
+func LoadItemSprites(name string) (map[int]ItemSprite, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadItemSprites(file)
+}
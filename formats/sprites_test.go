@@ -0,0 +1,30 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadItemSprites(t *testing.T) {
+	sprites, err := ReadItemSprites(strings.NewReader("; floor sprites\n0 L0\n\n12 S3 ; dagger\n13 L14\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]ItemSprite{0: {Large: true}, 12: {Index: 3}, 13: {Large: true, Index: 14}}
+	if len(sprites) != len(want) {
+		t.Errorf("%d sprites instead of %d", len(sprites), len(want))
+	}
+	for picture, sprite := range want {
+		if sprites[picture] != sprite {
+			t.Errorf("picture %d: %s instead of %s", picture, sprites[picture], sprite)
+		}
+	}
+}
+
+func TestReadItemSpritesErrors(t *testing.T) {
+	for _, text := range []string{"12", "12 L3 S4", "x L3", "-1 L3", "12 3", "12 M3", "12 L", "12 S-1", "12 L3\n12 S4"} {
+		if _, err := ReadItemSprites(strings.NewReader(text)); err == nil {
+			t.Errorf("%q read", text)
+		}
+	}
+}
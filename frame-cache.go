@@ -0,0 +1,106 @@
+package main
+
+import (
+	"EOB1MazeViewer/postprocess"
+	"container/list"
+	"sync"
+)
+
+// frameKey identifies a scaled view.
+type frameKey struct {
+	level           string
+	x, y, direction int
+	scaler          string
+	postprocess     postprocess.Options
+	screen          bool
+	message         string
+	messageColor    byte
+}
+
+type frameCacheEntry struct {
+	key   frameKey
+	frame *scaledFrame
+}
+
+// frameCache keeps the most recently used scaled views within a memory budget.
+// Frames rendered before the last invalidate are dropped by put, so a slow worker cannot bring back outdated views.
+type frameCache struct {
+	mutex      sync.Mutex
+	budget     int
+	used       int
+	generation int
+	entries    map[frameKey]*list.Element
+	lru        *list.List
+}
+
+func newFrameCache(budget int) *frameCache {
+	return &frameCache{
+		budget:  budget,
+		entries: make(map[frameKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (fc *frameCache) get(key frameKey) (*scaledFrame, bool) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	element, ok := fc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	fc.lru.MoveToFront(element)
+	return element.Value.(*frameCacheEntry).frame, true
+}
+
+func (fc *frameCache) contains(key frameKey) bool {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	_, ok := fc.entries[key]
+	return ok
+}
+
+// put stores a frame rendered in the given generation and evicts the least recently used frames over the budget.
+func (fc *frameCache) put(key frameKey, frame *scaledFrame, generation int) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	if generation != fc.generation || frame.size() > fc.budget {
+		return
+	}
+	if element, ok := fc.entries[key]; ok {
+		fc.remove(element)
+	}
+
+	fc.entries[key] = fc.lru.PushFront(&frameCacheEntry{key: key, frame: frame})
+	fc.used += frame.size()
+	for fc.used > fc.budget {
+		fc.remove(fc.lru.Back())
+	}
+}
+
+func (fc *frameCache) remove(element *list.Element) {
+	entry := fc.lru.Remove(element).(*frameCacheEntry)
+	delete(fc.entries, entry.key)
+	fc.used -= entry.frame.size()
+}
+
+// getGeneration returns the current generation, to be given to put after rendering.
+func (fc *frameCache) getGeneration() int {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	return fc.generation
+}
+
+// invalidate drops every frame, e.g. after the visible layers have changed.
+func (fc *frameCache) invalidate() {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	fc.generation++
+	fc.entries = make(map[frameKey]*list.Element)
+	fc.lru.Init()
+	fc.used = 0
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"EOB1MazeViewer/renderer"
+	"github.com/hajimehoshi/ebiten/v2"
+	"image"
+	"math"
+)
+
+const (
+	freeRoamMoveSpeed  = 0.05 // blocks per tick
+	freeRoamTurnSpeed  = 0.04 // radians per tick
+	freeRoamMouseSpeed = 0.005
+	freeRoamWallMargin = 0.2
+)
+
+// freeRoam glides through the level with the raycaster, the grid position of the Game is
+// only updated when leaving the mode.
+type freeRoam struct {
+	raycaster         *renderer.Raycaster
+	posX, posY, angle float64
+	lastCursorX       int
+	changed           bool
+}
+
+func newFreeRoam(raycaster *renderer.Raycaster, x, y, direction int) *freeRoam {
+	cursorX, _ := ebiten.CursorPosition()
+	return &freeRoam{
+		raycaster:   raycaster,
+		posX:        float64(x) + 0.5,
+		posY:        float64(y) + 0.5,
+		angle:       float64(direction) * math.Pi / 2,
+		lastCursorX: cursorX,
+		changed:     true,
+	}
+}
+
+func (fr *freeRoam) update() {
+	forward, strafe := 0.0, 0.0
+	if ebiten.IsKeyPressed(ebiten.KeyW) {
+		forward += freeRoamMoveSpeed
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyS) {
+		forward -= freeRoamMoveSpeed
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyD) {
+		strafe += freeRoamMoveSpeed
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyA) {
+		strafe -= freeRoamMoveSpeed
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyE) {
+		fr.turn(freeRoamTurnSpeed)
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyQ) {
+		fr.turn(-freeRoamTurnSpeed)
+	}
+
+	// Mouse-look
+	cursorX, _ := ebiten.CursorPosition()
+	if cursorX != fr.lastCursorX {
+		fr.turn(float64(cursorX-fr.lastCursorX) * freeRoamMouseSpeed)
+		fr.lastCursorX = cursorX
+	}
+
+	if forward != 0 || strafe != 0 {
+		dirX, dirY := math.Sin(fr.angle), -math.Cos(fr.angle)
+		fr.move(dirX*forward-dirY*strafe, dirY*forward+dirX*strafe)
+	}
+}
+
+func (fr *freeRoam) turn(delta float64) {
+	fr.angle = math.Mod(fr.angle+delta+2*math.Pi, 2*math.Pi)
+	fr.changed = true
+}
+
+// move moves separately along both axes, so the party slides along the walls.
+func (fr *freeRoam) move(dx, dy float64) {
+	if fr.canMoveTo(fr.posX+dx, fr.posY) {
+		fr.posX += dx
+		fr.changed = true
+	}
+	if fr.canMoveTo(fr.posX, fr.posY+dy) {
+		fr.posY += dy
+		fr.changed = true
+	}
+}
+
+func (fr *freeRoam) canMoveTo(x, y float64) bool {
+	left, top := math.Floor(fr.posX), math.Floor(fr.posY)
+	blockX, blockY := int(left), int(top)
+
+	// keep a margin from the walls of the current block
+	if x < fr.posX && x-left < freeRoamWallMargin && !fr.raycaster.IsPassable(blockX-1, blockY, 1) {
+		return false
+	}
+	if x > fr.posX && left+1-x < freeRoamWallMargin && !fr.raycaster.IsPassable(blockX+1, blockY, 3) {
+		return false
+	}
+	if y < fr.posY && y-top < freeRoamWallMargin && !fr.raycaster.IsPassable(blockX, blockY-1, 2) {
+		return false
+	}
+	if y > fr.posY && top+1-y < freeRoamWallMargin && !fr.raycaster.IsPassable(blockX, blockY+1, 0) {
+		return false
+	}
+	return true
+}
+
+func (fr *freeRoam) render() *image.Paletted {
+	fr.changed = false
+	return fr.raycaster.Render(fr.posX, fr.posY, fr.angle, nil)
+}
+
+// gridPosition returns the block and the nearest direction of the free-roam position.
+func (fr *freeRoam) gridPosition() (int, int, int) {
+	direction := int(math.Round(fr.angle/(math.Pi/2))) & 0x03
+	return int(math.Floor(fr.posX)), int(math.Floor(fr.posY)), direction
+}
@@ -3,7 +3,6 @@ module EOB1MazeViewer
 go 1.21
 
 require (
-	github.com/elliotchance/orderedmap/v2 v2.2.0
 	github.com/hajimehoshi/ebiten/v2 v2.6.3
 	github.com/virtualparadox/xbrscaler v0.1.0
 	golang.org/x/exp v0.0.0-20220321173239-a90fa8a75705
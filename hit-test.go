@@ -0,0 +1,79 @@
+package main
+
+import (
+	"EOB1MazeViewer/renderer"
+	"EOB1MazeViewer/vm"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"log"
+)
+
+// cell is a block of the maze selected by clicking one of its walls.
+type cell struct {
+	x, y, side int
+}
+
+func (g *Game) toggleHitTesting() {
+	g.hitTesting = !g.hitTesting
+	g.hitBuffer = nil
+	g.prevDirection = -1
+}
+
+// hitUnderCursor returns what has been drawn at the mouse cursor, false outside the view.
+func (g *Game) hitUnderCursor() (renderer.HitInfo, bool) {
+	if g.hitBuffer == nil || g.transition != nil {
+		return renderer.HitInfo{}, false
+	}
+	x, y := g.cursorToView()
+	if x < 0 || y < 0 || x >= 176 || y >= 120 {
+		return renderer.HitInfo{}, false
+	}
+	return g.hitBuffer.At(x, y), true
+}
+
+// updateSelection selects the block of the wall under the cursor on a left click. Like in the game, only a click
+// on the block in front of the party runs its wall click scripts.
+func (g *Game) updateSelection() {
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return
+	}
+	info, ok := g.hitUnderCursor()
+	if !ok || info.Record == nil || info.Record.Side < 0 {
+		return
+	}
+
+	g.selectedCell = &cell{x: info.Record.MazeX, y: info.Record.MazeY, side: info.Record.Side}
+	log.Printf("Selected cell X=%d Y=%d Side=%d", g.selectedCell.x, g.selectedCell.y, g.selectedCell.side)
+	if x, y := step(g.x, g.y, g.direction); g.selectedCell.x == x && g.selectedCell.y == y {
+		g.fireEvent(x, y, vm.EventWallClick)
+	}
+}
+
+func (g *Game) hitText() string {
+	text := ""
+	if g.selectedCell != nil {
+		text += fmt.Sprintf("\nSelected X=%d Y=%d Side=%d", g.selectedCell.x, g.selectedCell.y, g.selectedCell.side)
+	}
+
+	info, ok := g.hitUnderCursor()
+	if !ok {
+		return text
+	}
+	text += fmt.Sprintf("\nPalette index: %d", info.PaletteIndex)
+	record := info.Record
+	if record == nil {
+		return text + "\nBackground"
+	}
+
+	text += fmt.Sprintf("\nLayer: %s Row: %d Render position: %d", record.Layer, record.Row, record.RenderPosition)
+	text += fmt.Sprintf("\nMaze X=%d Y=%d Side=%d", record.MazeX, record.MazeY, record.Side)
+	if record.Item >= 0 {
+		return text + fmt.Sprintf("\nItem: %d", record.Item)
+	}
+	text += fmt.Sprintf("\nWall mapping: %d", record.WallMappingIndex)
+	if record.DecorationId >= 0 {
+		text += fmt.Sprintf("\nDecoration: %d Rectangle: %d", record.DecorationId, record.Rectangle)
+	}
+	return text
+}
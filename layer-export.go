@@ -0,0 +1,65 @@
+package main
+
+import (
+	"EOB1MazeViewer/renderer"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+)
+
+// layerKeys maps the number keys to the layers they show or hide.
+var layerKeys = []struct {
+	key   ebiten.Key
+	layer renderer.LayerMask
+}{
+	{ebiten.KeyDigit1, renderer.LayerBackground},
+	{ebiten.KeyDigit2, renderer.LayerWallsRow3},
+	{ebiten.KeyDigit3, renderer.LayerWallsRow2},
+	{ebiten.KeyDigit4, renderer.LayerWallsRow1},
+	{ebiten.KeyDigit5, renderer.LayerWallsRow0},
+	{ebiten.KeyDigit6, renderer.LayerWallsFar},
+	{ebiten.KeyDigit7, renderer.LayerDecorations},
+	{ebiten.KeyDigit8, renderer.LayerItems},
+}
+
+// exportLayers writes every layer of the current view into a separate paletted PNG,
+// 0x00 is transparent in all layers but the background.
+func exportLayers(mazeRenderer *renderer.MazeRenderer, x, y, direction int) error {
+	layers, err := mazeRenderer.RenderLayers(x, y, direction)
+	if err != nil {
+		return err
+	}
+
+	palette := mazeRenderer.GetPalette()
+	transparentPalette := make(color.Palette, len(palette))
+	copy(transparentPalette, palette)
+	transparentPalette[0] = color.RGBA{}
+
+	for _, layer := range renderer.Layers {
+		layerPalette := transparentPalette
+		if layer == renderer.LayerBackground {
+			layerPalette = palette
+		}
+
+		fileName := fmt.Sprintf("layer-%d-%d-%d-%s.png", x, y, direction, layer)
+		if err := writePalettedPNG(fileName, renderer.BytesToPalettedImage(layers.Composite(layer), 176, 120, layerPalette)); err != nil {
+			return err
+		}
+		log.Printf("%s written.", fileName)
+	}
+	return nil
+}
+
+func writePalettedPNG(fileName string, img *image.Paletted) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return png.Encode(file, img)
+}
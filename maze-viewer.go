@@ -2,14 +2,20 @@ package main
 
 import (
 	dat2 "EOB1MazeViewer/formats"
+	"EOB1MazeViewer/postprocess"
 	"EOB1MazeViewer/renderer"
+	"EOB1MazeViewer/scaler"
+	"EOB1MazeViewer/vm"
+	"flag"
 	"fmt"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/virtualparadox/xbrscaler"
+	"image"
 	_ "image/png"
 	"log"
+	"math"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 
@@ -17,71 +23,257 @@ import (
 )
 
 const (
-	zoom         = 4
-	screenWidth  = 176 * zoom
-	screenHeight = 120 * zoom
-
 	frameWidth  = 32
 	frameHeight = 32
 )
 
 type Game struct {
-	xbrscaler                   *xbrscaler.Xbr
+	presenter                   *presenter
+	scaler                      scaler.Scaler
+	postprocess                 postprocess.Options
+	screenCompositor            *renderer.ScreenCompositor
+	showScreen                  bool
+	textRenderer                *renderer.TextRenderer
+	message                     string
+	messageColor                byte
+	canvas                      *ebiten.Image
+	placement                   viewPlacement
+	level                       string
+	frameCache                  *frameCache
+	prefetcher                  *prefetcher
 	mazeRenderer                *renderer.MazeRenderer
 	x, y, direction             int
 	prevX, prevY, prevDirection int
 	mazeView                    *ebiten.Image
+	transition                  *transition
+	transitionFrames            int
+	bufferedActions             []action
+	raycaster                   *renderer.Raycaster
+	freeRoam                    *freeRoam
+	hitTesting                  bool
+	hitBuffer                   *renderer.HitBuffer
+	selectedCell                *cell
+	machine                     *vm.Machine
+	scriptMessages              []string
+}
+
+var actionKeys = []struct {
+	key    ebiten.Key
+	action action
+}{
+	{ebiten.KeyW, actionForward},
+	{ebiten.KeyS, actionBackward},
+	{ebiten.KeyA, actionStrafeLeft},
+	{ebiten.KeyD, actionStrafeRight},
+	{ebiten.KeyQ, actionTurnLeft},
+	{ebiten.KeyE, actionTurnRight},
 }
 
 func (g *Game) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		g.toggleFreeRoam()
+	}
 
-	// Move
-	if inpututil.IsKeyJustPressed(ebiten.KeyW) {
-		g.moveInMaze(g.direction)
+	for _, layerKey := range layerKeys {
+		if inpututil.IsKeyJustPressed(layerKey.key) {
+			g.mazeRenderer.SetVisibleLayers(g.mazeRenderer.GetVisibleLayers() ^ layerKey.layer)
+			g.frameCache.invalidate()
+			g.prevDirection = -1
+		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
-		g.moveInMaze((g.direction + 2) & 0x03)
+	if inpututil.IsKeyJustPressed(ebiten.KeyX) {
+		g.cycleScaler()
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyA) {
-		g.moveInMaze((g.direction - 1) & 0x03)
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		g.toggleCRT()
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyD) {
-		g.moveInMaze((g.direction + 1) & 0x03)
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		g.toggleAspectCorrection()
 	}
 
-	// Turn
-	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
-		g.direction = (g.direction + 1) & 0x03
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		g.toggleScreen()
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
-		g.direction = (g.direction - 1) & 0x03
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		toggleFullscreen()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		g.toggleHitTesting()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		if err := exportLayers(g.mazeRenderer, g.x, g.y, g.direction); err != nil {
+			log.Printf("Cannot export layers: %s", err)
+		}
+	}
+
+	if g.freeRoam != nil {
+		g.freeRoam.update()
+		if g.freeRoam.changed {
+			_, _, direction := g.freeRoam.gridPosition()
+			g.presentFrame(g.freeRoam.render(), direction)
+		}
+		return nil
+	}
+
+	for _, actionKey := range actionKeys {
+		if !inpututil.IsKeyJustPressed(actionKey.key) {
+			continue
+		}
+
+		// Input arriving during an animation is played after it
+		if g.transition != nil {
+			if len(g.bufferedActions) < maxBufferedActions {
+				g.bufferedActions = append(g.bufferedActions, actionKey.action)
+			}
+			continue
+		}
+		g.applyAction(actionKey.action)
+	}
+
+	if g.transition == nil {
+		g.updateWallClick()
+	}
+
+	if g.transition != nil && g.transition.advance() {
+		g.transition = nil
+		if len(g.bufferedActions) > 0 {
+			next := g.bufferedActions[0]
+			g.bufferedActions = g.bufferedActions[1:]
+			g.applyAction(next)
+		}
 	}
 
 	if g.needUpdate() {
 		g.updateMazeView()
 	}
 
+	if g.hitTesting {
+		g.updateSelection()
+	}
+
 	return nil
 }
 
-func (g *Game) updateMazeView() {
-	renderedImage, _ := g.mazeRenderer.RenderMaze(g.x, g.y, g.direction)
-	palettedImage := BytesToPalettedImage(renderedImage, 176, 120, g.mazeRenderer.Palette.GetPalette())
-	rgbaImage := ConvertPalettedToRGBA(palettedImage, true)
-	arrayImage := ConvertRGBAtoUint32Array(rgbaImage)
-	scaledImage, scaledWidth, scaledHeight := g.xbrscaler.Xbr4x(arrayImage, 176, 120, true, true)
+func (g *Game) applyAction(a action) {
+	from := g.mazeView
+
+	switch a {
+	// Move
+	case actionForward:
+		g.moveInMaze(g.direction)
+	case actionBackward:
+		g.moveInMaze((g.direction + 2) & 0x03)
+	case actionStrafeLeft:
+		g.moveInMaze((g.direction - 1) & 0x03)
+	case actionStrafeRight:
+		g.moveInMaze((g.direction + 1) & 0x03)
+	// Turn
+	case actionTurnRight:
+		g.direction = (g.direction + 1) & 0x03
+	case actionTurnLeft:
+		g.direction = (g.direction - 1) & 0x03
+	}
 
-	g.mazeView = ConvertUint32ArrayToEbitenImage(scaledImage, scaledWidth, scaledHeight)
+	if !g.needUpdate() {
+		return
+	}
+	g.updateMazeView()
+
+	if g.transitionFrames > 0 && from != nil {
+		g.transition = newTransition(from, g.mazeView, a, g.transitionFrames)
+	}
+}
+
+func (g *Game) toggleFreeRoam() {
+	if g.freeRoam == nil {
+		g.transition = nil
+		g.bufferedActions = nil
+		g.freeRoam = newFreeRoam(g.raycaster, g.x, g.y, g.direction)
+		ebiten.SetCursorMode(ebiten.CursorModeCaptured)
+		return
+	}
+
+	// Back to the grid, snapped to the nearest block and direction
+	g.x, g.y, g.direction = g.freeRoam.gridPosition()
+	g.freeRoam = nil
+	g.prevDirection = -1
+	ebiten.SetCursorMode(ebiten.CursorModeVisible)
+}
+
+func (g *Game) updateMazeView() {
+	if g.hitTesting {
+		renderedImage, hitBuffer, _ := g.mazeRenderer.RenderMazeWithHitBuffer(g.x, g.y, g.direction, nil)
+		g.hitBuffer = hitBuffer
+		g.presentFrame(renderedImage, g.direction)
+	} else {
+		key := g.getFrameKey(g.x, g.y, g.direction)
+		frame, ok := g.frameCache.get(key)
+		if !ok {
+			generation := g.frameCache.getGeneration()
+			frame = g.renderFrame(key)
+			g.frameCache.put(key, frame, generation)
+		}
+		g.mazeView = g.presenter.upload(frame)
+		g.prefetchNeighbours()
+	}
 
 	g.prevX = g.x
 	g.prevY = g.y
 	g.prevDirection = g.direction
 }
 
+func (g *Game) getFrameKey(x, y, direction int) frameKey {
+	return frameKey{level: g.level, x: x, y: y, direction: direction, scaler: g.scaler.Name(), postprocess: g.postprocess, screen: g.showScreen,
+		message: g.message, messageColor: g.messageColor}
+}
+
+// renderFrame renders and scales a view, it is called from the prefetch workers as well.
+func (g *Game) renderFrame(key frameKey) *scaledFrame {
+	renderedImage, _ := g.mazeRenderer.RenderMaze(key.x, key.y, key.direction, nil)
+	if key.screen {
+		renderedImage = g.screenCompositor.Compose(renderedImage, key.direction, nil)
+	}
+	g.drawMessage(renderedImage, key.message, key.messageColor)
+	s, _ := scaler.ByName(key.scaler)
+	return g.presenter.scale(renderedImage, s, key.postprocess)
+}
+
+// prefetchNeighbours requests the views reachable with a single move or turn.
+func (g *Game) prefetchNeighbours() {
+	if g.prefetcher == nil {
+		return
+	}
+	for direction := 0; direction < 4; direction++ {
+		x, y := step(g.x, g.y, direction)
+		g.prefetcher.request(g.getFrameKey(x, y, g.direction))
+	}
+	g.prefetcher.request(g.getFrameKey(g.x, g.y, (g.direction+1)&0x03))
+	g.prefetcher.request(g.getFrameKey(g.x, g.y, (g.direction-1)&0x03))
+}
+
+// presentFrame shows a view rendered outside the frame cache, direction is used for the compass of the game screen.
+func (g *Game) presentFrame(renderedImage *image.Paletted, direction int) {
+	if g.showScreen {
+		renderedImage = g.screenCompositor.Compose(renderedImage, direction, nil)
+	}
+	g.drawMessage(renderedImage, g.message, g.messageColor)
+	g.mazeView = g.presenter.present(renderedImage, g.scaler, g.postprocess)
+}
+
+// drawMessage writes a message into the text area of the game screen or over the view.
+func (g *Game) drawMessage(renderedImage *image.Paletted, message string, color byte) {
+	if message == "" || g.textRenderer == nil {
+		return
+	}
+	g.textRenderer.DrawMessage(renderedImage, message, color)
+}
+
 func (g *Game) needUpdate() bool {
 	return g.x != g.prevX || g.y != g.prevY || g.direction != g.prevDirection
 }
@@ -91,85 +283,192 @@ func (g *Game) moveInMaze(direction int) {
 	g.prevY = g.y
 	g.prevDirection = g.direction
 
+	// a leave script moving the party cancels the step
+	if g.fireEvent(g.x, g.y, vm.EventLeave) {
+		return
+	}
+	x, y := step(g.x, g.y, direction)
+	g.x, g.y = x, y
+	g.fireEvent(x, y, vm.EventEnter)
+}
+
+// step returns the neighbouring block in the direction.
+func step(x, y, direction int) (int, int) {
 	switch direction {
 	case 0:
-		g.y--
+		y--
 	case 1:
-		g.x++
+		x++
 	case 2:
-		g.y++
+		y++
 	case 3:
-		g.x--
+		x--
 	}
+	return x, y
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(-float64(frameWidth)/2, -float64(frameHeight)/2)
-	op.GeoM.Translate(screenWidth/2, screenHeight/2)
-
 	if g.mazeView != nil {
-		screen.DrawImage(g.mazeView, &ebiten.DrawImageOptions{})
+		canvas := g.getCanvas()
+		canvas.Clear()
+		if g.transition != nil {
+			g.transition.draw(canvas)
+		} else {
+			canvas.DrawImage(g.mazeView, &ebiten.DrawImageOptions{})
+		}
+		g.drawToWindow(screen, canvas)
+	}
+
+	if g.freeRoam != nil {
+		ebitenutil.DebugPrint(screen, fmt.Sprintf("Free roam X=%.2f Y=%.2f Angle=%.0f", g.freeRoam.posX, g.freeRoam.posY, g.freeRoam.angle*180/math.Pi))
+	} else {
+		ebitenutil.DebugPrint(screen, "X="+strconv.Itoa(g.x)+" Y="+strconv.Itoa(g.y)+" Direction="+strconv.Itoa(g.direction)+g.hiddenLayersText()+g.hitText())
+	}
+}
+
+func (g *Game) hiddenLayersText() string {
+	var hidden []string
+	for _, layer := range renderer.Layers {
+		if g.mazeRenderer.GetVisibleLayers()&layer == 0 {
+			hidden = append(hidden, layer.String())
+		}
+	}
+	if len(hidden) == 0 {
+		return ""
 	}
-	ebitenutil.DebugPrint(screen, "X="+strconv.Itoa(g.x)+" Y="+strconv.Itoa(g.y)+" Direction="+strconv.Itoa(g.direction))
+	return "\nHidden: " + strings.Join(hidden, ", ")
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
+	return outsideWidth, outsideHeight
 }
 
 func main() {
-	args := os.Args
-
-	if len(args) != 3 {
-		fmt.Printf("Usage: maze-viewer EOB1DATA_DIR LEVEL\neg: maze-viewer /home/joe/EOB1 8")
+	shading := flag.String("shading", "none", "distance shading curve: none, linear or exponential")
+	falloff := flag.Float64("falloff", 0, fmt.Sprintf("darkening step (linear, default %g) or brightness factor (exponential, default %g) per distance row",
+		renderer.DefaultLinearStep, renderer.DefaultExponentialFactor))
+	viewDepth := flag.Int("view-depth", renderer.DefaultViewCone.Depth, "number of visible block rows in front of the party, 3 to 10")
+	viewWidth := flag.Int("view-width", renderer.DefaultViewCone.Width, "number of visible blocks in the rows beyond the original view, odd, 7 to 31")
+	transitionMs := flag.Int("transition-ms", 0, "duration of the animated step and turn transitions in milliseconds, 0 disables them")
+	cacheMb := flag.Int("cache-mb", 64, "memory budget of the frame cache in megabytes")
+	scalerName := flag.String("scaler", scaler.Xbr4x.Name(), "upscaler: "+strings.Join(scaler.Names(), ", "))
+	crt := flag.Bool("crt", false, "CRT filter with scanlines, slot mask and bloom")
+	screen := flag.Bool("screen", false, "show the view inside the 320x200 game screen with the playfield frame and the compass")
+	aspect := flag.Bool("aspect", false, "stretch the view vertically by 1.2 like on the 4:3 monitors of the time")
+	message := flag.String("message", "", "text shown in the message area with the game's font")
+	messageColor := flag.Int("message-color", 15, "palette index of the message text")
+	scripts := flag.Bool("scripts", true, "run the level scripts when entering and leaving blocks and clicking walls")
+	partyFile := flag.String("party", "", "JSON file with the party the script conditions are evaluated with")
+	itemSpritesName := flag.String("item-sprites", "", "sprite map file selecting the floor sprites of the item pictures, without it items lie on the floor as icons")
+	prefetchWorkers := flag.Int("prefetch-workers", runtime.NumCPU(), "number of goroutines rendering the neighbouring views ahead of time, 0 disables prefetching")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Printf("Usage: maze-viewer [flags] EOB1DATA_DIR LEVEL\neg: maze-viewer /home/joe/EOB1 8\n")
+		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	dataFiles := loadDataFiles(args)
-	mazeRenderer := initMazeRenderer(args, dataFiles)
-	xbrScaler := xbrscaler.NewXbrScaler(false)
+	viewScaler, err := scaler.ByName(*scalerName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dataFiles := loadDataFiles(flag.Arg(0))
+	mazeRenderer := initMazeRenderer(flag.Arg(1), dataFiles, *itemSpritesName)
+	viewCone := renderer.ViewCone{Depth: *viewDepth, Width: *viewWidth}
+	if err := mazeRenderer.SetViewCone(viewCone); err != nil {
+		log.Fatal(err)
+	}
+	mazeRenderer.SetDistanceShading(initDistanceShading(mazeRenderer, viewCone, *shading, *falloff))
 
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
-	ebiten.SetWindowSize(screenWidth*2, screenHeight*2)
+	ebiten.SetWindowSize(renderer.ViewportWidth*defaultWindowScale, renderer.ViewportHeight*defaultWindowScale)
 	ebiten.SetWindowTitle("EOB1 - Maze Viewer")
 
-	if err := ebiten.RunGame(&Game{
-		xbrscaler:    xbrScaler,
-		mazeRenderer: mazeRenderer,
-		x:            10,
-		y:            15,
-		direction:    0}); err != nil {
+	game := &Game{
+		presenter:        newPresenter(mazeRenderer.GetPalette(), noTransparency),
+		scaler:           viewScaler,
+		postprocess:      postprocess.Options{AspectCorrection: *aspect, CRT: *crt},
+		screenCompositor: renderer.NewScreenCompositor(dataFiles, mazeRenderer.GetPalette()),
+		showScreen:       *screen,
+		textRenderer:     initTextRenderer(dataFiles),
+		message:          *message,
+		messageColor:     byte(*messageColor),
+		level:            flag.Arg(1),
+		frameCache:       newFrameCache(*cacheMb << 20),
+		mazeRenderer:     mazeRenderer,
+		raycaster:        renderer.NewRaycaster(mazeRenderer),
+		transitionFrames: *transitionMs * ebiten.TPS() / 1000,
+		x:                10,
+		y:                15,
+		direction:        0,
+	}
+	if *scripts {
+		game.machine = initMachine(flag.Arg(1), mazeRenderer, dataFiles, *partyFile)
+	}
+	if *prefetchWorkers > 0 {
+		game.prefetcher = newPrefetcher(game.frameCache, *prefetchWorkers, game.renderFrame)
+	}
+
+	if err := ebiten.RunGame(game); err != nil {
 		log.Fatal(err)
 	}
 
 }
 
-func initMazeRenderer(args []string, dataFiles map[string]*[]byte) *renderer.MazeRenderer {
-	infName := "LEVEL" + args[2] + ".INF"
-	_, ok := dataFiles[infName]
-	if !ok {
-		log.Fatalf("Cannot find file %s", infName)
+// initDistanceShading returns the shading of a curve, a falloff of 0 selects the default of the curve. Other
+// falloffs have to be in (0,1], a larger or negative one would brighten distant rows or make them black.
+func initDistanceShading(mazeRenderer *renderer.MazeRenderer, viewCone renderer.ViewCone, shading string, falloff float64) *renderer.DistanceShading {
+	if falloff < 0 || falloff > 1 {
+		log.Fatalf("Invalid falloff %g, it has to be greater than 0 and at most 1", falloff)
 	}
+	rows := viewCone.Depth + 1
+	switch shading {
+	case "none":
+		return nil
+	case "linear":
+		if falloff == 0 {
+			falloff = renderer.DefaultLinearStep
+		}
+		return renderer.NewDistanceShading(mazeRenderer.Palette, rows, renderer.LinearFalloff(falloff))
+	case "exponential":
+		if falloff == 0 {
+			falloff = renderer.DefaultExponentialFactor
+		}
+		return renderer.NewDistanceShading(mazeRenderer.Palette, rows, renderer.ExponentialFalloff(falloff))
+	default:
+		log.Fatalf("Unknown shading curve %s", shading)
+	}
+	return nil
+}
 
-	inf, _ := dat2.NewInfFromByteArray(dataFiles[infName])
-
-	mazName := "LEVEL" + args[2] + ".MAZ"
-	maz, _ := dat2.NewMazFromByteArray(dataFiles[mazName])
-	vcn, _ := dat2.NewVCNFromByteArray(dataFiles[strings.ToUpper(inf.VmpVcnName)+".VCN"])
-	vmp, _ := dat2.NewVMPFromByteArray(dataFiles[strings.ToUpper(inf.VmpVcnName)+".VMP"])
-	pal, _ := dat2.NewPALFromByteArray(dataFiles[strings.ToUpper(inf.PaletteName)+".PAL"])
-
-	decorationCPSNames := inf.GetDecorationCPSNames()
-	dat, _ := dat2.NewDATFromByteArray(dataFiles[strings.ToUpper(inf.VmpVcnName)+".DAT"])
-	decorationContainer := renderer.BuildDecorationContainer(dat, dataFiles, decorationCPSNames)
-
-	mazeRenderer := renderer.NewMazeRenderer(inf, maz, vcn, vmp, pal, decorationContainer)
+func initMazeRenderer(level string, dataFiles map[string]*[]byte, itemSpritesName string) *renderer.MazeRenderer {
+	var itemSprites map[int]dat2.ItemSprite
+	if itemSpritesName != "" {
+		var err error
+		if itemSprites, err = dat2.LoadItemSprites(itemSpritesName); err != nil {
+			log.Fatal(err)
+		}
+	}
+	mazeRenderer, err := renderer.LoadLevel(dataFiles, level, itemSprites)
+	if err != nil {
+		log.Fatal(err)
+	}
 	return mazeRenderer
 }
 
-func loadDataFiles(args []string) map[string]*[]byte {
-	dataFiles, err := UnPak(args[1])
+func initTextRenderer(dataFiles map[string]*[]byte) *renderer.TextRenderer {
+	textRenderer, err := renderer.LoadTextRenderer(dataFiles)
+	if err != nil {
+		log.Printf("Messages are not shown: %s", err)
+		return nil
+	}
+	return textRenderer
+}
+
+func loadDataFiles(dataDir string) map[string]*[]byte {
+	dataFiles, err := dat2.UnPak(dataDir)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,111 @@
+package postprocess
+
+// Options selects the effects applied after the upscaler, pixels are packed as A<<24 | B<<16 | G<<8 | R
+// like in the scaler package.
+type Options struct {
+	AspectCorrection bool
+	CRT              bool
+}
+
+const (
+	// EOB1 ran in 320x200 on 4:3 monitors, its pixels were 1.2 times taller than wide
+	AspectRatio = 1.2
+
+	scanlineBrightness = 0.6
+	slotMaskBrightness = 0.8
+	bloomThreshold     = 160
+	bloomStrength      = 0.25
+)
+
+// Apply applies the selected effects, lineHeight is the height of a source pixel row after the upscaler.
+func Apply(pixels *[]uint32, width, height, lineHeight int, options Options) (*[]uint32, int, int) {
+	if options.AspectCorrection {
+		pixels, width, height = CorrectAspect(pixels, width, height)
+	}
+	if options.CRT {
+		scanlineHeight := float64(lineHeight)
+		if options.AspectCorrection {
+			scanlineHeight *= AspectRatio
+		}
+		pixels = CRT(pixels, width, height, scanlineHeight)
+	}
+	return pixels, width, height
+}
+
+// CorrectAspect stretches the image vertically by AspectRatio, interpolating between the rows.
+func CorrectAspect(pixels *[]uint32, width, height int) (*[]uint32, int, int) {
+	correctedHeight := int(float64(height)*AspectRatio + 0.5)
+	corrected := make([]uint32, width*correctedHeight)
+
+	for y := 0; y < correctedHeight; y++ {
+		sourceY := (float64(y)+0.5)/AspectRatio - 0.5
+		top := max(int(sourceY), 0)
+		bottom := min(top+1, height-1)
+		weight := max(sourceY-float64(top), 0)
+
+		for x := 0; x < width; x++ {
+			corrected[y*width+x] = mix((*pixels)[top*width+x], (*pixels)[bottom*width+x], weight)
+		}
+	}
+	return &corrected, width, correctedHeight
+}
+
+// CRT darkens the last line of every source pixel row (at least every other line), tints the columns
+// with a red, green and blue slot mask and lets the bright pixels bleed into their neighbours.
+func CRT(pixels *[]uint32, width, height int, lineHeight float64) *[]uint32 {
+	lineHeight = max(lineHeight, 2)
+	result := make([]uint32, width*height)
+
+	for y := 0; y < height; y++ {
+		row := (*pixels)[y*width : (y+1)*width]
+		scanline := int(float64(y+1)/lineHeight) != int(float64(y)/lineHeight)
+		for x := 0; x < width; x++ {
+			var channels [3]float64
+			for c := 0; c < 3; c++ {
+				channels[c] = float64(channel(row[x], c))
+				channels[c] += bloomStrength * (bloom(row, x-1, c) + bloom(row, x+1, c))
+				if x%3 != c {
+					channels[c] *= slotMaskBrightness
+				}
+				if scanline {
+					channels[c] *= scanlineBrightness
+				}
+			}
+			result[y*width+x] = pack(channels, row[x]>>24)
+		}
+	}
+	return &result
+}
+
+// bloom returns the part of a channel of a neighbouring pixel above the bloom threshold.
+func bloom(row []uint32, x int, c int) float64 {
+	if x < 0 || x >= len(row) {
+		return 0
+	}
+	return float64(max(int(channel(row[x], c))-bloomThreshold, 0))
+}
+
+func channel(pixel uint32, c int) uint32 {
+	return (pixel >> (8 * c)) & 0xFF
+}
+
+func pack(channels [3]float64, alpha uint32) uint32 {
+	pixel := alpha << 24
+	for c, value := range channels {
+		pixel |= uint32(min(max(value+0.5, 0), 255)) << (8 * c)
+	}
+	return pixel
+}
+
+// mix blends two pixels, weight is the part of b.
+func mix(a, b uint32, weight float64) uint32 {
+	if weight == 0 || a == b {
+		return a
+	}
+	var result uint32
+	for c := 0; c < 4; c++ {
+		ca, cb := float64((a>>(8*c))&0xFF), float64((b>>(8*c))&0xFF)
+		result |= uint32(ca+(cb-ca)*weight+0.5) << (8 * c)
+	}
+	return result
+}
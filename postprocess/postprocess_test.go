@@ -0,0 +1,117 @@
+package postprocess
+
+import "testing"
+
+// gray returns an opaque pixel with all channels set to value.
+func gray(value uint32) uint32 {
+	return 0xFF000000 | value<<16 | value<<8 | value
+}
+
+func filled(width, height int, pixel uint32) *[]uint32 {
+	pixels := make([]uint32, width*height)
+	for i := range pixels {
+		pixels[i] = pixel
+	}
+	return &pixels
+}
+
+func TestCorrectAspect(t *testing.T) {
+	tests := []struct{ height, want int }{{120, 144}, {200, 240}, {5, 6}, {1, 1}}
+	for _, test := range tests {
+		corrected, width, height := CorrectAspect(filled(3, test.height, gray(100)), 3, test.height)
+		if width != 3 || height != test.want || len(*corrected) != width*height {
+			t.Errorf("height %d: %dx%d, %d pixels", test.height, width, height, len(*corrected))
+			continue
+		}
+		for i, pixel := range *corrected {
+			if pixel != gray(100) {
+				t.Errorf("height %d: pixel %d of a uniform image is %08x", test.height, i, pixel)
+				break
+			}
+		}
+	}
+}
+
+func TestCorrectAspectGradient(t *testing.T) {
+	// one column from black to white in 5 rows
+	pixels := []uint32{gray(0), gray(50), gray(100), gray(150), gray(200)}
+	corrected, _, height := CorrectAspect(&pixels, 1, len(pixels))
+	if (*corrected)[0] != gray(0) || (*corrected)[height-1] != gray(200) {
+		t.Errorf("edges %08x and %08x", (*corrected)[0], (*corrected)[height-1])
+	}
+	for y := 1; y < height; y++ {
+		if channel((*corrected)[y], 0) <= channel((*corrected)[y-1], 0) {
+			t.Errorf("row %d not brighter than the one above: %v", y, *corrected)
+		}
+	}
+}
+
+func TestCRTScanlines(t *testing.T) {
+	const width, height = 6, 6
+	// below the bloom threshold every pixel is only darkened
+	result := CRT(filled(width, height, gray(100)), width, height, 3)
+	for y := 0; y < height; y++ {
+		brightness := 1.0
+		if y%3 == 2 {
+			brightness = scanlineBrightness
+		}
+		for x := 0; x < width; x++ {
+			pixel := (*result)[y*width+x]
+			for c := 0; c < 3; c++ {
+				want := 100 * brightness
+				if x%3 != c {
+					want *= slotMaskBrightness
+				}
+				if got := channel(pixel, c); got != uint32(want+0.5) {
+					t.Errorf("pixel %d,%d channel %d is %d instead of %g", x, y, c, got, want)
+				}
+			}
+			if pixel>>24 != 0xFF {
+				t.Errorf("pixel %d,%d lost its alpha: %08x", x, y, pixel)
+			}
+		}
+	}
+}
+
+func TestCRTMinimumLineHeight(t *testing.T) {
+	// unscaled images get a scanline every other line
+	result := CRT(filled(1, 4, gray(100)), 1, 4, 1)
+	for y, want := range []uint32{100, 60, 100, 60} {
+		if got := channel((*result)[y], 0); got != want {
+			t.Errorf("line %d: red %d instead of %d", y, got, want)
+		}
+	}
+}
+
+func TestCRTBloom(t *testing.T) {
+	pixels := []uint32{gray(0), gray(200), gray(0)}
+	result := CRT(&pixels, 3, 1, 2)
+	// 0.25 * (200 - 160), the red channel of the first column and the green one of the third are not masked
+	if got := channel((*result)[0], 0); got != 10 {
+		t.Errorf("left neighbour red %d instead of 10", got)
+	}
+	if got := channel((*result)[2], 2); got != 10 {
+		t.Errorf("right neighbour blue %d instead of 10", got)
+	}
+	if got := channel((*result)[1], 1); got != 200 {
+		t.Errorf("bright pixel green %d instead of 200", got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		options       Options
+		width, height int
+	}{
+		{Options{}, 8, 10},
+		{Options{CRT: true}, 8, 10},
+		{Options{AspectCorrection: true}, 8, 12},
+		{Options{AspectCorrection: true, CRT: true}, 8, 12},
+	}
+	for _, test := range tests {
+		pixels, width, height := Apply(filled(8, 10, gray(100)), 8, 10, 2, test.options)
+		if width != test.width || height != test.height || len(*pixels) != width*height {
+			t.Errorf("%+v: %dx%d, %d pixels", test.options, width, height, len(*pixels))
+		}
+	}
+}
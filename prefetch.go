@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+)
+
+const prefetchQueueLength = 32
+
+// prefetcher renders views into the frame cache on a pool of worker goroutines.
+type prefetcher struct {
+	cache   *frameCache
+	render  func(key frameKey) *scaledFrame
+	queue   chan frameKey
+	mutex   sync.Mutex
+	pending map[frameKey]bool
+}
+
+func newPrefetcher(cache *frameCache, workers int, render func(key frameKey) *scaledFrame) *prefetcher {
+	p := &prefetcher{
+		cache:   cache,
+		render:  render,
+		queue:   make(chan frameKey, prefetchQueueLength),
+		pending: make(map[frameKey]bool),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// request queues a view unless it is cached or queued already. It never blocks, requests are dropped
+// while the queue is full.
+func (p *prefetcher) request(key frameKey) {
+	if p.cache.contains(key) {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.pending[key] {
+		return
+	}
+	select {
+	case p.queue <- key:
+		p.pending[key] = true
+	default:
+	}
+}
+
+func (p *prefetcher) work() {
+	for key := range p.queue {
+		if !p.cache.contains(key) {
+			generation := p.cache.getGeneration()
+			p.cache.put(key, p.render(key), generation)
+		}
+
+		p.mutex.Lock()
+		delete(p.pending, key)
+		p.mutex.Unlock()
+	}
+}
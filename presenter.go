@@ -0,0 +1,100 @@
+package main
+
+import (
+	"EOB1MazeViewer/postprocess"
+	"EOB1MazeViewer/scaler"
+	"github.com/hajimehoshi/ebiten/v2"
+	"image"
+	"image/color"
+)
+
+const (
+	// the shown image, the previous one kept for a running transition and a spare one to render into
+	presenterImages = 3
+
+	noTransparency = -1
+)
+
+// presenter turns indexed frames into Ebiten images: palette lookup, scaling and an upload with WritePixels
+// into a few reused images.
+type presenter struct {
+	lut              [256]uint32
+	transparentIndex int
+	images           [presenterImages]*ebiten.Image
+	next             int
+}
+
+// newPresenter creates a presenter for the palette. Pixels of transparentIndex get alpha 0,
+// noTransparency keeps every pixel opaque.
+func newPresenter(palette color.Palette, transparentIndex int) *presenter {
+	p := &presenter{transparentIndex: transparentIndex}
+	p.setPalette(palette)
+	return p
+}
+
+// setPalette builds the lookup table from palette indices to pixels, packed as the scalers expect them
+// (R in the low byte), which is also the byte order of WritePixels.
+func (p *presenter) setPalette(palette color.Palette) {
+	for i := range p.lut {
+		p.lut[i] = 0xFF000000
+		if i < len(palette) {
+			r, g, b, _ := palette[i].RGBA()
+			p.lut[i] = 0xFF000000 | (b>>8)<<16 | (g>>8)<<8 | r>>8
+		}
+		if i == p.transparentIndex {
+			p.lut[i] = 0
+		}
+	}
+}
+
+// scaledFrame is a view scaled for the screen, RGBA bytes ready for WritePixels.
+type scaledFrame struct {
+	pixels        []byte
+	width, height int
+}
+
+func (sf *scaledFrame) size() int {
+	return len(sf.pixels)
+}
+
+// scale converts the frame through the lookup table, scales it and applies the post-processing.
+// It does not touch the Ebiten images, so it can be called from any goroutine.
+func (p *presenter) scale(frame *image.Paletted, s scaler.Scaler, options postprocess.Options) *scaledFrame {
+	width, height := frame.Rect.Dx(), frame.Rect.Dy()
+	input := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		offset := frame.PixOffset(frame.Rect.Min.X, frame.Rect.Min.Y+y)
+		for x, index := range frame.Pix[offset : offset+width] {
+			input[y*width+x] = p.lut[index]
+		}
+	}
+
+	scaled, scaledWidth, scaledHeight := s.Scale(&input, width, height)
+	scaled, scaledWidth, scaledHeight = postprocess.Apply(scaled, scaledWidth, scaledHeight, s.Factor(), options)
+	pixels := make([]byte, len(*scaled)*4)
+	for i, pixel := range *scaled {
+		pixels[i*4] = byte(pixel)
+		pixels[i*4+1] = byte(pixel >> 8)
+		pixels[i*4+2] = byte(pixel >> 16)
+		pixels[i*4+3] = byte(pixel >> 24)
+	}
+	return &scaledFrame{pixels: pixels, width: scaledWidth, height: scaledHeight}
+}
+
+// present scales the frame and uploads it, see upload.
+func (p *presenter) present(frame *image.Paletted, s scaler.Scaler, options postprocess.Options) *ebiten.Image {
+	return p.upload(p.scale(frame, s, options))
+}
+
+// upload writes the scaled frame into the next image of the ring and returns it. The returned image stays valid
+// until upload has been called presenterImages more times.
+func (p *presenter) upload(frame *scaledFrame) *ebiten.Image {
+	img := p.images[p.next]
+	if img == nil || img.Bounds().Dx() != frame.width || img.Bounds().Dy() != frame.height {
+		img = ebiten.NewImage(frame.width, frame.height)
+		p.images[p.next] = img
+	}
+	img.WritePixels(frame.pixels)
+	p.next = (p.next + 1) % presenterImages
+	return img
+}
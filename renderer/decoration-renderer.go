@@ -65,23 +65,25 @@ func NewDecorationRenderer(decorationContainer *DecorationContainer) *Decoration
 	return &DecorationRenderer{decorationContainer: decorationContainer}
 }
 
-func (dr *DecorationRenderer) DrawCompleteDecoration(background *[]byte, wallMapping formats.WallMapping, renderPosition int, isAtWall bool) *[]byte {
+func (dr *DecorationRenderer) DrawCompleteDecoration(target *Canvas, wallMapping formats.WallMapping, renderPosition int, isAtWall bool, shadeTable *[256]byte) *Canvas {
 	if wallMapping.DecorationId == 0xFF {
-		return background
+		return target
 	}
 
 	bitmap := dr.decorationContainer.GetDecorationBitmapByName(wallMapping.CpsName)
-	decoration := dr.decorationContainer.GetDecoration(wallMapping.DecorationId)
+	decorationId := wallMapping.DecorationId
+	decoration := dr.decorationContainer.GetDecoration(decorationId)
 
-	dr.drawDecoration(background, decoration, renderPosition, isAtWall, bitmap)
+	dr.drawDecoration(target, decorationId, decoration, renderPosition, isAtWall, bitmap, shadeTable)
 	for decoration.LinkToNextDecoration != 0 {
-		decoration = dr.decorationContainer.GetDecoration(int(decoration.LinkToNextDecoration))
-		dr.drawDecoration(background, decoration, renderPosition, isAtWall, bitmap)
+		decorationId = int(decoration.LinkToNextDecoration)
+		decoration = dr.decorationContainer.GetDecoration(decorationId)
+		dr.drawDecoration(target, decorationId, decoration, renderPosition, isAtWall, bitmap, shadeTable)
 	}
-	return background
+	return target
 }
 
-func (dr *DecorationRenderer) drawDecoration(background *[]byte, decoration formats.Decoration, renderPosition int, isAtWall bool, decorationBitmap *[]byte) {
+func (dr *DecorationRenderer) drawDecoration(target *Canvas, decorationId int, decoration formats.Decoration, renderPosition int, isAtWall bool, decorationBitmap *[]byte, shadeTable *[256]byte) {
 	var i, j, s, t, dx, pos int
 	var mirrored bool
 	var q byte
@@ -115,6 +117,7 @@ func (dr *DecorationRenderer) drawDecoration(background *[]byte, decoration form
 		q = decoration.RectangleIndices[pos]
 
 		if q != 0xFF {
+			target.beginDecoration(decorationId, int(q))
 			mirrored = false
 			switch renderPosition {
 			case 6, 7, 8, 9, 10, 15, 16, 17, 20, 21, 22, 25:
@@ -133,13 +136,13 @@ func (dr *DecorationRenderer) drawDecoration(background *[]byte, decoration form
 
 				for i = int(rectangle.X * 8); i < int(rectangle.X*8+rectangle.W*8); i++ {
 					if mirrored {
-						putPixel(background, s+dx, t, (*decorationBitmap)[320*j+i])
+						target.putPixel(s+dx, t, shade(shadeTable, (*decorationBitmap)[320*j+i]))
 						s--
 					} else {
 						if DecorationPositions[renderPosition].XFlip == 0 {
-							putPixel(background, s+dx, t, (*decorationBitmap)[320*j+i])
+							target.putPixel(s+dx, t, shade(shadeTable, (*decorationBitmap)[320*j+i]))
 						} else {
-							putPixel(background, 22*8-(s+dx), t, (*decorationBitmap)[320*j+i])
+							target.putPixel(22*8-(s+dx), t, shade(shadeTable, (*decorationBitmap)[320*j+i]))
 						}
 						s++
 					}
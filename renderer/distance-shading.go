@@ -0,0 +1,98 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"image/color"
+	"math"
+)
+
+// FalloffCurve returns the brightness (0.0-1.0) of the given distance row, row 0 being the party's own row.
+type FalloffCurve func(row int) float64
+
+// Falloffs giving a similar darkening over the original 3 distance rows.
+const (
+	DefaultLinearStep        = 0.2
+	DefaultExponentialFactor = 0.8
+)
+
+// LinearFalloff darkens every distance row by the same step.
+func LinearFalloff(step float64) FalloffCurve {
+	return func(row int) float64 {
+		return math.Max(0, 1-step*float64(row))
+	}
+}
+
+// ExponentialFalloff multiplies the brightness by factor for every distance row.
+func ExponentialFalloff(factor float64) FalloffCurve {
+	return func(row int) float64 {
+		return math.Pow(factor, float64(row))
+	}
+}
+
+// DistanceShading holds a darkened palette lookup table for every distance row.
+// The tables map palette indices to palette indices, so shaded frames stay indexed.
+type DistanceShading struct {
+	tables [][256]byte
+}
+
+func NewDistanceShading(pal *formats.PAL, rows int, curve FalloffCurve) *DistanceShading {
+	palette := pal.GetPalette()
+	tables := make([][256]byte, rows)
+	for row := 0; row < rows; row++ {
+		tables[row] = buildShadeTable(palette, curve(row))
+	}
+	return &DistanceShading{tables: tables}
+}
+
+// GetTable returns the lookup table of the given distance row, or nil if the row is drawn at full brightness.
+func (ds *DistanceShading) GetTable(row int) *[256]byte {
+	if ds == nil || len(ds.tables) == 0 {
+		return nil
+	}
+	if row >= len(ds.tables) {
+		row = len(ds.tables) - 1
+	}
+	return &ds.tables[row]
+}
+
+func buildShadeTable(palette color.Palette, brightness float64) [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = byte(i)
+	}
+	if brightness >= 1 {
+		return table
+	}
+
+	// index 0 is transparent in the rendering pipeline, it is neither remapped nor used as a target
+	for i := 1; i < len(palette) && i < 256; i++ {
+		r, g, b, _ := palette[i].RGBA()
+		table[i] = findNearestColor(palette, float64(r>>8)*brightness, float64(g>>8)*brightness, float64(b>>8)*brightness)
+	}
+	return table
+}
+
+// findNearestColor returns the palette index closest to the color, index 0 is never returned.
+func findNearestColor(palette color.Palette, targetR, targetG, targetB float64) byte {
+	bestIndex := 1
+	bestDistance := math.MaxFloat64
+	for j := 1; j < len(palette) && j < 256; j++ {
+		cr, cg, cb, _ := palette[j].RGBA()
+		dr := float64(cr>>8) - targetR
+		dg := float64(cg>>8) - targetG
+		db := float64(cb>>8) - targetB
+		distance := dr*dr + dg*dg + db*db
+		if distance < bestDistance {
+			bestDistance = distance
+			bestIndex = j
+		}
+	}
+	return byte(bestIndex)
+}
+
+func shade(table *[256]byte, b byte) byte {
+	if table == nil {
+		return b
+	}
+	return table[b]
+}
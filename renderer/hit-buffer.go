@@ -0,0 +1,130 @@
+package renderer
+
+// HitRecord describes a drawn element of a view: a wall, a decoration or an item.
+// Fields not related to the element are -1.
+type HitRecord struct {
+	Layer            LayerMask
+	Row              int
+	RenderPosition   int // index into wallRenderData, -1 for far walls and items
+	MazeX, MazeY     int
+	Side             int // 0: north, 1: east, 2: south, 3: west
+	WallMappingIndex int
+	DecorationId     int
+	Rectangle        int // DAT rectangle index of the decoration
+	Item             int // index into the item catalog
+}
+
+// HitInfo is the content of a single pixel of a HitBuffer.
+type HitInfo struct {
+	Record       *HitRecord // nil for the background
+	PaletteIndex byte
+}
+
+// HitBuffer maps every pixel of a rendered view back to the element that drew it.
+type HitBuffer struct {
+	Pixels  *[]byte
+	ids     []uint16
+	records []HitRecord
+}
+
+func (hb *HitBuffer) At(x, y int) HitInfo {
+	if x < 0 || y < 0 || x >= 176 || y >= 120 {
+		return HitInfo{}
+	}
+
+	index := y*176 + x
+	info := HitInfo{PaletteIndex: (*hb.Pixels)[index]}
+	if id := hb.ids[index]; id != 0 {
+		info.Record = &hb.records[id-1]
+	}
+	return info
+}
+
+// hitRecorder collects the drawn elements, ids are 1-based, 0 means nothing has been drawn.
+type hitRecorder struct {
+	records []HitRecord
+}
+
+func (hr *hitRecorder) add(record HitRecord) uint16 {
+	hr.records = append(hr.records, record)
+	return uint16(len(hr.records))
+}
+
+// Canvas is a layer buffer, 0x00 is transparent. When hit-testing it also records which element drew each pixel.
+// The canvases of a view share a counter of the elements begun, each pixel keeps the number of the element that
+// drew it to composite the canvases in drawing order.
+type Canvas struct {
+	Pixels *[]byte
+	hits   *hitRecorder
+	ids    []uint16
+	id     uint16
+	base   HitRecord
+	orders []uint16
+	order  *uint16
+}
+
+func newCanvas(hits *hitRecorder, order *uint16) *Canvas {
+	pixels := make([]byte, 176*120)
+	canvas := &Canvas{Pixels: &pixels, hits: hits, orders: make([]uint16, 176*120), order: order}
+	if hits != nil {
+		canvas.ids = make([]uint16, 176*120)
+	}
+	return canvas
+}
+
+// begin starts drawing a new element, the following pixels are attributed to it.
+func (c *Canvas) begin(record HitRecord) {
+	*c.order++
+	if c.hits == nil {
+		return
+	}
+	c.base = record
+	c.id = c.hits.add(record)
+}
+
+// beginDecoration starts drawing a part of a decoration chain of the element given to begin.
+func (c *Canvas) beginDecoration(decorationId int, rectangle int) {
+	if c.hits == nil {
+		return
+	}
+	record := c.base
+	record.DecorationId = decorationId
+	record.Rectangle = rectangle
+	c.id = c.hits.add(record)
+}
+
+func (c *Canvas) clear() {
+	clear(*c.Pixels)
+	if c.ids != nil {
+		clear(c.ids)
+	}
+}
+
+func (c *Canvas) putPixel(x int, y int, b byte) {
+	if b == 0x00 {
+		return
+	}
+	coord := 176*y + x
+	if coord >= 0 && coord < len(*c.Pixels) {
+		(*c.Pixels)[coord] = b
+		c.orders[coord] = *c.order
+		if c.ids != nil {
+			c.ids[coord] = c.id
+		}
+	}
+}
+
+func newHitRecord(layer LayerMask, row int) HitRecord {
+	return HitRecord{
+		Layer:            layer,
+		Row:              row,
+		RenderPosition:   -1,
+		MazeX:            -1,
+		MazeY:            -1,
+		Side:             -1,
+		WallMappingIndex: -1,
+		DecorationId:     -1,
+		Rectangle:        -1,
+		Item:             -1,
+	}
+}
@@ -1,30 +1,28 @@
-package main
+package renderer
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
 	"image"
 	"image/color"
 )
 
-func ConvertPalettedToRGBA(palettedImage *image.Paletted, transparent bool) *image.RGBA {
-	// Create a new RGBA image with the same dimensions
-	rgbaImage := image.NewRGBA(palettedImage.Rect)
+const (
+	ViewportWidth  = 176
+	ViewportHeight = 120
+)
 
-	for y := 0; y < palettedImage.Bounds().Dy(); y++ {
-		for x := 0; x < palettedImage.Bounds().Dx(); x++ {
-			// Set pixel to some color with alpha value
-			index := palettedImage.Pix[palettedImage.PixOffset(x, y)]
-			indexedColor := palettedImage.Palette[index]
-			r, g, b, _ := indexedColor.RGBA()
-			a := 254
-			if transparent && r == 0 && g == 0 && b == 0 {
-				a = 0
-			}
-			rgbaImage.SetRGBA(x, y, color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: uint8(a)}) // A = 254 to force 32 bit
-		}
+// toViewportImage copies a viewport buffer into dst with the given palette attached.
+// A new image is allocated if dst is nil or not of the viewport size.
+func toViewportImage(data *[]byte, palette color.Palette, dst *image.Paletted) *image.Paletted {
+	if dst == nil || dst.Rect.Dx() != ViewportWidth || dst.Rect.Dy() != ViewportHeight {
+		dst = image.NewPaletted(image.Rect(0, 0, ViewportWidth, ViewportHeight), palette)
 	}
+	dst.Palette = palette
 
-	return rgbaImage
+	for y := 0; y < ViewportHeight; y++ {
+		offset := dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y)
+		copy(dst.Pix[offset:offset+ViewportWidth], (*data)[y*ViewportWidth:(y+1)*ViewportWidth])
+	}
+	return dst
 }
 
 func BytesToPalettedImage(data *[]byte, width, height int, palette color.Palette) *image.Paletted {
@@ -40,37 +38,23 @@ func BytesToPalettedImage(data *[]byte, width, height int, palette color.Palette
 	return img
 }
 
-func ConvertRGBAtoUint32Array(rgba *image.RGBA) *[]uint32 {
-	width, height := rgba.Rect.Dx(), rgba.Rect.Dy()
-	pixels := make([]uint32, width*height)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			i := y*rgba.Stride + x*4
-			r, g, b, a := rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2], rgba.Pix[i+3]
-			pixels[y*width+x] = uint32(r)<<24 | uint32(g)<<16 | uint32(b)<<8 | uint32(a)
-		}
-	}
-
-	return &pixels
-}
-
-func ConvertUint32ArrayToEbitenImage(pixels *[]uint32, width, height int) *ebiten.Image {
-	img := ebiten.NewImage(width, height)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			index := y*width + x
-			pixel := (*pixels)[index]
-
-			r := uint8((pixel >> 24) & 0xFF)
-			g := uint8((pixel >> 16) & 0xFF)
-			b := uint8((pixel >> 8) & 0xFF)
-			a := uint8(pixel & 0xFF)
+func ConvertPalettedToRGBA(palettedImage *image.Paletted, transparent bool) *image.RGBA {
+	// Create a new RGBA image with the same dimensions
+	rgbaImage := image.NewRGBA(palettedImage.Rect)
 
-			img.Set(x, y, color.RGBA{R: r, G: g, B: b, A: a})
+	for y := 0; y < palettedImage.Bounds().Dy(); y++ {
+		for x := 0; x < palettedImage.Bounds().Dx(); x++ {
+			// Set pixel to some color with alpha value
+			index := palettedImage.Pix[palettedImage.PixOffset(x, y)]
+			indexedColor := palettedImage.Palette[index]
+			r, g, b, _ := indexedColor.RGBA()
+			a := 254
+			if transparent && r == 0 && g == 0 && b == 0 {
+				a = 0
+			}
+			rgbaImage.SetRGBA(x, y, color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: uint8(a)}) // A = 254 to force 32 bit
 		}
 	}
 
-	return img
+	return rgbaImage
 }
@@ -0,0 +1,164 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"log"
+)
+
+const (
+	itemIconCPSName   = "ITEMICN.CPS"
+	itemIconSize      = 16
+	itemIconsPerRow   = 20
+	itemIconSheetW    = 320
+	itemIconSheetRows = 12
+)
+
+// The floor sprite sheets have 3 sprites per column, 24 pixels high every 64 pixels, the large sprites are 64
+// pixels wide and the small ones 32.
+const (
+	largeItemCPSName      = "ITEML1.CPS"
+	smallItemCPSName      = "ITEMS1.CPS"
+	largeItemSpriteW      = 64
+	smallItemSpriteW      = 32
+	itemSpriteH           = 24
+	itemSpriteRowDistance = 64
+	itemSpritesPerColumn  = 3
+)
+
+// itemBitmap is a picture of an item cropped to its visible pixels, 0x00 is transparent.
+type itemBitmap struct {
+	width, height int
+	pixels        []byte
+}
+
+type ItemContainer struct {
+	catalog      *formats.ItemCatalog
+	iconData     *[]byte
+	largeSprites []*itemBitmap
+	smallSprites []*itemBitmap
+	spriteMap    map[int]formats.ItemSprite
+}
+
+// BuildItemContainer loads the item icons and floor sprites. The floor sprite of an item picture is selected by
+// the sprite map, the pictures missing from it are drawn on the floor with their icon.
+func BuildItemContainer(catalog *formats.ItemCatalog, files map[string]*[]byte, spriteMap map[int]formats.ItemSprite) *ItemContainer {
+	var iconData *[]byte
+	if rawIcons, ok := files[itemIconCPSName]; ok {
+		cps, err := formats.NewCPSFromByteArray(rawIcons)
+		if err == nil {
+			iconData = cps.GetRawData()
+		}
+	}
+
+	container := &ItemContainer{
+		catalog:      catalog,
+		iconData:     iconData,
+		largeSprites: loadItemSprites(files, largeItemCPSName, largeItemSpriteW),
+		smallSprites: loadItemSprites(files, smallItemCPSName, smallItemSpriteW),
+		spriteMap:    spriteMap,
+	}
+	for picture, sprite := range spriteMap {
+		if container.getSprite(sprite) == nil {
+			log.Printf("Item picture %d: sprite %s is missing", picture, sprite)
+		}
+	}
+	return container
+}
+
+// loadItemSprites cuts a floor sprite sheet into its sprites, nil for an empty one.
+func loadItemSprites(files map[string]*[]byte, name string, width int) []*itemBitmap {
+	rawSheet, ok := files[name]
+	if !ok {
+		return nil
+	}
+	cps, err := formats.NewCPSFromByteArray(rawSheet)
+	if err != nil {
+		log.Printf("Cannot load %s: %s", name, err)
+		return nil
+	}
+
+	sheet := *cps.GetRawData()
+	var sprites []*itemBitmap
+	for column := 0; (column+1)*width <= itemIconSheetW; column++ {
+		for i := 0; i < itemSpritesPerColumn; i++ {
+			top := i * itemSpriteRowDistance
+			if (top+itemSpriteH)*itemIconSheetW > len(sheet) {
+				return sprites
+			}
+			sprites = append(sprites, cropBitmap(sheet, itemIconSheetW, column*width, top, width, itemSpriteH))
+		}
+	}
+	return sprites
+}
+
+// cropBitmap returns the visible pixels of a rectangle of a sheet, nil if it is transparent.
+func cropBitmap(sheet []byte, sheetWidth, left, top, width, height int) *itemBitmap {
+	minX, minY, maxX, maxY := width, height, -1, -1
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if sheet[(top+y)*sheetWidth+left+x] != 0x00 {
+				minX, minY = min(minX, x), min(minY, y)
+				maxX, maxY = max(maxX, x), max(maxY, y)
+			}
+		}
+	}
+	if maxX < 0 {
+		return nil
+	}
+
+	bitmap := &itemBitmap{width: maxX - minX + 1, height: maxY - minY + 1}
+	bitmap.pixels = make([]byte, bitmap.width*bitmap.height)
+	for y := 0; y < bitmap.height; y++ {
+		start := (top+minY+y)*sheetWidth + left + minX
+		copy(bitmap.pixels[y*bitmap.width:], sheet[start:start+bitmap.width])
+	}
+	return bitmap
+}
+
+func (c ItemContainer) GetCatalog() *formats.ItemCatalog {
+	return c.catalog
+}
+
+// GetIconBitmap returns the 16x16 icon of the given item picture, or nil if the icon sheet is missing.
+func (c ItemContainer) GetIconBitmap(picture int) []byte {
+	if c.iconData == nil || picture < 0 || picture >= itemIconsPerRow*itemIconSheetRows {
+		return nil
+	}
+
+	iconX := (picture % itemIconsPerRow) * itemIconSize
+	iconY := (picture / itemIconsPerRow) * itemIconSize
+
+	icon := make([]byte, itemIconSize*itemIconSize)
+	for y := 0; y < itemIconSize; y++ {
+		start := (iconY+y)*itemIconSheetW + iconX
+		if start+itemIconSize > len(*c.iconData) {
+			return nil
+		}
+		copy(icon[y*itemIconSize:], (*c.iconData)[start:start+itemIconSize])
+	}
+	return icon
+}
+
+func (c ItemContainer) getSprite(sprite formats.ItemSprite) *itemBitmap {
+	sprites := c.smallSprites
+	if sprite.Large {
+		sprites = c.largeSprites
+	}
+	if sprite.Index >= len(sprites) {
+		return nil
+	}
+	return sprites[sprite.Index]
+}
+
+// getFloorBitmap returns the picture of an item lying on the floor, its floor sprite or else its icon.
+func (c ItemContainer) getFloorBitmap(picture int) *itemBitmap {
+	if sprite, ok := c.spriteMap[picture]; ok {
+		if bitmap := c.getSprite(sprite); bitmap != nil {
+			return bitmap
+		}
+	}
+	if icon := c.GetIconBitmap(picture); icon != nil {
+		return &itemBitmap{width: itemIconSize, height: itemIconSize, pixels: icon}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"testing"
+)
+
+func TestItemFloorBitmap(t *testing.T) {
+	large := make([]byte, 320*200)
+	// a 3x2 sprite in the second large sprite, the top one of the first column is empty
+	for y := 70; y < 72; y++ {
+		for x := 10; x < 13; x++ {
+			large[y*320+x] = 5
+		}
+	}
+	small := make([]byte, 320*200)
+	// the 5th small sprite is the middle one of the second column
+	small[80*320+40] = 6
+	icons := make([]byte, 320*200)
+	for i := 0; i < 16; i++ {
+		icons[i*320+16+i] = 7
+	}
+	largeCPS, smallCPS, iconCPS := uncompressedCPS(large), uncompressedCPS(small), uncompressedCPS(icons)
+	files := map[string]*[]byte{largeItemCPSName: &largeCPS, smallItemCPSName: &smallCPS, itemIconCPSName: &iconCPS}
+
+	spriteMap := map[int]formats.ItemSprite{
+		2: {Large: true, Index: 1},
+		3: {Index: 4},
+		4: {Large: true, Index: 0},
+		5: {Index: 40},
+	}
+	container := BuildItemContainer(&formats.ItemCatalog{}, files, spriteMap)
+	if len(container.largeSprites) != 15 || len(container.smallSprites) != 30 {
+		t.Fatalf("%d large and %d small sprites", len(container.largeSprites), len(container.smallSprites))
+	}
+
+	tests := []struct {
+		picture       int
+		width, height int
+		pixel         byte
+	}{
+		{2, 3, 2, 5},
+		{3, 1, 1, 6},
+		// pictures without a sprite lie on the floor as icons
+		{1, 16, 16, 7},
+		{4, 16, 16, 0},
+		{5, 16, 16, 0},
+	}
+	for _, test := range tests {
+		bitmap := container.getFloorBitmap(test.picture)
+		if bitmap == nil || bitmap.width != test.width || bitmap.height != test.height || len(bitmap.pixels) != test.width*test.height {
+			t.Errorf("picture %d: %+v", test.picture, bitmap)
+			continue
+		}
+		if bitmap.pixels[0] != test.pixel {
+			t.Errorf("picture %d: first pixel %d instead of %d", test.picture, bitmap.pixels[0], test.pixel)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+)
+
+// Item picture scale in 1/8 units for each distance row
+var itemScale = [4]int{8, 6, 4, 3}
+
+// Visible blocks of each distance row, from the outermost to the middle one
+var itemRowBlocks = [4][]int{
+	{0},
+	{-1, 1, 0},
+	{-2, 2, -1, 1, 0},
+	{-3, 3, -2, 2, -1, 1, 0},
+}
+
+var viewForward = [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+var viewRight = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+type ItemRenderer struct {
+	itemContainer *ItemContainer
+	inf           *formats.InfHeader
+	maz           *formats.Maz
+	itemsByBlock  map[int][]int
+}
+
+func NewItemRenderer(itemContainer *ItemContainer, inf *formats.InfHeader, maz *formats.Maz, level int) *ItemRenderer {
+	catalog := itemContainer.GetCatalog()
+	itemsByBlock := make(map[int][]int)
+	for _, index := range catalog.GetItemsOfLevel(level) {
+		position := int(catalog.Items[index].Position)
+		itemsByBlock[position] = append(itemsByBlock[position], index)
+	}
+
+	return &ItemRenderer{
+		itemContainer: itemContainer,
+		inf:           inf,
+		maz:           maz,
+		itemsByBlock:  itemsByBlock,
+	}
+}
+
+// DrawItems draws the items lying on the floor of the visible blocks in the given distance row.
+func (ir *ItemRenderer) DrawItems(target *Canvas, x, y, direction, row int, shadeTable *[256]byte) {
+	for _, xDelta := range itemRowBlocks[row] {
+		blockX, blockY := GetMazeCoordinate(x, y, direction, xDelta, -row)
+		if !ir.isOpenBlock(blockX, blockY, direction) {
+			continue
+		}
+
+		items := ir.itemsByBlock[blockY*32+blockX]
+		// far half of the block first, then the near half
+		for _, far := range []bool{true, false} {
+			for _, index := range items {
+				item := ir.itemContainer.GetCatalog().Items[index]
+				lateral, forward := subPositionToView(int(item.SubPosition), direction)
+				if (forward > 0) != far {
+					continue
+				}
+
+				record := newHitRecord(LayerItems, row)
+				record.MazeX, record.MazeY = blockX, blockY
+				record.Item = index
+				target.begin(record)
+				ir.drawItem(target, item, xDelta, row, lateral, forward, shadeTable)
+			}
+		}
+	}
+}
+
+func (ir *ItemRenderer) isOpenBlock(x, y, direction int) bool {
+	if x < 0 || y < 0 || x >= int(ir.maz.Width) || y >= int(ir.maz.Height) {
+		return false
+	}
+
+	facingSide := (direction + 2) & 0x03
+	wallMapping := ir.inf.FindWallMappingByIndex(ir.maz.GetMazeBlockByCoordinateOrFake(x, y).Wall[facingSide])
+	return wallMapping == nil || wallMapping.Flags&0x01 != 0
+}
+
+func subPositionToView(subPosition int, direction int) (int, int) {
+	sx, sy := -1, -1
+	if subPosition&0x01 != 0 {
+		sx = 1
+	}
+	if subPosition&0x02 != 0 {
+		sy = 1
+	}
+
+	lateral := sx*viewRight[direction][0] + sy*viewRight[direction][1]
+	forward := sx*viewForward[direction][0] + sy*viewForward[direction][1]
+	return lateral, forward
+}
+
+func (ir *ItemRenderer) drawItem(target *Canvas, item formats.Item, xDelta, row, lateral, forward int, shadeTable *[256]byte) {
+	bitmap := ir.itemContainer.getFloorBitmap(int(item.Picture))
+	if bitmap == nil {
+		return
+	}
+
+	depth := 0.25
+	if forward > 0 {
+		depth = 0.75
+	}
+	nearHalfWidth, _, nearFloorY := getConeBoundary(row)
+	farHalfWidth, _, farFloorY := getConeBoundary(row + 1)
+	floorY := nearFloorY + (farFloorY-nearFloorY)*depth
+	halfWidth := nearHalfWidth + (farHalfWidth-nearHalfWidth)*depth
+	centerX := 88 + (float64(xDelta)+float64(lateral)*0.25)*halfWidth*2
+
+	width := max(bitmap.width*itemScale[row]/8, 1)
+	height := max(bitmap.height*itemScale[row]/8, 1)
+	left := int(centerX) - width/2
+	top := int(floorY) - height
+
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			b := bitmap.pixels[(j*8/itemScale[row])*bitmap.width+i*8/itemScale[row]]
+			if left+i >= 0 && left+i < 176 {
+				target.putPixel(left+i, top+j, shade(shadeTable, b))
+			}
+		}
+	}
+}
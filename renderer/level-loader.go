@@ -0,0 +1,70 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// LoadLevel builds a MazeRenderer for a level from the files of the PAK archives, see formats.UnPak.
+// Items are drawn if ITEM.DAT is available, with the floor sprites of the sprite map, see BuildItemContainer.
+func LoadLevel(dataFiles map[string]*[]byte, level string, itemSprites map[int]formats.ItemSprite) (*MazeRenderer, error) {
+	inf, err := formats.NewInfFromDataFiles(dataFiles, level)
+	if err != nil {
+		return nil, err
+	}
+
+	maz, err := loadLevelFile(dataFiles, "LEVEL"+level+".MAZ", formats.NewMazFromByteArray)
+	if err != nil {
+		return nil, err
+	}
+	vcn, err := loadLevelFile(dataFiles, strings.ToUpper(inf.VmpVcnName)+".VCN", formats.NewVCNFromByteArray)
+	if err != nil {
+		return nil, err
+	}
+	vmp, err := loadLevelFile(dataFiles, strings.ToUpper(inf.VmpVcnName)+".VMP", formats.NewVMPFromByteArray)
+	if err != nil {
+		return nil, err
+	}
+	pal, err := loadLevelFile(dataFiles, strings.ToUpper(inf.PaletteName)+".PAL", formats.NewPALFromByteArray)
+	if err != nil {
+		return nil, err
+	}
+	dat, err := loadLevelFile(dataFiles, strings.ToUpper(inf.VmpVcnName)+".DAT", formats.NewDATFromByteArray)
+	if err != nil {
+		return nil, err
+	}
+
+	decorationCPSNames := inf.GetDecorationCPSNames()
+	decorationContainer := BuildDecorationContainer(dat, dataFiles, decorationCPSNames)
+
+	mazeRenderer := NewMazeRenderer(inf, maz, vcn, vmp, pal, decorationContainer)
+
+	if itemData, ok := dataFiles["ITEM.DAT"]; ok {
+		catalog, err := formats.NewItemCatalogFromByteArray(itemData)
+		if err != nil {
+			log.Printf("Cannot load item catalog: %s", err)
+		} else {
+			levelNumber, _ := strconv.Atoi(level)
+			itemContainer := BuildItemContainer(catalog, dataFiles, itemSprites)
+			mazeRenderer.SetItemRenderer(NewItemRenderer(itemContainer, inf, maz, levelNumber))
+		}
+	}
+
+	return mazeRenderer, nil
+}
+
+// loadLevelFile decodes a file of a level, the error names the file.
+func loadLevelFile[T any](dataFiles map[string]*[]byte, name string, decode func(*[]byte) (*T, error)) (*T, error) {
+	data, ok := dataFiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing", name)
+	}
+	decoded, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return decoded, nil
+}
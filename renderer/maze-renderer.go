@@ -2,7 +2,10 @@ package renderer
 
 import (
 	inf2 "EOB1MazeViewer/formats"
-	"github.com/elliotchance/orderedmap/v2"
+	"image"
+	"image/color"
+	"sync"
+	"sync/atomic"
 )
 
 // WallRenderData
@@ -116,11 +119,38 @@ var wallRenderData = map[int]WallRenderData{ /* 25 different wall positions exis
 	Q_WEST: {19, 15, 3, 1, 0}, /* Q-west */
 }
 
+// getDistanceRow returns the distance row of a wall position: 3 for A-G, 2 for H-L, 1 for M/N/O and 0 for P/Q.
+func getDistanceRow(renderPosition int) int {
+	switch {
+	case renderPosition <= F_SOUTH:
+		return 3
+	case renderPosition <= K_SOUTH:
+		return 2
+	case renderPosition <= O_SOUTH:
+		return 1
+	default:
+		return 0
+	}
+}
+
 type MazeRenderer struct {
 	viewportDataProvider *ViewportDataProvider
 	wallRenderer         *WallRenderer
 	decorationRenderer   *DecorationRenderer
+	itemRenderer         *ItemRenderer
+	distanceShading      *DistanceShading
+	viewCone             ViewCone
+	visibleLayers        atomic.Uint32
 	Palette              *inf2.PAL
+	palette              color.Palette
+	scratch              sync.Pool
+	mazeLock             sync.RWMutex
+}
+
+// renderScratch holds the buffers of a single RenderMaze call, they are reused through a pool.
+type renderScratch struct {
+	layers *RenderLayers
+	frame  []byte
 }
 
 func NewMazeRenderer(inf *inf2.InfHeader, maz *inf2.Maz, vcn *inf2.VCN, vmp *inf2.VMP, pal *inf2.PAL, decorationContainer *DecorationContainer) *MazeRenderer {
@@ -128,61 +158,150 @@ func NewMazeRenderer(inf *inf2.InfHeader, maz *inf2.Maz, vcn *inf2.VCN, vmp *inf
 	wallRenderer := NewWallRenderer(vcn, vmp)
 	decorationRenderer := NewDecorationRenderer(decorationContainer)
 
-	return &MazeRenderer{
+	mazeRenderer := &MazeRenderer{
 		viewportDataProvider: viewportDataProvider,
 		wallRenderer:         wallRenderer,
 		decorationRenderer:   decorationRenderer,
+		viewCone:             DefaultViewCone,
 		Palette:              pal,
+		palette:              pal.GetPalette(),
+	}
+	mazeRenderer.visibleLayers.Store(uint32(AllLayers))
+	return mazeRenderer
+}
+
+// GetMazeSize returns the width and the height of the level in blocks.
+func (mr *MazeRenderer) GetMazeSize() (int, int) {
+	maz := mr.viewportDataProvider.maz
+	return int(maz.Width), int(maz.Height)
+}
+
+// GetInf returns the level header with the wall mappings and the scripts.
+func (mr *MazeRenderer) GetInf() *inf2.InfHeader {
+	return mr.viewportDataProvider.inf
+}
+
+// GetWall returns the wall mapping index of a side of a block, 0 outside the maze.
+func (mr *MazeRenderer) GetWall(x, y, side int) int {
+	mr.mazeLock.RLock()
+	defer mr.mazeLock.RUnlock()
+	return int(mr.viewportDataProvider.maz.GetMazeBlockByCoordinateOrFake(x, y).Wall[side&0x03])
+}
+
+// SetWall changes the wall mapping index of a side of a block, it waits for the views being rendered.
+// Cached views have to be discarded by the caller.
+func (mr *MazeRenderer) SetWall(x, y, side, wallMappingIndex int) {
+	mr.mazeLock.Lock()
+	defer mr.mazeLock.Unlock()
+	maz := mr.viewportDataProvider.maz
+	if x < 0 || y < 0 || x >= int(maz.Width) || y >= int(maz.Height) {
+		return
+	}
+	maz.GetMazeBlockByCoordinateOrFake(x, y).Wall[side&0x03] = byte(wallMappingIndex)
+}
+
+// GetPalette returns the level palette attached to the rendered images.
+func (mr *MazeRenderer) GetPalette() color.Palette {
+	return mr.palette
+}
+
+// RenderMaze renders the 176x120 view of the party at the given position and direction.
+// The view is written into dst if it is not nil, otherwise a new image is allocated.
+// Views can be rendered concurrently, but not while the renderer is being configured with the setters.
+func (mr *MazeRenderer) RenderMaze(x int, y int, direction int, dst *image.Paletted) (*image.Paletted, error) {
+	rows := max(mr.viewCone.Depth, originalViewDepth) + 1
+	scratch, _ := mr.scratch.Get().(*renderScratch)
+	if scratch == nil || len(scratch.layers.Rows) != rows {
+		scratch = &renderScratch{layers: newRenderLayers(rows, false), frame: make([]byte, 176*120)}
+	}
+	defer mr.scratch.Put(scratch)
+
+	layers, err := mr.renderLayers(x, y, direction, scratch.layers)
+	if err != nil {
+		return nil, err
+	}
+	layers.compositeInto(scratch.frame, mr.GetVisibleLayers(), nil)
+	return toViewportImage(&scratch.frame, mr.palette, dst), nil
+}
+
+// RenderMazeWithHitBuffer renders the view like RenderMaze, together with a buffer mapping every pixel
+// back to the wall, decoration or item that drew it.
+func (mr *MazeRenderer) RenderMazeWithHitBuffer(x int, y int, direction int, dst *image.Paletted) (*image.Paletted, *HitBuffer, error) {
+	layers, err := mr.renderLayers(x, y, direction, newRenderLayers(max(mr.viewCone.Depth, originalViewDepth)+1, true))
+	if err != nil {
+		return nil, nil, err
 	}
+	hitBuffer := layers.CompositeHitBuffer(mr.GetVisibleLayers())
+	return toViewportImage(hitBuffer.Pixels, mr.palette, dst), hitBuffer, nil
 }
 
-func (mr *MazeRenderer) RenderMaze(x int, y int, direction int) (*[]byte, error) {
-	viewportData := mr.viewportDataProvider.GetViewportData(x, y, direction)
+// RenderLayers renders the view into separate layers, see RenderLayers.Composite.
+func (mr *MazeRenderer) RenderLayers(x int, y int, direction int) (*RenderLayers, error) {
+	return mr.renderLayers(x, y, direction, newRenderLayers(max(mr.viewCone.Depth, originalViewDepth)+1, false))
+}
+
+func (mr *MazeRenderer) renderLayers(x int, y int, direction int, layers *RenderLayers) (*RenderLayers, error) {
+	mr.mazeLock.RLock()
+	defer mr.mazeLock.RUnlock()
+
+	mr.viewportDataProvider.fillViewportData(x, y, direction, layers.viewportData)
 	background := mr.wallRenderer.RenderBackground()
 	if (x+y+direction)%2 == 0 {
-		background = flipBackgroundX(background, 176, 120)
+		background = mr.wallRenderer.RenderFlippedBackground()
 	}
-	return mr.renderAndOverlay(viewportData, background)
+
+	layers.reset(background)
+	if mr.viewCone.Depth > originalViewDepth {
+		mr.renderFarRows(layers, x, y, direction)
+	}
+	return mr.renderAndOverlay(&layers.viewportData, layers, x, y, direction)
+}
+
+// SetVisibleLayers selects the layers composited by RenderMaze, it can be called while views are being rendered.
+func (mr *MazeRenderer) SetVisibleLayers(visibleLayers LayerMask) {
+	mr.visibleLayers.Store(uint32(visibleLayers))
+}
+
+func (mr *MazeRenderer) GetVisibleLayers() LayerMask {
+	return LayerMask(mr.visibleLayers.Load())
 }
 
-func (mr *MazeRenderer) renderAndOverlay(viewportData ViewportData, background *[]byte) (*[]byte, error) {
-	mazeWallDataMap := orderedmap.NewOrderedMap[int, inf2.WallMapping]()
-	for i := A_EAST; i <= Q_WEST; i++ {
-		index := (*viewportData)[i]
-		mazeWallDataMap.Set(i, *mr.viewportDataProvider.inf.FindWallMappingByIndex(index))
+// SetItemRenderer enables drawing the items lying on the floor of the visible blocks.
+func (mr *MazeRenderer) SetItemRenderer(itemRenderer *ItemRenderer) {
+	mr.itemRenderer = itemRenderer
+}
+
+// SetViewCone sets the visible area, walls beyond the original 3 rows are downscaled from the farthest wall bitmaps.
+// An invalid view cone is rejected, see ViewCone.Validate.
+func (mr *MazeRenderer) SetViewCone(viewCone ViewCone) error {
+	if err := viewCone.Validate(); err != nil {
+		return err
 	}
+	mr.viewCone = viewCone
+	return nil
+}
 
-	for renderPosition := range mazeWallDataMap.Keys() {
-		mazeWallData, _ := mazeWallDataMap.Get(renderPosition)
+// SetDistanceShading enables darkening the walls, decorations and items by distance row, nil disables it.
+func (mr *MazeRenderer) SetDistanceShading(distanceShading *DistanceShading) {
+	mr.distanceShading = distanceShading
+}
+
+func (mr *MazeRenderer) renderAndOverlay(viewportData ViewportData, layers *RenderLayers, x, y, direction int) (*RenderLayers, error) {
+	for renderPosition := A_EAST; renderPosition <= Q_WEST; renderPosition++ {
+		mazeWallData := *mr.viewportDataProvider.inf.FindWallMappingByIndex((*viewportData)[renderPosition])
 		renderData := wallRenderData[renderPosition]
+		row := getDistanceRow(renderPosition)
+		shadeTable := mr.distanceShading.GetTable(row)
 
-		var wallData = make([]byte, 0)
-		wallDataPtr := &wallData
-
-		needWallRender := true
-		switch mazeWallData.WallMappingIndex {
-		case 0:
-			needWallRender = false
-		case 1:
-			wallDataPtr = mr.wallRenderer.RenderWall(1, renderData.wallIndex)
-		case 2:
-			wallDataPtr = mr.wallRenderer.RenderWall(1, renderData.wallIndex)
-		case 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22:
-			// door stuff
-			wallDataPtr = mr.wallRenderer.RenderWall(2, renderData.wallIndex)
-		case 23:
-			wallDataPtr = mr.wallRenderer.RenderWall(3, renderData.wallIndex)
-		case 24:
-			wallDataPtr = mr.wallRenderer.RenderWall(4, renderData.wallIndex)
-		default:
-			if mazeWallData.WallSetId == 0 {
-				needWallRender = false
-			} else {
-				wallDataPtr = mr.wallRenderer.RenderWall(mazeWallData.WallSetId, renderData.wallIndex)
-			}
-		}
+		record := newHitRecord(LayerWallsRow0<<row, row)
+		record.RenderPosition = renderPosition
+		record.MazeX, record.MazeY = GetMazeCoordinate(x, y, direction, MazePositions[renderPosition].XDelta, MazePositions[renderPosition].YDelta)
+		record.Side = (direction + MazePositions[renderPosition].Direction) & 0x03
+		record.WallMappingIndex = mazeWallData.WallMappingIndex
 
+		wallSet, needWallRender := getWallSet(mazeWallData)
 		if needWallRender {
+			wallDataPtr := mr.wallRenderer.RenderWall(wallSet, renderData.wallIndex)
 			w, h := mr.wallRenderer.GetWallSize(renderData.wallIndex)
 			w *= 8
 			h *= 8
@@ -191,63 +310,48 @@ func (mr *MazeRenderer) renderAndOverlay(viewportData ViewportData, background *
 			positionY := (renderData.offsetInViewPort / 22) * 8
 
 			cropWidth := renderData.visibleWidthInBlocks * 8
-			cropHeight := renderData.visibleHeightInBlocks * 8
-			cropWallData := cropImage(wallDataPtr, w, cropWidth, cropHeight)
-			background, _ = mr.overlayImage(background, cropWallData, cropWidth, cropHeight, positionX, positionY, renderData.flipFlag)
+			cropHeight := min(renderData.visibleHeightInBlocks*8, h)
+			layers.Rows[row].Walls.begin(record)
+			mr.overlayImage(layers.Rows[row].Walls, wallDataPtr, w, cropWidth, cropHeight, positionX, positionY, renderData.flipFlag, shadeTable)
 		}
 
-		background = mr.decorationRenderer.DrawCompleteDecoration(background, mazeWallData, renderPosition, renderData.wallIndex != 0)
+		record.Layer = LayerDecorations
+		layers.Rows[row].Decorations.begin(record)
+		mr.decorationRenderer.DrawCompleteDecoration(layers.Rows[row].Decorations, mazeWallData, renderPosition, renderData.wallIndex != 0, shadeTable)
 	}
 
-	return background, nil
-}
-
-func cropImage(original *[]byte, originalWidth, newWidth, newHeight int) *[]byte {
-	// Create a new slice for the cropped image
-	cropped := make([]byte, newWidth*newHeight)
-
-	// Iterate over each row and column of the new image
-	for y := 0; y < newHeight; y++ {
-		for x := 0; x < newWidth; x++ {
-			// Calculate the position in the original and cropped slices
-			originalPos := y*originalWidth + x
-			croppedPos := y*newWidth + x
-
-			// Copy the pixel
-			cropped[croppedPos] = (*original)[originalPos]
+	if mr.itemRenderer != nil {
+		for row := 0; row <= originalViewDepth; row++ {
+			mr.itemRenderer.DrawItems(layers.Rows[row].Items, x, y, direction, row, mr.distanceShading.GetTable(row))
 		}
 	}
 
-	return &cropped
+	return layers, nil
 }
 
-func (mr *MazeRenderer) overlayImage(backgroundImageBytes *[]byte, anotherImageBytes *[]byte, anotherWidth int, anotherHeight int, dX int, dY int, flipFlag int) (*[]byte, error) {
-	for row := 0; row < anotherHeight; row++ {
-		for col := 0; col < anotherWidth; col++ {
+// overlayImage draws the top left cropWidth x cropHeight part of an image, flipped horizontally if flipFlag is 1.
+func (mr *MazeRenderer) overlayImage(target *Canvas, anotherImageBytes *[]byte, anotherWidth int, cropWidth int, cropHeight int, dX int, dY int, flipFlag int, shadeTable *[256]byte) {
+	for row := 0; row < cropHeight; row++ {
+		for col := 0; col < cropWidth; col++ {
 			// Calculate the position in the first image
 			destX := dX + col
 			destY := dY + row
 
-			// Check if the position is within the bounds of the first image
-			destPos := destY*176 + destX
-
 			var srcPos int
 			if flipFlag == 1 {
 				// Flip the image horizontally
-				srcPos = row*anotherWidth + (anotherWidth - 1 - col)
+				srcPos = row*anotherWidth + (cropWidth - 1 - col)
 			} else {
 				// Normal positioning
 				srcPos = row*anotherWidth + col
 			}
 
+			// Check if the position is within the bounds of the first image
 			if destX >= 0 && destX < 176 && destY >= 0 && destY < 120 {
-				if (*anotherImageBytes)[srcPos] != 0x00 {
-					(*backgroundImageBytes)[destPos] = (*anotherImageBytes)[srcPos]
-				}
+				target.putPixel(destX, destY, shade(shadeTable, (*anotherImageBytes)[srcPos]))
 			}
 		}
 	}
-	return backgroundImageBytes, nil
 }
 
 // FlipImageOnX flips the image horizontally.
@@ -0,0 +1,142 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"encoding/binary"
+	"image"
+	"strings"
+	"testing"
+)
+
+const testMazeSize = 32
+
+// testVCN returns a VCN of 64 blocks with patterned pixels, wrapped in an uncompressed CPS file.
+func testVCN(tb testing.TB) *formats.VCN {
+	blocks := 64
+	raw := make([]byte, 2+32+blocks*32)
+	binary.LittleEndian.PutUint16(raw, uint16(blocks))
+	for i := 0; i < 16; i++ {
+		raw[2+i] = byte(i + 16)
+		raw[18+i] = byte(i + 32)
+	}
+	for i := 34; i < len(raw); i++ {
+		raw[i] = byte(i*7) | 0x11
+	}
+	cps := uncompressedCPS(raw)
+	vcn, err := formats.NewVCNFromByteArray(&cps)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return vcn
+}
+
+// uncompressedCPS wraps data in a CPS file without compression.
+func uncompressedCPS(raw []byte) []byte {
+	cps := make([]byte, 10+len(raw))
+	binary.LittleEndian.PutUint16(cps, uint16(len(cps)-2))
+	binary.LittleEndian.PutUint32(cps[4:], uint32(len(raw)))
+	copy(cps[10:], raw)
+	return cps
+}
+
+// testVMP returns a VMP using the blocks of testVCN in turn, every other one flipped.
+func testVMP(tb testing.TB) *formats.VMP {
+	count := 4000
+	raw := make([]byte, 2+count*2)
+	binary.LittleEndian.PutUint16(raw, uint16(count))
+	for i := 0; i < count; i++ {
+		binary.LittleEndian.PutUint16(raw[2+i*2:], uint16(i%64)|uint16((i%2)<<14))
+	}
+	vmp, err := formats.NewVMPFromByteArray(&raw)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return vmp
+}
+
+// testMaz returns a MAZ file of a level walled in, with pillars every 4 blocks across and 3 blocks down.
+func testMaz(tb testing.TB) *formats.Maz {
+	raw := make([]byte, 6+testMazeSize*testMazeSize*4)
+	binary.LittleEndian.PutUint16(raw, testMazeSize)
+	binary.LittleEndian.PutUint16(raw[2:], testMazeSize)
+	binary.LittleEndian.PutUint16(raw[4:], 4)
+	for y := 0; y < testMazeSize; y++ {
+		for x := 0; x < testMazeSize; x++ {
+			if x == 0 || y == 0 || x == testMazeSize-1 || y == testMazeSize-1 || x%4 == 0 && y%3 == 0 {
+				copy(raw[6+(y*testMazeSize+x)*4:], []byte{1, 1, 1, 1})
+			}
+		}
+	}
+	maz, err := formats.NewMazFromByteArray(&raw)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return maz
+}
+
+func newTestMazeRenderer(tb testing.TB) *MazeRenderer {
+	palRaw := make([]byte, 768)
+	for i := range palRaw {
+		palRaw[i] = byte(i % 64)
+	}
+	pal, err := formats.NewPALFromByteArray(&palRaw)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	wallMapping := map[int]formats.WallMapping{}
+	wallSets := []int{0, 1, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3}
+	flags := []int{1, 4, 4, 0x2c, 0x2c, 0x2c, 0x2c, 0x19, 0x2c, 0x2c, 0x2c, 0x2c, 0x19, 0x2e, 0x2e, 0x2e, 0x2e, 0x19, 0x2e, 0x2e, 0x2e, 0x2e, 0x19}
+	for i := range wallSets {
+		wallMapping[i] = formats.WallMapping{WallMappingIndex: i, WallSetId: wallSets[i], DecorationId: 0xff, Flags: flags[i]}
+	}
+	decorations := BuildDecorationContainer(&formats.DecorationData{}, map[string]*[]byte{}, nil)
+	return NewMazeRenderer(&formats.InfHeader{WallMapping: wallMapping}, testMaz(tb), testVCN(tb), testVMP(tb), pal, decorations)
+}
+
+// BenchmarkRenderMaze renders every open block of the level in all directions into the same frame.
+func BenchmarkRenderMaze(b *testing.B) {
+	mr := newTestMazeRenderer(b)
+	type view struct{ x, y, direction int }
+	var views []view
+	for y := 1; y < testMazeSize-1; y++ {
+		for x := 1; x < testMazeSize-1; x++ {
+			if x%4 != 0 || y%3 != 0 {
+				for direction := 0; direction < 4; direction++ {
+					views = append(views, view{x, y, direction})
+				}
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var frame *image.Paletted
+	var err error
+	for i := 0; i < b.N; i++ {
+		v := views[i%len(views)]
+		if frame, err = mr.RenderMaze(v.x, v.y, v.direction, frame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderBackground(b *testing.B) {
+	wr := NewWallRenderer(testVCN(b), testVMP(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wr.RenderBackground()
+	}
+}
+
+func TestLoadLevelFile(t *testing.T) {
+	mazRaw := make([]byte, 2)
+	dataFiles := map[string]*[]byte{"LEVEL1.MAZ": &mazRaw}
+	if _, err := loadLevelFile(dataFiles, "LEVEL2.MAZ", formats.NewMazFromByteArray); err == nil || !strings.Contains(err.Error(), "LEVEL2.MAZ") {
+		t.Errorf("missing file: %v", err)
+	}
+	if _, err := loadLevelFile(dataFiles, "LEVEL1.MAZ", formats.NewMazFromByteArray); err == nil || !strings.Contains(err.Error(), "LEVEL1.MAZ") {
+		t.Errorf("corrupt file: %v", err)
+	}
+}
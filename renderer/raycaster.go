@@ -0,0 +1,152 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	raycasterFov          = 66.0
+	raycasterMaxSteps     = 64
+	raycasterTextureIndex = 8 // the nearest front wall bitmap (N-south)
+)
+
+// Raycaster renders the maze from any position and angle by casting one ray per viewport column.
+// Walls are textured with the front wall bitmaps of the level, the result is indexed with the level palette.
+type Raycaster struct {
+	mazeRenderer *MazeRenderer
+	planeLength  float64
+	frame        []byte
+}
+
+func NewRaycaster(mazeRenderer *MazeRenderer) *Raycaster {
+	return &Raycaster{
+		mazeRenderer: mazeRenderer,
+		planeLength:  math.Tan(raycasterFov / 2 * math.Pi / 180),
+		frame:        make([]byte, 176*120),
+	}
+}
+
+// Render renders the view from the position (in block units, block centers are at .5) looking at angle
+// (radians, 0 is north, growing clockwise).
+func (rc *Raycaster) Render(posX, posY, angle float64, dst *image.Paletted) *image.Paletted {
+	rc.mazeRenderer.mazeLock.RLock()
+	defer rc.mazeRenderer.mazeLock.RUnlock()
+
+	background := &rc.frame
+	copy(rc.frame, *rc.mazeRenderer.wallRenderer.RenderBackground())
+
+	dirX, dirY := math.Sin(angle), -math.Cos(angle)
+	planeX, planeY := math.Cos(angle)*rc.planeLength, math.Sin(angle)*rc.planeLength
+	textureW, textureH := rc.mazeRenderer.wallRenderer.GetWallSize(raycasterTextureIndex)
+	textureW *= 8
+	textureH *= 8
+
+	for column := 0; column < 176; column++ {
+		cameraX := 2*float64(column)/176 - 1
+		rayX := dirX + planeX*cameraX
+		rayY := dirY + planeY*cameraX
+
+		distance, wallX, wallSet, hit := rc.castRay(posX, posY, rayX, rayY)
+		if !hit {
+			continue
+		}
+
+		texture := rc.mazeRenderer.wallRenderer.RenderWall(wallSet, raycasterTextureIndex)
+		textureX := int(wallX * float64(textureW))
+		lineHeight := int(0.5 * 120 / distance)
+		drawStart := 60 - lineHeight/2
+		shadeTable := rc.mazeRenderer.distanceShading.GetTable(int(distance))
+
+		for y := max(drawStart, 0); y < min(drawStart+lineHeight, 120); y++ {
+			textureY := (y - drawStart) * textureH / lineHeight
+			putPixel(background, column, y, shade(shadeTable, (*texture)[textureY*textureW+textureX]))
+		}
+	}
+
+	return toViewportImage(background, rc.mazeRenderer.palette, dst)
+}
+
+// castRay walks the maze grid along the ray and returns the perpendicular distance of the first solid wall,
+// the horizontal hit position on the wall (0.0-1.0) and its wall set.
+func (rc *Raycaster) castRay(posX, posY, rayX, rayY float64) (float64, float64, int, bool) {
+	mapX, mapY := int(math.Floor(posX)), int(math.Floor(posY))
+	deltaDistX, deltaDistY := math.Abs(1/rayX), math.Abs(1/rayY)
+
+	var stepX, stepY int
+	var sideDistX, sideDistY float64
+	if rayX < 0 {
+		stepX = -1
+		sideDistX = (posX - float64(mapX)) * deltaDistX
+	} else {
+		stepX = 1
+		sideDistX = (float64(mapX) + 1 - posX) * deltaDistX
+	}
+	if rayY < 0 {
+		stepY = -1
+		sideDistY = (posY - float64(mapY)) * deltaDistY
+	} else {
+		stepY = 1
+		sideDistY = (float64(mapY) + 1 - posY) * deltaDistY
+	}
+
+	for i := 0; i < raycasterMaxSteps; i++ {
+		var side int
+		var distance float64
+		if sideDistX < sideDistY {
+			distance = sideDistX
+			sideDistX += deltaDistX
+			mapX += stepX
+			side = 3 // entering through the west side
+			if stepX < 0 {
+				side = 1
+			}
+		} else {
+			distance = sideDistY
+			sideDistY += deltaDistY
+			mapY += stepY
+			side = 0 // entering through the north side
+			if stepY < 0 {
+				side = 2
+			}
+		}
+
+		wallSet, solid := rc.getSolidWall(mapX, mapY, side)
+		if !solid {
+			continue
+		}
+
+		var wallX float64
+		if side == 1 || side == 3 {
+			wallX = posY + distance*rayY
+		} else {
+			wallX = posX + distance*rayX
+		}
+		wallX -= math.Floor(wallX)
+		// keep the texture orientation of the walls as seen from the inside of the blocks
+		if side == 1 || side == 0 {
+			wallX = 1 - wallX
+		}
+		return math.Max(distance, 0.01), math.Min(wallX, 0.999), wallSet, true
+	}
+	return 0, 0, 0, false
+}
+
+// IsPassable reports whether the party can enter the block through the given side.
+func (rc *Raycaster) IsPassable(x, y, side int) bool {
+	maz := rc.mazeRenderer.viewportDataProvider.maz
+	if x < 0 || y < 0 || x >= int(maz.Width) || y >= int(maz.Height) {
+		return false
+	}
+	_, solid := rc.getSolidWall(x, y, side)
+	return !solid
+}
+
+func (rc *Raycaster) getSolidWall(x, y, side int) (int, bool) {
+	vdp := rc.mazeRenderer.viewportDataProvider
+	wallMapping := vdp.inf.FindWallMappingByIndex(vdp.maz.GetMazeBlockByCoordinateOrFake(x, y).Wall[side])
+	if wallMapping == nil || wallMapping.Flags&0x01 != 0 {
+		return 0, false
+	}
+	return getWallSet(*wallMapping)
+}
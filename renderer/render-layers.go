@@ -0,0 +1,179 @@
+package renderer
+
+// LayerMask selects layers of a rendered view.
+type LayerMask uint32
+
+const (
+	LayerBackground LayerMask = 1 << iota
+	LayerWallsRow0            // P/Q
+	LayerWallsRow1            // M/N/O
+	LayerWallsRow2            // H-L
+	LayerWallsRow3            // A-G
+	LayerWallsFar             // rows beyond the original view cone
+	LayerDecorations
+	LayerItems
+
+	AllLayers = LayerBackground | LayerWallsRow0 | LayerWallsRow1 | LayerWallsRow2 | LayerWallsRow3 | LayerWallsFar | LayerDecorations | LayerItems
+)
+
+// Layers lists every single layer in compositing order.
+var Layers = []LayerMask{
+	LayerBackground, LayerWallsFar, LayerWallsRow3, LayerWallsRow2, LayerWallsRow1, LayerWallsRow0, LayerDecorations, LayerItems,
+}
+
+var layerNames = map[LayerMask]string{
+	LayerBackground:  "background",
+	LayerWallsRow0:   "walls-row-0",
+	LayerWallsRow1:   "walls-row-1",
+	LayerWallsRow2:   "walls-row-2",
+	LayerWallsRow3:   "walls-row-3",
+	LayerWallsFar:    "walls-far",
+	LayerDecorations: "decorations",
+	LayerItems:       "items",
+}
+
+func (m LayerMask) String() string {
+	return layerNames[m]
+}
+
+// LayerRow holds the layers of a distance row, 0x00 is transparent in all of them.
+type LayerRow struct {
+	Walls       *Canvas
+	Decorations *Canvas
+	Items       *Canvas
+}
+
+// RenderLayers is a view rendered into separate layers, Rows[0] is the party's own row.
+type RenderLayers struct {
+	Background *[]byte
+	Rows       []LayerRow
+	hits       *hitRecorder
+	order      uint16
+	// viewportData holds the wall mapping indices of the view while it is rendered
+	viewportData []byte
+}
+
+func newRenderLayers(rows int, hitTesting bool) *RenderLayers {
+	var hits *hitRecorder
+	if hitTesting {
+		hits = &hitRecorder{}
+	}
+
+	layers := &RenderLayers{
+		Rows:         make([]LayerRow, rows),
+		hits:         hits,
+		viewportData: make([]byte, Q_WEST+1),
+	}
+	for i := range layers.Rows {
+		layers.Rows[i] = LayerRow{
+			Walls:       newCanvas(hits, &layers.order),
+			Decorations: newCanvas(hits, &layers.order),
+			Items:       newCanvas(hits, &layers.order),
+		}
+	}
+	return layers
+}
+
+// reset clears all layers for rendering a new view on the given background.
+func (rl *RenderLayers) reset(background *[]byte) {
+	rl.Background = background
+	rl.order = 0
+	for _, row := range rl.Rows {
+		row.Walls.clear()
+		row.Decorations.clear()
+		row.Items.clear()
+	}
+	if rl.hits != nil {
+		rl.hits.records = rl.hits.records[:0]
+	}
+}
+
+func getWallLayer(row int) LayerMask {
+	if row > originalViewDepth {
+		return LayerWallsFar
+	}
+	return LayerWallsRow0 << row
+}
+
+// Composite draws the selected layers on top of each other, from the farthest row to the nearest one.
+// The walls and decorations of a row are drawn in the order of their wall positions, each wall followed by its
+// decorations like in the game, the items of a row on top of them. Without the background layer the uncovered
+// pixels stay 0x00.
+func (rl *RenderLayers) Composite(mask LayerMask) *[]byte {
+	result := make([]byte, 176*120)
+	rl.compositeInto(result, mask, nil)
+	return &result
+}
+
+// CompositeHitBuffer composites the selected layers like Composite and keeps which element drew each pixel.
+// It is only available if the layers were rendered with hit-testing.
+func (rl *RenderLayers) CompositeHitBuffer(mask LayerMask) *HitBuffer {
+	if rl.hits == nil {
+		return nil
+	}
+
+	pixels := make([]byte, 176*120)
+	ids := make([]uint16, 176*120)
+	rl.compositeInto(pixels, mask, ids)
+	return &HitBuffer{
+		Pixels:  &pixels,
+		ids:     ids,
+		records: rl.hits.records,
+	}
+}
+
+func (rl *RenderLayers) compositeInto(result []byte, mask LayerMask, ids []uint16) {
+	if mask&LayerBackground != 0 {
+		copy(result, *rl.Background)
+	} else {
+		clear(result)
+	}
+
+	for row := len(rl.Rows) - 1; row >= 0; row-- {
+		walls, decorations := mask&getWallLayer(row) != 0, mask&LayerDecorations != 0
+		switch {
+		case walls && decorations:
+			overlayInDrawingOrder(result, ids, rl.Rows[row].Walls, rl.Rows[row].Decorations)
+		case walls:
+			overlayLayer(result, ids, rl.Rows[row].Walls)
+		case decorations:
+			overlayLayer(result, ids, rl.Rows[row].Decorations)
+		}
+		if mask&LayerItems != 0 {
+			overlayLayer(result, ids, rl.Rows[row].Items)
+		}
+	}
+}
+
+// overlayInDrawingOrder overlays two layers, a pixel drawn in both shows the element drawn last.
+func overlayInDrawingOrder(destination []byte, ids []uint16, below, above *Canvas) {
+	abovePixels := *above.Pixels
+	belowPixels := (*below.Pixels)[:len(abovePixels)]
+	aboveOrders, belowOrders := above.orders[:len(abovePixels)], below.orders[:len(abovePixels)]
+	destination = destination[:len(abovePixels)]
+	for i, a := range abovePixels {
+		b := belowPixels[i]
+		if a|b == 0x00 {
+			continue
+		}
+		layer := above
+		if a == 0x00 || b != 0x00 && belowOrders[i] > aboveOrders[i] {
+			a, layer = b, below
+		}
+		destination[i] = a
+		if ids != nil {
+			ids[i] = layer.ids[i]
+		}
+	}
+}
+
+func overlayLayer(destination []byte, ids []uint16, layer *Canvas) {
+	for i, b := range *layer.Pixels {
+		if b != 0x00 {
+			destination[i] = b
+			if ids != nil {
+				ids[i] = layer.ids[i]
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package renderer
+
+import "testing"
+
+func TestCompositeDrawingOrder(t *testing.T) {
+	for _, hitTesting := range []bool{false, true} {
+		layers := newRenderLayers(originalViewDepth+1, hitTesting)
+		background := make([]byte, 176*120)
+		for i := range background {
+			background[i] = 1
+		}
+		layers.reset(&background)
+
+		// the wall of a position, its decoration and the wall of the next position of row 2 overlapping
+		row := layers.Rows[2]
+		row.Walls.begin(newHitRecord(LayerWallsRow2, 2))
+		row.Walls.putPixel(10, 10, 5)
+		row.Walls.putPixel(11, 10, 5)
+		row.Decorations.begin(newHitRecord(LayerDecorations, 2))
+		row.Decorations.putPixel(10, 10, 6)
+		row.Decorations.putPixel(11, 10, 6)
+		row.Decorations.putPixel(12, 10, 6)
+		row.Walls.begin(newHitRecord(LayerWallsRow2, 2))
+		row.Walls.putPixel(11, 10, 7)
+		// the nearer row covers everything of row 2
+		layers.Rows[1].Walls.begin(newHitRecord(LayerWallsRow1, 1))
+		layers.Rows[1].Walls.putPixel(13, 10, 8)
+		layers.Rows[2].Items.begin(newHitRecord(LayerItems, 2))
+		layers.Rows[2].Items.putPixel(13, 10, 9)
+
+		tests := []struct {
+			mask   LayerMask
+			pixels [5]byte
+		}{
+			{AllLayers, [5]byte{6, 7, 6, 8, 1}},
+			{AllLayers &^ LayerDecorations, [5]byte{5, 7, 1, 8, 1}},
+			{AllLayers &^ LayerWallsRow2, [5]byte{6, 6, 6, 8, 1}},
+			{LayerDecorations | LayerItems, [5]byte{6, 6, 6, 9, 0}},
+		}
+		for _, test := range tests {
+			result := *layers.Composite(test.mask)
+			for i, want := range test.pixels {
+				if got := result[10*176+10+i]; got != want {
+					t.Errorf("hit-testing %v, mask %b: pixel %d is %d instead of %d", hitTesting, test.mask, 10+i, got, want)
+				}
+			}
+		}
+
+		if hitTesting {
+			hits := layers.CompositeHitBuffer(AllLayers)
+			if info := hits.At(11, 10); info.Record == nil || info.Record.Layer != LayerWallsRow2 || info.PaletteIndex != 7 {
+				t.Errorf("hit %+v instead of the second wall", info)
+			}
+			if info := hits.At(10, 10); info.Record == nil || info.Record.Layer != LayerDecorations {
+				t.Errorf("hit %+v instead of the decoration", info)
+			}
+		}
+	}
+}
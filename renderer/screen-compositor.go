@@ -0,0 +1,120 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"image"
+	"image/color"
+	"log"
+	"math"
+)
+
+const (
+	ScreenWidth  = 320
+	ScreenHeight = 200
+
+	// the viewport sits in the top left corner of the game screen
+	viewportOffsetX = 0
+	viewportOffsetY = 0
+
+	// center and needle length of the compass rose below the viewport
+	compassCenterX = 136
+	compassCenterY = 146
+	compassRadius  = 12
+
+	partySlotWidth  = 64
+	partySlotHeight = 50
+)
+
+// party slots right of the viewport, two columns of three characters
+var partySlotX = [2]int{184, 256}
+var partySlotY = [3]int{2, 54, 106}
+
+// ScreenCompositor places the view into the 320x200 game screen: the PLAYFLD.CPS frame, a compass needle
+// following the party's direction and the (empty) party panels.
+type ScreenCompositor struct {
+	playfield []byte
+	palette   color.Palette
+	needle    byte
+	tail      byte
+}
+
+// NewScreenCompositor loads PLAYFLD.CPS from the files of the PAK archives. Without it a plain frame is drawn.
+func NewScreenCompositor(dataFiles map[string]*[]byte, palette color.Palette) *ScreenCompositor {
+	sc := &ScreenCompositor{
+		palette: palette,
+		needle:  findNearestColor(palette, 255, 0, 0),
+		tail:    findNearestColor(palette, 255, 255, 255),
+	}
+
+	if data, ok := dataFiles["PLAYFLD.CPS"]; ok {
+		cps, err := formats.NewCPSFromByteArray(data)
+		if err == nil && len(*cps.GetRawData()) >= ScreenWidth*ScreenHeight {
+			sc.playfield = (*cps.GetRawData())[:ScreenWidth*ScreenHeight]
+		} else {
+			log.Printf("Cannot load PLAYFLD.CPS: %v", err)
+		}
+	}
+	if sc.playfield == nil {
+		sc.playfield = sc.buildPlainFrame()
+	}
+	return sc
+}
+
+// Compose draws the view and the compass of the direction into the game screen. The screen is written into dst
+// if it is not nil, otherwise a new image is allocated.
+func (sc *ScreenCompositor) Compose(view *image.Paletted, direction int, dst *image.Paletted) *image.Paletted {
+	if dst == nil || dst.Rect.Dx() != ScreenWidth || dst.Rect.Dy() != ScreenHeight {
+		dst = image.NewPaletted(image.Rect(0, 0, ScreenWidth, ScreenHeight), sc.palette)
+	}
+	dst.Palette = view.Palette
+
+	for y := 0; y < ScreenHeight; y++ {
+		offset := dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y)
+		copy(dst.Pix[offset:offset+ScreenWidth], sc.playfield[y*ScreenWidth:(y+1)*ScreenWidth])
+	}
+	for y := 0; y < view.Rect.Dy(); y++ {
+		source := view.PixOffset(view.Rect.Min.X, view.Rect.Min.Y+y)
+		target := dst.PixOffset(dst.Rect.Min.X+viewportOffsetX, dst.Rect.Min.Y+viewportOffsetY+y)
+		copy(dst.Pix[target:target+view.Rect.Dx()], view.Pix[source:source+view.Rect.Dx()])
+	}
+
+	sc.drawCompass(dst, direction)
+	return dst
+}
+
+// drawCompass draws a needle pointing north, the top of the compass is the party's direction.
+func (sc *ScreenCompositor) drawCompass(dst *image.Paletted, direction int) {
+	angle := -float64(direction) * math.Pi / 2
+	dx, dy := math.Sin(angle), -math.Cos(angle)
+	for i := -compassRadius; i <= compassRadius; i++ {
+		b := sc.needle
+		if i < 0 {
+			b = sc.tail
+		}
+		x := compassCenterX + int(math.Round(dx*float64(i)))
+		y := compassCenterY + int(math.Round(dy*float64(i)))
+		dst.SetColorIndex(x, y, b)
+		dst.SetColorIndex(x+int(math.Round(-dy)), y+int(math.Round(dx)), b)
+	}
+}
+
+// buildPlainFrame draws a gray screen with dark empty party panels, used when PLAYFLD.CPS is missing.
+func (sc *ScreenCompositor) buildPlainFrame() []byte {
+	frame := make([]byte, ScreenWidth*ScreenHeight)
+	background := findNearestColor(sc.palette, 128, 128, 128)
+	panel := findNearestColor(sc.palette, 48, 48, 48)
+	for i := range frame {
+		frame[i] = background
+	}
+
+	for _, x := range partySlotX {
+		for _, y := range partySlotY {
+			for py := y; py < y+partySlotHeight; py++ {
+				for px := x; px < x+partySlotWidth; px++ {
+					frame[py*ScreenWidth+px] = panel
+				}
+			}
+		}
+	}
+	return frame
+}
@@ -0,0 +1,142 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// FontNames are the font files tried by LoadTextRenderer, the first one is the font of the game's text area.
+var FontNames = []string{"FONT6.FNT", "FONT8.FNT"}
+
+const (
+	// text area of the game screen below the viewport and the party panels
+	textAreaX     = 2
+	textAreaY     = 177
+	textAreaWidth = ScreenWidth - 2*textAreaX
+
+	// space between the bottom of a message and the bottom of the viewport
+	viewportTextMargin = 2
+)
+
+// TextRenderer draws text with a FNT font into indexed images, pixels not covered by a glyph are left unchanged.
+type TextRenderer struct {
+	font *formats.FNT
+}
+
+func NewTextRenderer(font *formats.FNT) *TextRenderer {
+	return &TextRenderer{font: font}
+}
+
+// LoadTextRenderer loads the first available font of FontNames from the files of the PAK archives.
+func LoadTextRenderer(dataFiles map[string]*[]byte) (*TextRenderer, error) {
+	for _, name := range FontNames {
+		if data, ok := dataFiles[name]; ok {
+			font, err := formats.NewFNTFromByteArray(data)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load %s: %w", name, err)
+			}
+			return NewTextRenderer(font), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot find a font, tried %s", strings.Join(FontNames, ", "))
+}
+
+func (tr *TextRenderer) GetLineHeight() int {
+	return tr.font.Height
+}
+
+// TextWidth returns the width of a single line in pixels, the font has a fixed width.
+func (tr *TextRenderer) TextWidth(text string) int {
+	return len(text) * tr.font.Width
+}
+
+// Wrap breaks text into lines not wider than width. Words are split at spaces, '\r' and '\n' start a new line
+// like in the messages of the scripts. Words longer than a line are cut.
+func (tr *TextRenderer) Wrap(text string, width int) []string {
+	maxChars := width / tr.font.Width
+	if maxChars < 1 {
+		maxChars = 1
+	}
+
+	var lines []string
+	for _, paragraph := range strings.FieldsFunc(strings.ReplaceAll(text, "\r\n", "\n"), func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len(word) > maxChars {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:maxChars])
+				word = word[maxChars:]
+			}
+			switch {
+			case line == "":
+				line = word
+			case len(line)+1+len(word) <= maxChars:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// DrawText draws a single line with the top left corner at x, y. Characters without a glyph are drawn as spaces.
+func (tr *TextRenderer) DrawText(dst *image.Paletted, text string, x, y int, colorIndex byte) {
+	for i := 0; i < len(text); i++ {
+		glyph := tr.font.GetGlyph(text[i])
+		if glyph != nil {
+			tr.drawGlyph(dst, glyph, x+i*tr.font.Width, y, colorIndex)
+		}
+	}
+}
+
+func (tr *TextRenderer) drawGlyph(dst *image.Paletted, glyph []byte, x, y int, colorIndex byte) {
+	for gy := 0; gy < tr.font.Height; gy++ {
+		for gx := 0; gx < tr.font.Width; gx++ {
+			if tr.font.IsSet(glyph, gx, gy) {
+				dst.SetColorIndex(dst.Rect.Min.X+x+gx, dst.Rect.Min.Y+y+gy, colorIndex)
+			}
+		}
+	}
+}
+
+// DrawWrappedText draws text wrapped to width starting at x, y and returns the number of lines drawn.
+func (tr *TextRenderer) DrawWrappedText(dst *image.Paletted, text string, x, y, width int, colorIndex byte) int {
+	lines := tr.Wrap(text, width)
+	for i, line := range lines {
+		tr.DrawText(dst, line, x, y+i*tr.font.Height, colorIndex)
+	}
+	return len(lines)
+}
+
+// DrawMessage shows a script message the way the game does. On a game screen the message goes into the text area
+// below the party panels keeping the last lines that fit, like the scrolling text area of the game. On a bare
+// view it is drawn over the bottom of the view with a dark shadow.
+func (tr *TextRenderer) DrawMessage(dst *image.Paletted, text string, colorIndex byte) {
+	height := tr.font.Height
+	if dst.Rect.Dx() == ScreenWidth && dst.Rect.Dy() == ScreenHeight {
+		lines := tr.Wrap(text, textAreaWidth)
+		if maxLines := (ScreenHeight - textAreaY) / height; len(lines) > maxLines {
+			lines = lines[len(lines)-maxLines:]
+		}
+		for i, line := range lines {
+			tr.DrawText(dst, line, textAreaX, textAreaY+i*height, colorIndex)
+		}
+		return
+	}
+
+	lines := tr.Wrap(text, dst.Rect.Dx()-2*viewportTextMargin)
+	top := dst.Rect.Dy() - viewportTextMargin - len(lines)*height
+	shadow := findNearestColor(dst.Palette, 0, 0, 0)
+	for i, line := range lines {
+		tr.DrawText(dst, line, viewportTextMargin+1, top+i*height+1, shadow)
+		tr.DrawText(dst, line, viewportTextMargin, top+i*height, colorIndex)
+	}
+}
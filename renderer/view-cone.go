@@ -0,0 +1,234 @@
+package renderer
+
+import (
+	"EOB1MazeViewer/formats"
+	"fmt"
+)
+
+const (
+	originalViewDepth = 3
+	originalViewWidth = 7
+
+	// blocks of the rows beyond are narrower than a pixel
+	maxViewDepth = 10
+	// the width of a level
+	maxViewWidth = 31
+
+	coneFront = 0
+	coneSide  = 1
+
+	// the farthest available front and side wall bitmaps (D-south and B-east)
+	farFrontWallIndex = 6
+	farSideWallIndex  = 4
+
+	// shrink ratio of a block row beyond the original view cone
+	farRowRatio = 0.6
+	horizonY    = 40
+)
+
+// ViewCone is the visible area in front of the party. Depth is the number of block rows
+// in front of the party's own row, Width the number of blocks in a row (odd).
+// The original game uses a depth of 3 and a width of 7. The view cone can only grow: the rows
+// of the original view always show their 7 blocks, Width widens the rows beyond them.
+type ViewCone struct {
+	Depth int
+	Width int
+}
+
+var DefaultViewCone = ViewCone{Depth: originalViewDepth, Width: originalViewWidth}
+
+// Validate returns an error for a view cone smaller than the original view or too large to be drawn.
+func (c ViewCone) Validate() error {
+	if c.Depth < originalViewDepth || c.Depth > maxViewDepth {
+		return fmt.Errorf("view depth %d is not between %d and %d", c.Depth, originalViewDepth, maxViewDepth)
+	}
+	if c.Width < originalViewWidth || c.Width > maxViewWidth || c.Width%2 == 0 {
+		return fmt.Errorf("view width %d is not an odd number between %d and %d", c.Width, originalViewWidth, maxViewWidth)
+	}
+	return nil
+}
+
+// ConeWall is a wall beyond the original view cone.
+type ConeWall struct {
+	Row              int
+	XDelta           int
+	Side             int
+	MazeX, MazeY     int
+	WallDirection    int
+	WallMappingIndex byte
+}
+
+// Boundaries of the block rows of the original view, index k is the k-th boundary in front of the party
+var coneBoundaryHalfWidth = [4]float64{96, 64, 40, 24}
+var coneBoundaryTop = [4]float64{0, 8, 16, 24}
+var coneBoundaryBottom = [4]float64{120, 104, 80, 64}
+
+// getConeBoundary returns the half width, the top and the bottom of the k-th block boundary in the viewport.
+// Boundaries beyond the original view are extrapolated.
+func getConeBoundary(k int) (float64, float64, float64) {
+	if k < len(coneBoundaryHalfWidth) {
+		return coneBoundaryHalfWidth[k], coneBoundaryTop[k], coneBoundaryBottom[k]
+	}
+
+	halfWidth, top, bottom := getConeBoundary(k - 1)
+	return halfWidth * farRowRatio, horizonY - (horizonY-top)*farRowRatio, horizonY + (bottom-horizonY)*farRowRatio
+}
+
+// GetExtendedViewportData returns the walls of the rows beyond the original view cone,
+// from the farthest row to the nearest one and from the outermost block to the middle one.
+func (vdp *ViewportDataProvider) GetExtendedViewportData(x, y, direction int, cone ViewCone) []ConeWall {
+	var result []ConeWall
+	halfWidth := cone.Width / 2
+
+	for row := cone.Depth; row > originalViewDepth; row-- {
+		xDeltas := make([]int, 0, cone.Width)
+		for i := halfWidth; i > 0; i-- {
+			xDeltas = append(xDeltas, -i, i)
+		}
+		xDeltas = append(xDeltas, 0)
+
+		// side walls first, then the front walls of the row
+		for _, xDelta := range xDeltas {
+			if xDelta == 0 {
+				continue
+			}
+			sideDirection := 1
+			if xDelta > 0 {
+				sideDirection = 3
+			}
+			result = append(result, vdp.getConeWall(x, y, direction, row, xDelta, coneSide, sideDirection))
+		}
+		for _, xDelta := range xDeltas {
+			result = append(result, vdp.getConeWall(x, y, direction, row, xDelta, coneFront, 2))
+		}
+	}
+	return result
+}
+
+func (vdp *ViewportDataProvider) getConeWall(x, y, direction, row, xDelta, side, relativeDirection int) ConeWall {
+	finalX, finalY := GetMazeCoordinate(x, y, direction, xDelta, -row)
+	wallDirection := (direction + relativeDirection) & 0x03
+	return ConeWall{
+		Row:              row,
+		XDelta:           xDelta,
+		Side:             side,
+		MazeX:            finalX,
+		MazeY:            finalY,
+		WallDirection:    wallDirection,
+		WallMappingIndex: vdp.maz.GetMazeBlockByCoordinateOrFake(finalX, finalY).Wall[wallDirection],
+	}
+}
+
+// renderFarRows draws the walls beyond the original view cone, downscaled from the farthest wall bitmaps.
+func (mr *MazeRenderer) renderFarRows(layers *RenderLayers, x, y, direction int) {
+	for _, coneWall := range mr.viewportDataProvider.GetExtendedViewportData(x, y, direction, mr.viewCone) {
+		wallMapping := mr.viewportDataProvider.inf.FindWallMappingByIndex(coneWall.WallMappingIndex)
+		if wallMapping == nil {
+			continue
+		}
+		wallSet, ok := getWallSet(*wallMapping)
+		if !ok {
+			continue
+		}
+
+		shadeTable := mr.distanceShading.GetTable(coneWall.Row)
+		walls := layers.Rows[coneWall.Row].Walls
+		record := newHitRecord(LayerWallsFar, coneWall.Row)
+		record.MazeX, record.MazeY = coneWall.MazeX, coneWall.MazeY
+		record.Side = coneWall.WallDirection
+		record.WallMappingIndex = int(coneWall.WallMappingIndex)
+		walls.begin(record)
+		if coneWall.Side == coneFront {
+			mr.renderFarFrontWall(walls, coneWall, wallSet, shadeTable)
+		} else {
+			mr.renderFarSideWall(walls, coneWall, wallSet, shadeTable)
+		}
+	}
+}
+
+func (mr *MazeRenderer) renderFarFrontWall(walls *Canvas, coneWall ConeWall, wallSet int, shadeTable *[256]byte) {
+	wall := mr.wallRenderer.RenderWall(wallSet, farFrontWallIndex)
+	w, h := mr.wallRenderer.GetWallSize(farFrontWallIndex)
+	w *= 8
+	h *= 8
+
+	halfWidth, top, bottom := getConeBoundary(coneWall.Row)
+	left := 88 + (float64(coneWall.XDelta)-0.5)*halfWidth*2
+	right := 88 + (float64(coneWall.XDelta)+0.5)*halfWidth*2
+
+	for dy := int(top); dy < int(bottom); dy++ {
+		sy := (dy - int(top)) * h / (int(bottom) - int(top))
+		for dx := int(left); dx < int(right); dx++ {
+			sx := (dx - int(left)) * w / (int(right) - int(left))
+			putViewportPixel(walls, dx, dy, shade(shadeTable, (*wall)[sy*w+sx]))
+		}
+	}
+}
+
+func (mr *MazeRenderer) renderFarSideWall(walls *Canvas, coneWall ConeWall, wallSet int, shadeTable *[256]byte) {
+	wall := mr.wallRenderer.RenderWall(wallSet, farSideWallIndex)
+	w, h := mr.wallRenderer.GetWallSize(farSideWallIndex)
+	w *= 8
+	h *= 8
+
+	// the side wall faces the middle of the row, its near edge is on the outer side
+	edge := 0.5
+	if coneWall.XDelta > 0 {
+		edge = -0.5
+	}
+	nearHalfWidth, nearTop, nearBottom := getConeBoundary(coneWall.Row)
+	farHalfWidth, farTop, farBottom := getConeBoundary(coneWall.Row + 1)
+	nearX := 88 + (float64(coneWall.XDelta)+edge)*nearHalfWidth*2
+	farX := 88 + (float64(coneWall.XDelta)+edge)*farHalfWidth*2
+
+	from, to := int(nearX), int(farX)
+	if from > to {
+		from, to = to, from
+	}
+	for dx := from; dx < to; dx++ {
+		t := (float64(dx) - nearX) / (farX - nearX)
+		sx := int(t * float64(w))
+		if sx < 0 || sx >= w {
+			continue
+		}
+		if coneWall.XDelta > 0 {
+			sx = w - 1 - sx
+		}
+
+		top := nearTop + (farTop-nearTop)*t
+		bottom := nearBottom + (farBottom-nearBottom)*t
+		for dy := int(top); dy < int(bottom); dy++ {
+			sy := (dy - int(top)) * h / (int(bottom) - int(top))
+			putViewportPixel(walls, dx, dy, shade(shadeTable, (*wall)[sy*w+sx]))
+		}
+	}
+}
+
+func putViewportPixel(target *Canvas, x int, y int, b byte) {
+	if x < 0 || x >= 176 {
+		return
+	}
+	target.putPixel(x, y, b)
+}
+
+// getWallSet returns the wall set used to draw a wall mapping, false if nothing has to be drawn.
+func getWallSet(wallMapping formats.WallMapping) (int, bool) {
+	switch wallMapping.WallMappingIndex {
+	case 0:
+		return 0, false
+	case 1, 2:
+		return 1, true
+	case 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22:
+		// door stuff
+		return 2, true
+	case 23:
+		return 3, true
+	case 24:
+		return 4, true
+	default:
+		if wallMapping.WallSetId == 0 {
+			return 0, false
+		}
+		return wallMapping.WallSetId, true
+	}
+}
@@ -0,0 +1,55 @@
+package renderer
+
+import "testing"
+
+func TestViewConeValidate(t *testing.T) {
+	tests := []struct {
+		cone  ViewCone
+		valid bool
+	}{
+		{DefaultViewCone, true},
+		{ViewCone{Depth: 10, Width: 31}, true},
+		{ViewCone{Depth: 5, Width: 9}, true},
+		{ViewCone{Depth: 2, Width: 7}, false},
+		{ViewCone{Depth: 11, Width: 7}, false},
+		{ViewCone{Depth: 3, Width: 5}, false},
+		{ViewCone{Depth: 3, Width: 8}, false},
+		{ViewCone{Depth: 3, Width: 33}, false},
+	}
+	mr := newTestMazeRenderer(t)
+	for _, test := range tests {
+		err := mr.SetViewCone(test.cone)
+		if (err == nil) != test.valid {
+			t.Errorf("%+v: %v", test.cone, err)
+		}
+		if err != nil && mr.viewCone != DefaultViewCone {
+			t.Errorf("%+v set although it is invalid", test.cone)
+		}
+		mr.SetViewCone(DefaultViewCone)
+	}
+}
+
+func TestExtendedViewportData(t *testing.T) {
+	vdp := newTestMazeRenderer(t).viewportDataProvider
+	if walls := vdp.GetExtendedViewportData(10, 15, 0, DefaultViewCone); len(walls) != 0 {
+		t.Errorf("%d walls beyond the original view", len(walls))
+	}
+
+	walls := vdp.GetExtendedViewportData(10, 15, 0, ViewCone{Depth: 5, Width: 9})
+	// two rows of 8 side walls and 9 front walls
+	if len(walls) != 2*(8+9) {
+		t.Fatalf("%d walls", len(walls))
+	}
+	if walls[0].Row != 5 || walls[len(walls)-1].Row != 4 {
+		t.Errorf("rows from %d to %d instead of the farthest to the nearest", walls[0].Row, walls[len(walls)-1].Row)
+	}
+	for _, wall := range walls {
+		if wall.XDelta < -4 || wall.XDelta > 4 {
+			t.Errorf("wall %+v outside the width", wall)
+		}
+		// facing north, the rows are above the party
+		if wall.MazeX != 10+wall.XDelta || wall.MazeY != 15-wall.Row {
+			t.Errorf("wall %+v not in front of the party", wall)
+		}
+	}
+}
@@ -69,26 +69,33 @@ func NewViewportDataProvider(inf *formats.InfHeader, maz *formats.Maz) *Viewport
 }
 
 func (vdp *ViewportDataProvider) GetViewportData(x, y, direction int) ViewportData {
-	viewportData := make([]byte, 25)
-	var deltaX, deltaY int
-	for i := A_EAST; i <= Q_WEST; i++ {
-		if direction%2 != 0 {
-			deltaX = MazeDirection[direction].xs * MazePositions[i].YDelta
-			deltaY = MazeDirection[direction].ys * MazePositions[i].XDelta
-		} else {
-			deltaX = MazeDirection[direction].xs * MazePositions[i].XDelta
-			deltaY = MazeDirection[direction].ys * MazePositions[i].YDelta
-		}
+	viewportData := make([]byte, Q_WEST+1)
+	vdp.fillViewportData(x, y, direction, viewportData)
+	return &viewportData
+}
 
-		finalX := x + deltaX
-		finalY := y + deltaY
+// fillViewportData writes the wall mapping indices of the wall positions A_EAST to Q_WEST into viewportData.
+func (vdp *ViewportDataProvider) fillViewportData(x, y, direction int, viewportData []byte) {
+	for i := A_EAST; i <= Q_WEST; i++ {
+		finalX, finalY := GetMazeCoordinate(x, y, direction, MazePositions[i].XDelta, MazePositions[i].YDelta)
 
 		wallDirection := (direction + MazePositions[i].Direction) & 0x03
 
 		wallMappingIndex := vdp.maz.GetMazeBlockByCoordinateOrFake(finalX, finalY).Wall[wallDirection]
 		viewportData[i] = wallMappingIndex
 	}
+}
 
-	return &viewportData
-
+// GetMazeCoordinate converts a position relative to the party (xDelta to the right, yDelta forward being negative)
+// into an absolute maze coordinate.
+func GetMazeCoordinate(x, y, direction, xDelta, yDelta int) (int, int) {
+	var deltaX, deltaY int
+	if direction%2 != 0 {
+		deltaX = MazeDirection[direction].xs * yDelta
+		deltaY = MazeDirection[direction].ys * xDelta
+	} else {
+		deltaX = MazeDirection[direction].xs * xDelta
+		deltaY = MazeDirection[direction].ys * yDelta
+	}
+	return x + deltaX, y + deltaY
 }
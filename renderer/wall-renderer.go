@@ -2,7 +2,8 @@ package renderer
 
 import (
 	"EOB1MazeViewer/formats"
-	"fmt"
+	"log"
+	"sync"
 )
 
 var offsetTable = [][]int{
@@ -17,13 +18,25 @@ var offsetTable = [][]int{
 	{569, 16, 12},
 }
 
+type wallKey struct {
+	wallSet int
+	wall    int
+}
+
+// WallRenderer builds the wall and background bitmaps from the VMP codes. The bitmaps only depend on
+// the level, they are built once and shared, so they must not be modified. It is safe for concurrent use.
 type WallRenderer struct {
-	vcn *formats.VCN
-	vmp *formats.VMP
+	vcn               *formats.VCN
+	vmp               *formats.VMP
+	mutex             sync.Mutex
+	walls             map[wallKey]*[]byte
+	backgroundOnce    sync.Once
+	background        *[]byte
+	flippedBackground *[]byte
 }
 
 func NewWallRenderer(vcn *formats.VCN, vmp *formats.VMP) *WallRenderer {
-	return &WallRenderer{vcn: vcn, vmp: vmp}
+	return &WallRenderer{vcn: vcn, vmp: vmp, walls: make(map[wallKey]*[]byte)}
 }
 
 func (wr *WallRenderer) Render(baseOffset int, width int, height int, colors []byte) *[]byte {
@@ -32,12 +45,12 @@ func (wr *WallRenderer) Render(baseOffset int, width int, height int, colors []b
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
 			offset := baseOffset + x + y*width
-			var vmpCode = 0
-			if offset > len(wr.vmp.Codes) {
-				fmt.Printf("Invalid vmp code offset: %d\n", offset)
-				continue
+			if offset >= len(wr.vmp.Codes) {
+				// the offsets only grow, the remaining blocks stay transparent
+				log.Printf("Invalid VMP code offset %d, the VMP has %d codes", offset, len(wr.vmp.Codes))
+				return &rawPixels
 			}
-			vmpCode = wr.vmp.Codes[offset]
+			vmpCode := wr.vmp.Codes[offset]
 			tileIndex := vmpCode & 0x3fff
 			tileFlipped := (vmpCode & 0x4000) == 0x4000
 			tile := wr.vcn.GetTile(tileIndex)
@@ -69,7 +82,21 @@ func (wr *WallRenderer) drawBlock(tileFlipped bool, x int, y int, width int, raw
 }
 
 func (wr *WallRenderer) RenderBackground() *[]byte {
-	return wr.Render(0, 22, 15, wr.vcn.GetBackgroundColors())
+	wr.renderBackgrounds()
+	return wr.background
+}
+
+// RenderFlippedBackground returns the background mirrored horizontally, the game alternates both on every step and turn.
+func (wr *WallRenderer) RenderFlippedBackground() *[]byte {
+	wr.renderBackgrounds()
+	return wr.flippedBackground
+}
+
+func (wr *WallRenderer) renderBackgrounds() {
+	wr.backgroundOnce.Do(func() {
+		wr.background = wr.Render(0, 22, 15, wr.vcn.GetBackgroundColors())
+		wr.flippedBackground = flipBackgroundX(wr.background, 176, 120)
+	})
 }
 
 func (wr *WallRenderer) RenderFakeBackground(v formats.VCN) *[]byte {
@@ -85,10 +112,19 @@ func (wr *WallRenderer) GetBackgroundSize() (int, int) {
 }
 
 func (wr *WallRenderer) RenderWall(wallSet int, wall int) *[]byte {
+	key := wallKey{wallSet: wallSet, wall: wall}
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+	if bitmap, ok := wr.walls[key]; ok {
+		return bitmap
+	}
+
 	base := offsetTable[wall][0] + wallSet*431
 	wallW := offsetTable[wall][1]
 	wallH := offsetTable[wall][2]
-	return wr.Render(base, wallW, wallH, wr.vcn.GetWallColors())
+	bitmap := wr.Render(base, wallW, wallH, wr.vcn.GetWallColors())
+	wr.walls[key] = bitmap
+	return bitmap
 }
 
 func (wr *WallRenderer) GetWallSize(wall int) (int, int) {
@@ -0,0 +1,154 @@
+package scaler
+
+import (
+	"fmt"
+	"github.com/virtualparadox/xbrscaler"
+	"image"
+	"math"
+	"strings"
+)
+
+// Scaler upscales pixels packed as A<<24 | B<<16 | G<<8 | R, the byte order of RGBA images and Ebiten's WritePixels.
+// All scalers are stateless and can be used concurrently.
+type Scaler interface {
+	Name() string
+	Factor() int
+	Scale(pixels *[]uint32, width, height int) (*[]uint32, int, int)
+}
+
+type none struct{}
+
+func (none) Name() string { return "none" }
+func (none) Factor() int  { return 1 }
+
+func (none) Scale(pixels *[]uint32, width, height int) (*[]uint32, int, int) {
+	return pixels, width, height
+}
+
+// nearest repeats every pixel, the output stays unfiltered.
+type nearest struct {
+	factor int
+}
+
+func (n nearest) Name() string { return fmt.Sprintf("nearest%dx", n.factor) }
+func (n nearest) Factor() int  { return n.factor }
+
+func (n nearest) Scale(pixels *[]uint32, width, height int) (*[]uint32, int, int) {
+	scaledWidth, scaledHeight := width*n.factor, height*n.factor
+	scaled := make([]uint32, scaledWidth*scaledHeight)
+	for y := 0; y < scaledHeight; y++ {
+		source := (*pixels)[(y/n.factor)*width : (y/n.factor+1)*width]
+		row := scaled[y*scaledWidth : (y+1)*scaledWidth]
+		for x := range row {
+			row[x] = source[x/n.factor]
+		}
+	}
+	return &scaled, scaledWidth, scaledHeight
+}
+
+// xbr smooths the edges with the xBR algorithm.
+type xbr struct {
+	factor int
+	xbr    *xbrscaler.Xbr
+}
+
+func (x xbr) Name() string { return fmt.Sprintf("xbr%dx", x.factor) }
+func (x xbr) Factor() int  { return x.factor }
+
+func (x xbr) Scale(pixels *[]uint32, width, height int) (*[]uint32, int, int) {
+	switch x.factor {
+	case 2:
+		return x.xbr.Xbr2x(pixels, width, height, true, true)
+	case 3:
+		return x.xbr.Xbr3x(pixels, width, height, true, true)
+	default:
+		return x.xbr.Xbr4x(pixels, width, height, true, true)
+	}
+}
+
+var xbrScaler = xbrscaler.NewXbrScaler(false)
+
+var (
+	None      Scaler = none{}
+	Nearest2x Scaler = nearest{factor: 2}
+	Nearest3x Scaler = nearest{factor: 3}
+	Nearest4x Scaler = nearest{factor: 4}
+	Xbr2x     Scaler = xbr{factor: 2, xbr: xbrScaler}
+	Xbr3x     Scaler = xbr{factor: 3, xbr: xbrScaler}
+	Xbr4x     Scaler = xbr{factor: 4, xbr: xbrScaler}
+)
+
+// Scalers lists every available scaler.
+var Scalers = []Scaler{None, Nearest2x, Nearest3x, Nearest4x, Xbr2x, Xbr3x, Xbr4x}
+
+// ByName returns the scaler with the given name, e.g. nearest3x or xbr4x.
+func ByName(name string) (Scaler, error) {
+	for _, s := range Scalers {
+		if s.Name() == strings.ToLower(name) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown scaler %s", name)
+}
+
+// Names returns the names of all scalers.
+func Names() []string {
+	names := make([]string, len(Scalers))
+	for i, s := range Scalers {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// Next returns the scaler following s in Scalers, used for cycling through them.
+func Next(s Scaler) Scaler {
+	for i, candidate := range Scalers {
+		if candidate == s {
+			return Scalers[(i+1)%len(Scalers)]
+		}
+	}
+	return Scalers[0]
+}
+
+// Fit returns the largest integer scale an image fits a window with and the offset centering it, letterboxed.
+// An image larger than the window gets a scale below 1 instead.
+func Fit(width, height, windowWidth, windowHeight int) (scale, offsetX, offsetY float64) {
+	scale = math.Min(float64(windowWidth)/float64(width), float64(windowHeight)/float64(height))
+	if scale >= 1 {
+		scale = math.Floor(scale)
+	}
+	offsetX = math.Floor((float64(windowWidth) - float64(width)*scale) / 2)
+	offsetY = math.Floor((float64(windowHeight) - float64(height)*scale) / 2)
+	return scale, offsetX, offsetY
+}
+
+// Pack converts a paletted image into pixels for the scalers.
+func Pack(img *image.Paletted) *[]uint32 {
+	var lut [256]uint32
+	for i, c := range img.Palette {
+		r, g, b, a := c.RGBA()
+		lut[i] = (a>>8)<<24 | (b>>8)<<16 | (g>>8)<<8 | r>>8
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	pixels := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		offset := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+		for x, index := range img.Pix[offset : offset+width] {
+			pixels[y*width+x] = lut[index]
+		}
+	}
+	return &pixels
+}
+
+// ToRGBA converts scaled pixels into an image.
+func ToRGBA(pixels *[]uint32, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, pixel := range *pixels {
+		img.Pix[i*4] = byte(pixel)
+		img.Pix[i*4+1] = byte(pixel >> 8)
+		img.Pix[i*4+2] = byte(pixel >> 16)
+		img.Pix[i*4+3] = byte(pixel >> 24)
+	}
+	return img
+}
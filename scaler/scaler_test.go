@@ -0,0 +1,90 @@
+package scaler
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNearest(t *testing.T) {
+	// 2x2 pixels: a b / c d
+	pixels := []uint32{1, 2, 3, 4}
+	for _, s := range []Scaler{None, Nearest2x, Nearest3x, Nearest4x} {
+		scaled, width, height := s.Scale(&pixels, 2, 2)
+		factor := s.Factor()
+		if width != 2*factor || height != 2*factor || len(*scaled) != width*height {
+			t.Errorf("%s: %dx%d, %d pixels", s.Name(), width, height, len(*scaled))
+			continue
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if want := pixels[(y/factor)*2+x/factor]; (*scaled)[y*width+x] != want {
+					t.Errorf("%s: pixel %d,%d is %d instead of %d", s.Name(), x, y, (*scaled)[y*width+x], want)
+				}
+			}
+		}
+	}
+}
+
+func TestXbrSize(t *testing.T) {
+	pixels := make([]uint32, 8*5)
+	for _, s := range []Scaler{Xbr2x, Xbr3x, Xbr4x} {
+		if scaled, width, height := s.Scale(&pixels, 8, 5); width != 8*s.Factor() || height != 5*s.Factor() || len(*scaled) != width*height {
+			t.Errorf("%s: %dx%d, %d pixels", s.Name(), width, height, len(*scaled))
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		windowWidth, windowHeight int
+		scale, offsetX, offsetY   float64
+	}{
+		// the view of 176x120 fits exactly
+		{176, 120, 1, 0, 0},
+		{352, 240, 2, 0, 0},
+		// letterboxed: the smaller factor wins, the rest is split
+		{1000, 500, 4, 148, 10},
+		{530, 1000, 3, 1, 320},
+		{351, 239, 1, 87, 59},
+		// downscaled below the view size
+		{88, 120, 0.5, 0, 30},
+	}
+	for _, test := range tests {
+		scale, offsetX, offsetY := Fit(176, 120, test.windowWidth, test.windowHeight)
+		if scale != test.scale || offsetX != test.offsetX || offsetY != test.offsetY {
+			t.Errorf("%dx%d: scale %g at %g,%g instead of %g at %g,%g", test.windowWidth, test.windowHeight,
+				scale, offsetX, offsetY, test.scale, test.offsetX, test.offsetY)
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, s := range Scalers {
+		if found, err := ByName(s.Name()); err != nil || found != s {
+			t.Errorf("%s found as %v, %v", s.Name(), found, err)
+		}
+	}
+	if found, err := ByName("XBR2X"); err != nil || found != Xbr2x {
+		t.Errorf("XBR2X found as %v, %v", found, err)
+	}
+	if _, err := ByName("hq2x"); err == nil {
+		t.Error("hq2x found")
+	}
+	if Next(Xbr4x) != None || Next(None) != Nearest2x {
+		t.Error("scalers not cycled in order")
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	palette := color.Palette{color.RGBA{0x10, 0x20, 0x30, 0xff}, color.RGBA{0xff, 0x80, 0x00, 0xff}}
+	img := image.NewPaletted(image.Rect(0, 0, 2, 1), palette)
+	img.Pix[1] = 1
+	pixels := Pack(img)
+	if (*pixels)[0] != 0xff302010 || (*pixels)[1] != 0xff0080ff {
+		t.Errorf("packed as %08x", *pixels)
+	}
+	if rgba := ToRGBA(pixels, 2, 1); rgba.RGBAAt(1, 0) != palette[1] {
+		t.Errorf("converted to %v", rgba.RGBAAt(1, 0))
+	}
+}
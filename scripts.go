@@ -0,0 +1,88 @@
+package main
+
+import (
+	"EOB1MazeViewer/renderer"
+	"EOB1MazeViewer/vm"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScriptMessages is the number of script messages kept in the text area, older ones scroll out.
+const maxScriptMessages = 3
+
+// initMachine prepares the script interpreter of a level, the party is loaded from a JSON file if partyFile is set.
+func initMachine(level string, mazeRenderer *renderer.MazeRenderer, dataFiles map[string]*[]byte, partyFile string) *vm.Machine {
+	party := vm.NewParty()
+	if partyFile != "" {
+		var err error
+		if party, err = vm.LoadParty(partyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	levelNumber, _ := strconv.Atoi(level)
+	levelState := vm.NewLevelState(levelNumber, mazeRenderer.GetInf(), mazeRenderer, vm.LoadLevelItems(dataFiles, levelNumber))
+	return vm.NewMachine(party, levelState, time.Now().UnixNano())
+}
+
+// updateWallClick fires a wall click on the block in front of the party for Space or a left click on the view.
+func (g *Game) updateWallClick() {
+	clicked := inpututil.IsKeyJustPressed(ebiten.KeySpace)
+	if !g.hitTesting && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := g.cursorToView()
+		clicked = clicked || x >= 0 && y >= 0 && x < renderer.ViewportWidth && y < renderer.ViewportHeight
+	}
+	if !clicked {
+		return
+	}
+
+	x, y := step(g.x, g.y, g.direction)
+	g.fireEvent(x, y, vm.EventWallClick)
+}
+
+// fireEvent runs the scripts of a block and applies their effects to the view. It reports whether the scripts
+// moved the party.
+func (g *Game) fireEvent(x, y int, event vm.Event) bool {
+	if g.machine == nil {
+		return false
+	}
+
+	party := g.machine.Party
+	party.X, party.Y, party.Direction = g.x, g.y, g.direction
+	effects, err := g.machine.Fire(x, y, event)
+	if err != nil {
+		log.Printf("Script error: %s", err)
+	}
+
+	if effects.PartyMoved {
+		g.x, g.y, g.direction = party.X, party.Y, party.Direction
+	}
+	if effects.WallsChanged {
+		g.frameCache.invalidate()
+		g.prevDirection = -1
+	}
+	for _, message := range effects.Messages {
+		g.showScriptMessage(message)
+	}
+	if effects.LevelChange != nil {
+		change := effects.LevelChange
+		log.Printf("Script: level change to %d at [%d,%d] is not followed", change.Level, change.Pos.X, change.Pos.Y)
+	}
+	return effects.PartyMoved
+}
+
+// showScriptMessage adds a message to the text area, keeping the last maxScriptMessages.
+func (g *Game) showScriptMessage(message vm.Message) {
+	g.scriptMessages = append(g.scriptMessages, message.Text)
+	if len(g.scriptMessages) > maxScriptMessages {
+		g.scriptMessages = g.scriptMessages[len(g.scriptMessages)-maxScriptMessages:]
+	}
+	g.message = strings.Join(g.scriptMessages, "\r")
+	g.messageColor = byte(message.Color)
+	g.prevDirection = -1
+	log.Printf("Script message: %q", message.Text)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type action int
+
+const (
+	actionForward action = iota
+	actionBackward
+	actionStrafeLeft
+	actionStrafeRight
+	actionTurnLeft
+	actionTurnRight
+)
+
+const maxBufferedActions = 4
+
+// transition animates the change between two already rendered maze views.
+// The grid position of the Game is updated before the animation starts, the
+// transition is only a visual effect.
+type transition struct {
+	from, to    *ebiten.Image
+	action      action
+	frame       int
+	totalFrames int
+}
+
+func newTransition(from, to *ebiten.Image, a action, totalFrames int) *transition {
+	return &transition{from: from, to: to, action: a, totalFrames: totalFrames}
+}
+
+func (t *transition) advance() bool {
+	t.frame++
+	return t.frame >= t.totalFrames
+}
+
+func (t *transition) progress() float64 {
+	return float64(t.frame) / float64(t.totalFrames)
+}
+
+func (t *transition) draw(screen *ebiten.Image) {
+	p := t.progress()
+	switch t.action {
+	case actionForward:
+		drawZoomed(screen, t.from, 1+0.25*p, 1-p)
+		drawZoomed(screen, t.to, 0.8+0.2*p, p)
+	case actionBackward:
+		drawZoomed(screen, t.from, 1-0.2*p, 1-p)
+		drawZoomed(screen, t.to, 1.25-0.25*p, p)
+	case actionStrafeLeft, actionStrafeRight:
+		drawZoomed(screen, t.from, 1, 1-p)
+		drawZoomed(screen, t.to, 1, p)
+	case actionTurnLeft:
+		drawShifted(screen, t.from, p)
+		drawShifted(screen, t.to, p-1)
+	case actionTurnRight:
+		drawShifted(screen, t.from, -p)
+		drawShifted(screen, t.to, 1-p)
+	}
+}
+
+func drawZoomed(screen, image *ebiten.Image, scale, alpha float64) {
+	w, h := image.Bounds().Dx(), image.Bounds().Dy()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(-float64(w)/2, -float64(h)/2)
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(w)/2, float64(h)/2)
+	op.ColorScale.ScaleAlpha(float32(alpha))
+	op.Filter = ebiten.FilterLinear
+	screen.DrawImage(image, op)
+}
+
+// drawShifted draws the image moved horizontally by the given fraction of its width.
+func drawShifted(screen, image *ebiten.Image, fraction float64) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(fraction*float64(image.Bounds().Dx()), 0)
+	screen.DrawImage(image, op)
+}
@@ -0,0 +1,166 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"fmt"
+)
+
+// FlagWatch is a watched maze or global flag, or a flag of a monster. Target is inf.FlagMaze, inf.FlagGlobal or
+// inf.FlagMonster.
+type FlagWatch struct {
+	Target  byte
+	Monster int
+	Flag    int
+}
+
+func (w FlagWatch) String() string {
+	switch w.Target {
+	case inf.FlagMaze:
+		return fmt.Sprintf("maze flag %d", w.Flag)
+	case inf.FlagGlobal:
+		return fmt.Sprintf("global flag %d", w.Flag)
+	default:
+		return fmt.Sprintf("monster %d flag %d", w.Monster, w.Flag)
+	}
+}
+
+// FlagChange is a watched flag changed by the last steps.
+type FlagChange struct {
+	Watch    FlagWatch
+	Old, New int
+}
+
+// Debugger runs the scripts of an event one instruction or one expression token at a time. Thread is the running
+// script, nil when all scripts of the event are done. Effects collects the effects of the finished scripts.
+type Debugger struct {
+	Machine     *Machine
+	Breakpoints map[int]bool
+	Thread      *Thread
+	Effects     Effects
+	watches     []FlagWatch
+	values      []int
+	pending     []inf.Trigger
+}
+
+func NewDebugger(machine *Machine) *Debugger {
+	return &Debugger{Machine: machine, Breakpoints: make(map[int]bool)}
+}
+
+// Fire starts the scripts of the triggers at a block matching the event and returns their number. A running
+// event is abandoned.
+func (d *Debugger) Fire(x, y int, event Event) int {
+	d.Thread, d.pending, d.Effects = nil, nil, Effects{}
+	for _, trigger := range d.Machine.Level.Inf.Triggers {
+		if trigger.Pos.X == x && trigger.Pos.Y == y && trigger.FiresOn(event) {
+			d.pending = append(d.pending, trigger)
+		}
+	}
+	count := len(d.pending)
+	d.startNext(event)
+	return count
+}
+
+// Current returns the instruction at the PC of the running script, nil if no script runs.
+func (d *Debugger) Current() inf.Instruction {
+	if d.Thread == nil {
+		return nil
+	}
+	return d.Machine.Level.Inf.Script.At(d.Thread.PC)
+}
+
+// AtBreakpoint reports whether the running script is about to execute an instruction with a breakpoint.
+func (d *Debugger) AtBreakpoint() bool {
+	return d.Thread != nil && d.Thread.Token == 0 && d.Breakpoints[d.Thread.PC]
+}
+
+// Step executes the next instruction, the script of the next trigger starts after the last one.
+func (d *Debugger) Step() error {
+	return d.advance(d.Machine.Step)
+}
+
+// StepToken evaluates the next token of a conditional, see Machine.StepToken.
+func (d *Debugger) StepToken() error {
+	return d.advance(d.Machine.StepToken)
+}
+
+// Continue runs until an instruction with a breakpoint is reached or all scripts are done. It reports whether a
+// breakpoint stopped it.
+func (d *Debugger) Continue() (bool, error) {
+	for d.Thread != nil {
+		if err := d.Step(); err != nil {
+			return false, err
+		}
+		if d.AtBreakpoint() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *Debugger) advance(step func(thread *Thread) error) error {
+	if d.Thread == nil {
+		return fmt.Errorf("no script is running")
+	}
+
+	thread := d.Thread
+	err := step(thread)
+	if !thread.Done {
+		return err
+	}
+	d.Effects.merge(&thread.Effects)
+	if err != nil || thread.Effects.LevelChange != nil {
+		d.Thread, d.pending = nil, nil
+		return err
+	}
+	d.startNext(thread.Event)
+	return nil
+}
+
+func (d *Debugger) startNext(event Event) {
+	if len(d.pending) == 0 {
+		d.Thread = nil
+		return
+	}
+	d.Thread = d.Machine.Start(d.pending[0], event)
+	d.pending = d.pending[1:]
+}
+
+// Watch adds a flag to the watched flags.
+func (d *Debugger) Watch(watch FlagWatch) {
+	d.watches = append(d.watches, watch)
+	d.values = append(d.values, d.FlagValue(watch))
+}
+
+// Watches returns the watched flags.
+func (d *Debugger) Watches() []FlagWatch {
+	return d.watches
+}
+
+// FlagValue returns the current value of a flag, 0 or 1.
+func (d *Debugger) FlagValue(watch FlagWatch) int {
+	switch watch.Target {
+	case inf.FlagMaze:
+		return testBit(d.Machine.Level.MazeFlags, watch.Flag)
+	case inf.FlagGlobal:
+		return testBit(d.Machine.GlobalFlags, watch.Flag)
+	case inf.FlagMonster:
+		if watch.Monster < 0 || watch.Monster >= len(d.Machine.Level.MonsterFlags) {
+			return 0
+		}
+		return testBit(uint32(d.Machine.Level.MonsterFlags[watch.Monster]), watch.Flag)
+	}
+	return 0
+}
+
+// WatchChanges returns the watched flags changed since the last call.
+func (d *Debugger) WatchChanges() []FlagChange {
+	var changes []FlagChange
+	for i, watch := range d.watches {
+		value := d.FlagValue(watch)
+		if value != d.values[i] {
+			changes = append(changes, FlagChange{Watch: watch, Old: d.values[i], New: value})
+			d.values[i] = value
+		}
+	}
+	return changes
+}
@@ -0,0 +1,183 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// debugSource has two triggers on [1,1] and a conditional on [2,2], the instructions are numbered in the comments.
+const debugSource = `.trigger 0, [1,1], $08, _first
+.trigger 1, [1,1], $08, _second
+.trigger 2, [2,2], $08, _conditional
+.org $0010
+_first: SetFlag target=maze flag=1 ; 0
+Message text="first" color=1 ; 1
+EndCode ; 2
+_second: ClearFlag target=maze flag=1 ; 3
+SetFlag target=global flag=3 ; 4
+EndCode ; 5
+_conditional: Conditional { mazeFlag(1) 1 == } falseTarget=_false ; 6
+Message text="true" color=1 ; 7
+EndCode ; 8
+_false: Message text="false" color=1 ; 9
+EndCode ; 10
+`
+
+func newTestDebugger(t *testing.T) *Debugger {
+	t.Helper()
+	script, triggers, err := inf.Assemble(strings.NewReader(debugSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &formats.InfHeader{Triggers: triggers, Script: script}
+	return NewDebugger(NewMachine(NewParty(), NewLevelState(1, header, testMaze{}, nil), 1))
+}
+
+// address returns the address of an instruction of debugSource by its number.
+func address(d *Debugger, instruction int) int {
+	return d.Machine.Level.Inf.Script.Instructions[instruction].GetOffset()
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		// the instructions Continue stops at and the trigger running them
+		stops    []int
+		triggers []int
+	}{
+		{"none", nil, nil, nil},
+		{"first script", []int{1}, []int{1}, []int{0}},
+		{"next trigger", []int{3}, []int{3}, []int{1}},
+		{"both scripts", []int{1, 4}, []int{1, 4}, []int{0, 1}},
+		{"not reached", []int{7}, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestDebugger(t)
+			for _, breakpoint := range test.breakpoints {
+				d.Breakpoints[address(d, breakpoint)] = true
+			}
+			if count := d.Fire(1, 1, EventEnter); count != 2 {
+				t.Fatalf("%d triggers fired", count)
+			}
+
+			for i, stop := range test.stops {
+				stopped, err := d.Continue()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !stopped || !d.AtBreakpoint() || d.Thread.PC != address(d, stop) {
+					t.Fatalf("stop %d: stopped %v at %v instead of instruction %d", i, stopped, d.Current(), stop)
+				}
+				if d.Thread.Trigger.Index != test.triggers[i] {
+					t.Errorf("stop %d: trigger %d running instead of %d", i, d.Thread.Trigger.Index, test.triggers[i])
+				}
+			}
+			if stopped, err := d.Continue(); stopped || err != nil || d.Thread != nil {
+				t.Fatalf("stopped %v, %v, thread %v after the last breakpoint", stopped, err, d.Thread)
+			}
+			if len(d.Effects.Messages) != 1 || d.Effects.Messages[0].Text != "first" {
+				t.Errorf("messages %v", d.Effects.Messages)
+			}
+			if err := d.Step(); err == nil {
+				t.Error("step without a running script")
+			}
+		})
+	}
+}
+
+func TestDebuggerBreakpointAtStart(t *testing.T) {
+	d := newTestDebugger(t)
+	d.Breakpoints[address(d, 0)] = true
+	d.Fire(1, 1, EventEnter)
+	if !d.AtBreakpoint() {
+		t.Error("not at the breakpoint of the first instruction")
+	}
+	if err := d.Step(); err != nil || d.AtBreakpoint() || d.Thread.PC != address(d, 1) {
+		t.Errorf("step to %v, %v", d.Current(), err)
+	}
+}
+
+func TestDebuggerStepToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		mazeFlags uint32
+		stacks    [][]int
+		next      int
+	}{
+		{"true", 1 << 1, [][]int{{1}, {1, 1}, {1}}, 7},
+		{"false", 0, [][]int{{0}, {0, 1}, {0}}, 9},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestDebugger(t)
+			d.Machine.Level.MazeFlags = test.mazeFlags
+			d.Fire(2, 2, EventEnter)
+
+			for i, stack := range test.stacks {
+				if err := d.StepToken(); err != nil {
+					t.Fatal(err)
+				}
+				if d.Thread.PC != address(d, 6) || d.Thread.Token != i+1 {
+					t.Fatalf("token %d: PC $%04x token %d", i, d.Thread.PC, d.Thread.Token)
+				}
+				if values := d.Thread.Stack.Values(); !reflect.DeepEqual(values, stack) {
+					t.Errorf("token %d: stack %v instead of %v", i, values, stack)
+				}
+				if d.AtBreakpoint() {
+					t.Errorf("token %d: at a breakpoint inside the conditional", i)
+				}
+			}
+			// the step after the last token branches
+			if err := d.StepToken(); err != nil {
+				t.Fatal(err)
+			}
+			if d.Thread.PC != address(d, test.next) || d.Thread.Token != 0 {
+				t.Errorf("branched to %v instead of instruction %d", d.Current(), test.next)
+			}
+			// other instructions are stepped as a whole
+			if err := d.StepToken(); err != nil || len(d.Thread.Effects.Messages) != 1 {
+				t.Errorf("message not shown: %v", err)
+			}
+		})
+	}
+}
+
+func TestDebuggerWatchChanges(t *testing.T) {
+	d := newTestDebugger(t)
+	mazeFlag := FlagWatch{Target: inf.FlagMaze, Flag: 1}
+	globalFlag := FlagWatch{Target: inf.FlagGlobal, Flag: 3}
+	unchanged := FlagWatch{Target: inf.FlagMaze, Flag: 2}
+	for _, watch := range []FlagWatch{mazeFlag, globalFlag, unchanged} {
+		d.Watch(watch)
+	}
+	d.Fire(1, 1, EventEnter)
+
+	// the changes after every step of both scripts
+	steps := [][]FlagChange{
+		{{Watch: mazeFlag, Old: 0, New: 1}},
+		nil,
+		nil,
+		{{Watch: mazeFlag, Old: 1, New: 0}},
+		{{Watch: globalFlag, Old: 0, New: 1}},
+		nil,
+	}
+	for i, want := range steps {
+		if err := d.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if changes := d.WatchChanges(); !reflect.DeepEqual(changes, want) {
+			t.Errorf("step %d: changes %v instead of %v", i, changes, want)
+		}
+	}
+	if d.Thread != nil {
+		t.Errorf("%v still running", d.Current())
+	}
+	if changes := d.WatchChanges(); changes != nil {
+		t.Errorf("changes %v reported twice", changes)
+	}
+}
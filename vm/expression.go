@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"fmt"
+)
+
+// Evaluate runs a postfix expression of a conditional and returns the value left on the stack.
+func (m *Machine) Evaluate(expression []inf.Token, event Event) (int, error) {
+	return m.evaluate(inf.NewSimulatedStack(), expression, event)
+}
+
+// finishConditional evaluates the tokens of a conditional not evaluated by StepToken yet.
+func (m *Machine) finishConditional(thread *Thread, conditional *inf.Conditional) (int, error) {
+	stack, token := thread.Stack, thread.Token
+	thread.Stack, thread.Token = nil, 0
+	if stack == nil {
+		stack = inf.NewSimulatedStack()
+	}
+	return m.evaluate(stack, conditional.Expression[token:], thread.Event)
+}
+
+func (m *Machine) evaluate(stack *inf.SimulatedStack, expression []inf.Token, event Event) (int, error) {
+	for _, token := range expression {
+		if err := m.push(stack, token, event); err != nil {
+			return 0, err
+		}
+	}
+	if stack.Size() != 1 {
+		return 0, fmt.Errorf("expression leaves %d values", stack.Size())
+	}
+	return stack.Back(), nil
+}
+
+// push applies a token to the stack.
+func (m *Machine) push(stack *inf.SimulatedStack, token inf.Token, event Event) error {
+	party := m.Party
+	level := m.Level
+
+	switch t := token.(type) {
+	case *inf.Constant:
+		stack.PushBack(int(t.Value))
+	case *inf.Operator:
+		if stack.Size() < 2 {
+			return fmt.Errorf("operator %s needs two values", t.Symbol())
+		}
+		a := stack.PopBack()
+		b := stack.PopBack()
+		stack.PushBack(t.Apply(a, b))
+	case *inf.CountMonstersAt:
+		stack.PushBack(level.countMonstersAt(t.Pos))
+	case *inf.CountMonstersOfType:
+		for _, pair := range t.Types {
+			stack.PushBack(level.countMonstersOfType(pair.Type))
+			stack.PushBack(pair.Value)
+		}
+	case *inf.PartyVisible:
+		stack.PushBack(boolValue(!party.Invisible))
+	case *inf.RollDice:
+		stack.PushBack(m.rollDice(t.Rolls, t.Sides, t.Base))
+	case *inf.PartyContains:
+		stack.PushBack(boolValue(party.hasMember(t.Code, t.Value)))
+	case *inf.TriggerFlags:
+		stack.PushBack(int(event))
+	case *inf.PartyDirection:
+		stack.PushBack(party.Direction)
+	case *inf.GlobalFlag:
+		stack.PushBack(testBit(m.GlobalFlags, t.Flag))
+	case *inf.MazeFlag:
+		stack.PushBack(testBit(level.MazeFlags, t.Flag))
+	case *inf.PointerItem:
+		stack.PushBack(pointerItemProperty(party.Pointer, t))
+	case *inf.WallSide:
+		stack.PushBack(level.Maze.GetWall(t.Pos.X, t.Pos.Y, t.Side))
+	case *inf.PartyInventoryCount:
+		stack.PushBack(party.Inventory[t.ItemType])
+	case *inf.PartyAt:
+		stack.PushBack(boolValue(party.X == t.Pos.X && party.Y == t.Pos.Y))
+	case *inf.ItemsAt:
+		stack.PushBack(level.countItemsAt(t.ItemType, t.Pos))
+	case *inf.WallNumber:
+		// the side of the block facing the party
+		stack.PushBack(level.Maze.GetWall(t.Pos.X, t.Pos.Y, (party.Direction+2)&0x03))
+	default:
+		return fmt.Errorf("unknown token %T", token)
+	}
+	return nil
+}
+
+func (m *Machine) rollDice(rolls, sides, base int) int {
+	sum := base
+	for i := 0; i < rolls && sides > 0; i++ {
+		sum += m.random.Intn(sides) + 1
+	}
+	return sum
+}
+
+// pointerItemProperty returns a property of the item in the mouse pointer, the type is -1 and the other
+// properties are 0 with empty hands.
+func pointerItemProperty(item *Item, t *inf.PointerItem) int {
+	if item == nil {
+		if t.Property == inf.PointerItemType {
+			return -1
+		}
+		return 0
+	}
+
+	switch t.Property {
+	case inf.PointerItemType:
+		return item.Type
+	case inf.PointerItemAny:
+		return 1
+	case inf.PointerItemValue:
+		return item.Value
+	case inf.PointerItemUnidentName:
+		return boolValue(item.UnidentifiedName == t.Value)
+	case inf.PointerItemIdentName:
+		return boolValue(item.IdentifiedName == t.Value)
+	}
+	return 0
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
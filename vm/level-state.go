@@ -0,0 +1,137 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"log"
+)
+
+// Door wall mappings come in groups of 5 from index 3: the closed door, three half open states and the open door.
+const (
+	firstDoorWall = 3
+	lastDoorWall  = 22
+	doorStates    = 5
+)
+
+// Maze is the live wall data of a level, renderer.MazeRenderer draws the walls set through it.
+type Maze interface {
+	GetWall(x, y, side int) int
+	SetWall(x, y, side, wallMappingIndex int)
+}
+
+// mazWalls changes the walls of a MAZ file directly, for running the scripts without a renderer.
+type mazWalls struct {
+	maz *formats.Maz
+}
+
+// NewMazeFromMaz returns a Maze changing the blocks of a MAZ file.
+func NewMazeFromMaz(maz *formats.Maz) Maze {
+	return &mazWalls{maz: maz}
+}
+
+func (w *mazWalls) GetWall(x, y, side int) int {
+	return int(w.maz.GetMazeBlockByCoordinateOrFake(x, y).Wall[side&0x03])
+}
+
+func (w *mazWalls) SetWall(x, y, side, wallMappingIndex int) {
+	if x < 0 || y < 0 || x >= int(w.maz.Width) || y >= int(w.maz.Height) {
+		return
+	}
+	w.maz.GetMazeBlockByCoordinateOrFake(x, y).Wall[side&0x03] = byte(wallMappingIndex)
+}
+
+// LevelState is the state of the level the party is in. Items are the items of ITEM.DAT lying in the level,
+// MonsterFlags are indexed by the monsters of the INF header.
+type LevelState struct {
+	Level        int
+	Inf          *formats.InfHeader
+	Maze         Maze
+	Items        []formats.Item
+	MazeFlags    uint32
+	MonsterFlags [30]byte
+}
+
+func NewLevelState(level int, infHeader *formats.InfHeader, maze Maze, items []formats.Item) *LevelState {
+	return &LevelState{Level: level, Inf: infHeader, Maze: maze, Items: items}
+}
+
+// LoadLevelItems returns the items of ITEM.DAT lying in a level, none without the file.
+func LoadLevelItems(dataFiles map[string]*[]byte, level int) []formats.Item {
+	itemData, ok := dataFiles["ITEM.DAT"]
+	if !ok {
+		return nil
+	}
+	catalog, err := formats.NewItemCatalogFromByteArray(itemData)
+	if err != nil {
+		log.Printf("Scripts see no items: %s", err)
+		return nil
+	}
+
+	var items []formats.Item
+	for _, index := range catalog.GetItemsOfLevel(level) {
+		items = append(items, catalog.Items[index])
+	}
+	return items
+}
+
+// countMonstersAt returns the number of monsters of the INF header placed at a block.
+func (l *LevelState) countMonstersAt(pos inf.Position) int {
+	count := 0
+	for _, monster := range l.Inf.Monsters {
+		if monster.Pos != 0 && monster.Pos == pos.Word() {
+			count++
+		}
+	}
+	return count
+}
+
+// countMonstersOfType returns the number of monsters of the INF header with a type.
+func (l *LevelState) countMonstersOfType(monsterType int) int {
+	count := 0
+	for _, monster := range l.Inf.Monsters {
+		if monster.Pos != 0 && int(monster.Type) == monsterType {
+			count++
+		}
+	}
+	return count
+}
+
+// countItemsAt returns the number of items of a type at a block, inf.AnyItemType counts all items.
+func (l *LevelState) countItemsAt(itemType byte, pos inf.Position) int {
+	count := 0
+	for _, item := range l.Items {
+		if item.IsInMaze() && item.X() == pos.X && item.Y() == pos.Y && (itemType == inf.AnyItemType || int(item.Type) == int(itemType)) {
+			count++
+		}
+	}
+	return count
+}
+
+// doorBase returns the closed state of the door group of a wall mapping, false if the wall is not a door.
+func doorBase(wall int) (int, bool) {
+	if wall < firstDoorWall || wall > lastDoorWall {
+		return 0, false
+	}
+	return wall - (wall-firstDoorWall)%doorStates, true
+}
+
+// setDoor opens or closes the doors on all sides of a block and reports whether a wall changed.
+func (l *LevelState) setDoor(pos inf.Position, open bool) bool {
+	changed := false
+	for side := 0; side < 4; side++ {
+		wall := l.Maze.GetWall(pos.X, pos.Y, side)
+		base, ok := doorBase(wall)
+		if !ok {
+			continue
+		}
+		state := base
+		if open {
+			state = base + doorStates - 1
+		}
+		if wall != state {
+			l.Maze.SetWall(pos.X, pos.Y, side, state)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,317 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"fmt"
+	"log"
+	"math/rand"
+)
+
+// DefaultStepLimit stops scripts that loop forever.
+const DefaultStepLimit = 10000
+
+// Event is what happened at a block, it is passed to the scripts as the trigger flags.
+type Event = inf.TriggerEvent
+
+const (
+	EventEnter        = inf.EventPartyEnter
+	EventLeave        = inf.EventPartyLeave
+	EventItemDrop     = inf.EventItemDrop
+	EventItemPickup   = inf.EventItemPickup
+	EventMonsterEnter = inf.EventMonsterEnter
+	EventWallClick    = inf.EventWallClick
+)
+
+// Message is a text printed by a script, Text holds the raw bytes of the DOS code page.
+type Message struct {
+	Text  string
+	Color int
+}
+
+// Effects collects what the scripts of an event changed outside the level state.
+type Effects struct {
+	Messages     []Message
+	WallsChanged bool
+	PartyMoved   bool
+	LevelChange  *inf.ChangeLevel
+}
+
+func (e *Effects) merge(other *Effects) {
+	e.Messages = append(e.Messages, other.Messages...)
+	e.WallsChanged = e.WallsChanged || other.WallsChanged
+	e.PartyMoved = e.PartyMoved || other.PartyMoved
+	if other.LevelChange != nil {
+		e.LevelChange = other.LevelChange
+	}
+}
+
+// Thread is a running script: the address of the next instruction, the return addresses of the calls and the
+// effects so far. Done is set by EndCode, by the last Return and by a level change. While a conditional is
+// evaluated token by token, Stack holds the expression stack and Token the index of the next token.
+type Thread struct {
+	Trigger   inf.Trigger
+	Event     Event
+	PC        int
+	CallStack []int
+	Stack     *inf.SimulatedStack
+	Token     int
+	Steps     int
+	Effects   Effects
+	Done      bool
+}
+
+// Machine runs the scripts of a level against the party and the level state. GlobalFlags are kept across
+// levels.
+type Machine struct {
+	Party       *Party
+	Level       *LevelState
+	GlobalFlags uint32
+	StepLimit   int
+	random      *rand.Rand
+}
+
+func NewMachine(party *Party, level *LevelState, seed int64) *Machine {
+	return &Machine{Party: party, Level: level, StepLimit: DefaultStepLimit, random: rand.New(rand.NewSource(seed))}
+}
+
+// Fire runs the scripts of the triggers at a block matching the event, in the order of the trigger table.
+// The effects of the scripts run before an error are returned with it.
+func (m *Machine) Fire(x, y int, event Event) (*Effects, error) {
+	effects := &Effects{}
+	for _, trigger := range m.Level.Inf.Triggers {
+		if trigger.Pos.X != x || trigger.Pos.Y != y || !trigger.FiresOn(event) {
+			continue
+		}
+
+		thread := m.Start(trigger, event)
+		err := m.Run(thread)
+		effects.merge(&thread.Effects)
+		if err != nil {
+			return effects, err
+		}
+		if thread.Effects.LevelChange != nil {
+			break
+		}
+	}
+	return effects, nil
+}
+
+// Start returns a thread at the address of a trigger, Step and Run execute it.
+func (m *Machine) Start(trigger inf.Trigger, event Event) *Thread {
+	return &Thread{Trigger: trigger, Event: event, PC: trigger.Address}
+}
+
+// Run steps a thread until it is done.
+func (m *Machine) Run(thread *Thread) error {
+	for !thread.Done {
+		if err := m.Step(thread); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Step executes the instruction at the PC of a thread, the rest of a conditional evaluated with StepToken.
+func (m *Machine) Step(thread *Thread) error {
+	instruction, err := m.fetch(thread)
+	if err != nil || instruction == nil {
+		return err
+	}
+
+	thread.Steps++
+	if err := m.execute(thread, instruction); err != nil {
+		thread.Done = true
+		return fmt.Errorf("$%04x %s: %s", instruction.GetOffset(), instruction.Mnemonic(), err)
+	}
+	return nil
+}
+
+// StepToken evaluates the next token of the conditional at the PC of a thread, the step after the last token
+// branches. Other instructions are executed like Step.
+func (m *Machine) StepToken(thread *Thread) error {
+	instruction, err := m.fetch(thread)
+	if err != nil || instruction == nil {
+		return err
+	}
+	conditional, ok := instruction.(*inf.Conditional)
+	if !ok || thread.Token >= len(conditional.Expression) {
+		return m.Step(thread)
+	}
+
+	if thread.Stack == nil {
+		thread.Stack = inf.NewSimulatedStack()
+	}
+	if err := m.push(thread.Stack, conditional.Expression[thread.Token], thread.Event); err != nil {
+		thread.Done = true
+		return fmt.Errorf("$%04x %s: %s", instruction.GetOffset(), instruction.Mnemonic(), err)
+	}
+	thread.Token++
+	return nil
+}
+
+// fetch returns the instruction at the PC of a thread, nil if the thread is done.
+func (m *Machine) fetch(thread *Thread) (inf.Instruction, error) {
+	if thread.Done {
+		return nil, nil
+	}
+	if thread.Steps >= m.StepLimit {
+		thread.Done = true
+		return nil, fmt.Errorf("script of trigger at [%d,%d] stopped after %d steps", thread.Trigger.Pos.X, thread.Trigger.Pos.Y, thread.Steps)
+	}
+	instruction := m.Level.Inf.Script.At(thread.PC)
+	if instruction == nil {
+		thread.Done = true
+		return nil, fmt.Errorf("no instruction at $%04x", thread.PC)
+	}
+	return instruction, nil
+}
+
+func (m *Machine) execute(thread *Thread, instruction inf.Instruction) error {
+	effects := &thread.Effects
+	party := m.Party
+	thread.PC += len(instruction.Encode())
+
+	switch ins := instruction.(type) {
+	case *inf.SetWall:
+		switch ins.Type {
+		case inf.WallAllSides:
+			for side := 0; side < 4; side++ {
+				m.setWall(effects, ins.Pos, side, ins.To)
+			}
+		case inf.WallOneSide:
+			m.setWall(effects, ins.Pos, ins.Side, ins.To)
+		case inf.WallPartyDirection:
+			party.Direction = ins.Direction & 0x03
+			effects.PartyMoved = true
+		}
+	case *inf.ChangeWall:
+		switch ins.Type {
+		case inf.WallAllSides:
+			to := ins.To
+			if m.Level.Maze.GetWall(ins.Pos.X, ins.Pos.Y, 0) != ins.From {
+				to = ins.From
+			}
+			for side := 0; side < 4; side++ {
+				m.setWall(effects, ins.Pos, side, to)
+			}
+		case inf.WallOneSide:
+			to := ins.To
+			if m.Level.Maze.GetWall(ins.Pos.X, ins.Pos.Y, ins.Side) != ins.From {
+				to = ins.From
+			}
+			m.setWall(effects, ins.Pos, ins.Side, to)
+		case inf.WallOpenDoor:
+			effects.WallsChanged = m.Level.setDoor(ins.Pos, true) || effects.WallsChanged
+		}
+	case *inf.OpenDoor:
+		effects.WallsChanged = m.Level.setDoor(ins.Pos, true) || effects.WallsChanged
+	case *inf.CloseDoor:
+		effects.WallsChanged = m.Level.setDoor(ins.Pos, false) || effects.WallsChanged
+	case *inf.Teleport:
+		if ins.Type != inf.TargetParty {
+			m.skip(instruction)
+			break
+		}
+		party.X, party.Y = ins.Destination.X, ins.Destination.Y
+		effects.PartyMoved = true
+	case *inf.Message:
+		effects.Messages = append(effects.Messages, Message{Text: ins.Text, Color: ins.Color})
+	case *inf.SetFlag:
+		m.setFlag(instruction, ins.FlagOperand, true)
+	case *inf.ClearFlag:
+		m.setFlag(instruction, ins.FlagOperand, false)
+	case *inf.Jump:
+		thread.PC = ins.Target
+	case *inf.Call:
+		thread.CallStack = append(thread.CallStack, thread.PC)
+		thread.PC = ins.Target
+	case *inf.Return:
+		if len(thread.CallStack) == 0 {
+			thread.Done = true
+			break
+		}
+		thread.PC = thread.CallStack[len(thread.CallStack)-1]
+		thread.CallStack = thread.CallStack[:len(thread.CallStack)-1]
+	case *inf.EndCode:
+		thread.Done = true
+	case *inf.Conditional:
+		result, err := m.finishConditional(thread, ins)
+		if err != nil {
+			return err
+		}
+		if result == 0 {
+			thread.PC = ins.FalseTarget
+		}
+	case *inf.ChangeLevel:
+		if ins.Type == inf.LevelChange {
+			levelChange := *ins
+			effects.LevelChange = &levelChange
+			thread.Done = true
+			break
+		}
+		party.X, party.Y = ins.Pos.X, ins.Pos.Y
+		if ins.Direction < 4 {
+			party.Direction = ins.Direction
+		}
+		effects.PartyMoved = true
+	case *inf.Turn:
+		if ins.Type != inf.TurnParty {
+			m.skip(instruction)
+			break
+		}
+		party.Direction = (party.Direction + ins.Direction) & 0x03
+		effects.PartyMoved = true
+	case *inf.RawBytes:
+		return fmt.Errorf("cannot run undecoded bytes")
+	default:
+		m.skip(instruction)
+	}
+	return nil
+}
+
+// skip logs the instructions acting on monsters, items and characters, the viewer has none of them.
+func (m *Machine) skip(instruction inf.Instruction) {
+	log.Printf("Script: %s at $%04x is not simulated", instruction.Mnemonic(), instruction.GetOffset())
+}
+
+func (m *Machine) setWall(effects *Effects, pos inf.Position, side int, wall int) {
+	if m.Level.Maze.GetWall(pos.X, pos.Y, side) == wall {
+		return
+	}
+	m.Level.Maze.SetWall(pos.X, pos.Y, side, wall)
+	effects.WallsChanged = true
+}
+
+func (m *Machine) setFlag(instruction inf.Instruction, operand inf.FlagOperand, set bool) {
+	switch operand.Target {
+	case inf.FlagMaze:
+		m.Level.MazeFlags = changeBit(m.Level.MazeFlags, operand.Flag, set)
+	case inf.FlagGlobal:
+		m.GlobalFlags = changeBit(m.GlobalFlags, operand.Flag, set)
+	case inf.FlagMonster:
+		if operand.Monster < 0 || operand.Monster >= len(m.Level.MonsterFlags) {
+			return
+		}
+		flags := uint32(m.Level.MonsterFlags[operand.Monster])
+		m.Level.MonsterFlags[operand.Monster] = byte(changeBit(flags, operand.Flag, set))
+	default:
+		m.skip(instruction)
+	}
+}
+
+func changeBit(flags uint32, bit int, set bool) uint32 {
+	if bit < 0 || bit >= 32 {
+		return flags
+	}
+	if set {
+		return flags | 1<<bit
+	}
+	return flags &^ (1 << bit)
+}
+
+func testBit(flags uint32, bit int) int {
+	if bit < 0 || bit >= 32 {
+		return 0
+	}
+	return int(flags>>bit) & 1
+}
@@ -0,0 +1,192 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats"
+	"EOB1MazeViewer/formats/inf"
+	"strings"
+	"testing"
+)
+
+// testMaze is a maze of walls by block and side, unset walls are 0.
+type testMaze map[[3]int]int
+
+func (m testMaze) GetWall(x, y, side int) int {
+	return m[[3]int{x, y, side & 0x03}]
+}
+
+func (m testMaze) SetWall(x, y, side, wallMappingIndex int) {
+	m[[3]int{x, y, side & 0x03}] = wallMappingIndex
+}
+
+const testSource = `.trigger 0, [5,5], $08, _0x0010
+.trigger 1, [6,6], $10, _0x0040
+.trigger 2, [7,7], $00, _0x0050
+.trigger 3, [8,8], $08, _0x0070
+.trigger 4, [8,8], $08, _0x0078
+.trigger 5, [9,9], $80, _0x0080
+.org $0010
+_0x0010: Conditional { partyAt([5,5]) triggerFlags 1 == && } falseTarget=_0x0030
+SetWall type=all pos=[6,5] to=3
+Message text="Plate" color=12
+SetFlag target=global flag=2
+Call target=_0x0035
+OpenDoor pos=[8,8]
+EndCode
+_0x0030: Message text="not here" color=1
+_0x0035: Turn type=party direction=1
+Return
+_0x0040: Teleport type=party source=[0,0] destination=[2,9]
+ChangeWall type=side pos=[6,5] side=1 to=4 from=3
+EndCode
+_0x0050: Conditional { globalFlag(2) } falseTarget=_0x0060
+ChangeLevel type=level level=2 pos=[3,3] direction=1
+Message text="unreached" color=1
+_0x0060: Jump target=_0x0060
+_0x0070: ChangeLevel type=level level=3 pos=[1,1] direction=0
+_0x0078: Message text="after the level change" color=1
+EndCode
+_0x0080: Message text="first" color=1
+EndCode
+`
+
+func newTestMachine(t *testing.T) (*Machine, testMaze) {
+	t.Helper()
+	script, triggers, err := inf.Assemble(strings.NewReader(testSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &formats.InfHeader{Triggers: triggers, Script: script}
+	maze := testMaze{}
+	// a closed door on two sides of [8,8]
+	maze.SetWall(8, 8, 0, firstDoorWall)
+	maze.SetWall(8, 8, 2, firstDoorWall)
+	machine := NewMachine(NewParty(), NewLevelState(1, header, maze, nil), 1)
+	machine.Party.X, machine.Party.Y = 5, 5
+	return machine, maze
+}
+
+func TestFireEnter(t *testing.T) {
+	machine, maze := newTestMachine(t)
+	effects, err := machine.Fire(5, 5, EventEnter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(effects.Messages) != 1 || effects.Messages[0] != (Message{Text: "Plate", Color: 12}) {
+		t.Errorf("messages %v", effects.Messages)
+	}
+	if !effects.WallsChanged {
+		t.Error("walls not changed")
+	}
+	if !effects.PartyMoved || machine.Party.Direction != 1 {
+		t.Errorf("party not turned by the subroutine: moved %v, direction %d", effects.PartyMoved, machine.Party.Direction)
+	}
+	if machine.GlobalFlags != 1<<2 {
+		t.Errorf("global flags %b", machine.GlobalFlags)
+	}
+	for side := 0; side < 4; side++ {
+		if wall := maze.GetWall(6, 5, side); wall != 3 {
+			t.Errorf("wall %d of [6,5] is %d", side, wall)
+		}
+	}
+	open := firstDoorWall + doorStates - 1
+	if maze.GetWall(8, 8, 0) != open || maze.GetWall(8, 8, 2) != open || maze.GetWall(8, 8, 1) != 0 {
+		t.Errorf("door of [8,8] not opened: %d %d %d", maze.GetWall(8, 8, 0), maze.GetWall(8, 8, 1), maze.GetWall(8, 8, 2))
+	}
+}
+
+func TestFireConditionalFalse(t *testing.T) {
+	machine, maze := newTestMachine(t)
+	machine.Party.X = 4
+	effects, err := machine.Fire(5, 5, EventEnter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(effects.Messages) != 1 || effects.Messages[0].Text != "not here" {
+		t.Errorf("messages %v", effects.Messages)
+	}
+	if effects.WallsChanged || maze.GetWall(6, 5, 0) != 0 {
+		t.Error("walls changed by the skipped branch")
+	}
+}
+
+func TestFireEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		x, y  int
+		event Event
+		fires bool
+	}{
+		{"enter", 5, 5, EventEnter, true},
+		{"leave", 5, 5, EventLeave, false},
+		{"item drop", 5, 5, EventItemDrop, false},
+		{"wall click without flags", 5, 5, EventWallClick, true},
+		{"other block", 4, 5, EventEnter, false},
+		{"monster enter", 9, 9, EventMonsterEnter, true},
+		{"item drop without the flag", 9, 9, EventItemDrop, false},
+		{"enter without the flag", 9, 9, EventEnter, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine, _ := newTestMachine(t)
+			effects, err := machine.Fire(test.x, test.y, test.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fired := len(effects.Messages) > 0; fired != test.fires {
+				t.Errorf("fired %v, messages %v", fired, effects.Messages)
+			}
+		})
+	}
+}
+
+func TestFireTeleport(t *testing.T) {
+	machine, maze := newTestMachine(t)
+	maze.SetWall(6, 5, 1, 3)
+	effects, err := machine.Fire(6, 6, EventWallClick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !effects.PartyMoved || machine.Party.X != 2 || machine.Party.Y != 9 {
+		t.Errorf("party at [%d,%d], moved %v", machine.Party.X, machine.Party.Y, effects.PartyMoved)
+	}
+	if !effects.WallsChanged || maze.GetWall(6, 5, 1) != 4 {
+		t.Errorf("wall of [6,5] is %d", maze.GetWall(6, 5, 1))
+	}
+}
+
+func TestFireLevelChange(t *testing.T) {
+	machine, _ := newTestMachine(t)
+	machine.GlobalFlags = 1 << 2
+	effects, err := machine.Fire(7, 7, EventWallClick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effects.LevelChange == nil || effects.LevelChange.Level != 2 || effects.LevelChange.Pos != (inf.Position{X: 3, Y: 3}) {
+		t.Errorf("level change %v", effects.LevelChange)
+	}
+	if len(effects.Messages) != 0 || effects.PartyMoved {
+		t.Errorf("the script went on after the level change: %v", effects)
+	}
+}
+
+func TestFireStopsAfterLevelChange(t *testing.T) {
+	machine, _ := newTestMachine(t)
+	effects, err := machine.Fire(8, 8, EventEnter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if effects.LevelChange == nil || effects.LevelChange.Level != 3 {
+		t.Errorf("level change %v", effects.LevelChange)
+	}
+	if len(effects.Messages) != 0 {
+		t.Errorf("the next trigger of the block ran: %v", effects.Messages)
+	}
+}
+
+func TestFireStepLimit(t *testing.T) {
+	machine, _ := newTestMachine(t)
+	machine.StepLimit = 50
+	if _, err := machine.Fire(7, 7, EventWallClick); err == nil {
+		t.Error("endless loop not stopped")
+	}
+}
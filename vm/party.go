@@ -0,0 +1,88 @@
+package vm
+
+import (
+	"EOB1MazeViewer/formats/inf"
+	"encoding/json"
+	"os"
+)
+
+// Member is a character of the party with the race, alignment and class codes of the game.
+type Member struct {
+	Name      string `json:"name"`
+	Race      int    `json:"race"`
+	Alignment int    `json:"alignment"`
+	Class     int    `json:"class"`
+}
+
+// Item is the item held in the mouse pointer, the names are indices into the names of ITEM.DAT.
+type Item struct {
+	Type             int `json:"type"`
+	Value            int `json:"value"`
+	UnidentifiedName int `json:"unidentifiedName"`
+	IdentifiedName   int `json:"identifiedName"`
+}
+
+// Party is the party model the conditionals are evaluated with. Inventory counts the items of the party by
+// item type. The position is not part of the JSON, it is set by the caller and changed by the scripts.
+type Party struct {
+	X         int         `json:"-"`
+	Y         int         `json:"-"`
+	Direction int         `json:"-"`
+	Members   []Member    `json:"members"`
+	Inventory map[int]int `json:"inventory"`
+	Pointer   *Item       `json:"pointerItem"`
+	Invisible bool        `json:"invisible"`
+}
+
+// NewParty returns the default party: a human fighter, a dwarf cleric, an elf mage and a halfling thief
+// with empty hands and inventories.
+func NewParty() *Party {
+	return &Party{
+		Members: []Member{
+			{Name: "Fighter", Race: 0, Alignment: 0, Class: 0},
+			{Name: "Cleric", Race: 3, Alignment: 0, Class: 4},
+			{Name: "Mage", Race: 1, Alignment: 2, Class: 3},
+			{Name: "Thief", Race: 5, Alignment: 4, Class: 5},
+		},
+		Inventory: make(map[int]int),
+	}
+}
+
+// LoadParty reads a party from a JSON file, eg. {"members": [{"name": "Anya", "race": 0, "alignment": 0,
+// "class": 0}], "inventory": {"12": 1}, "pointerItem": {"type": 12, "value": 0}}.
+func LoadParty(fileName string) (*Party, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	party := &Party{}
+	if err := json.Unmarshal(data, party); err != nil {
+		return nil, err
+	}
+	if party.Inventory == nil {
+		party.Inventory = make(map[int]int)
+	}
+	return party, nil
+}
+
+// hasMember reports whether a member has the race, alignment or class selected by code.
+func (p *Party) hasMember(code byte, value int) bool {
+	for _, member := range p.Members {
+		switch code {
+		case inf.ExprRace:
+			if member.Race == value {
+				return true
+			}
+		case inf.ExprAlignment:
+			if member.Alignment == value {
+				return true
+			}
+		case inf.ExprClass:
+			if member.Class == value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"EOB1MazeViewer/renderer"
+	"EOB1MazeViewer/scaler"
+	"github.com/hajimehoshi/ebiten/v2"
+	"log"
+	"math"
+)
+
+const defaultWindowScale = 8
+
+// viewPlacement is where the view has been drawn in the window: integer scaled and centered,
+// the rest of the window stays black. The scales map viewport pixels to window pixels.
+type viewPlacement struct {
+	offsetX, offsetY float64
+	scaleX, scaleY   float64
+}
+
+// getCanvas returns the image the view and the transitions are drawn into, in the resolution of the presented view.
+func (g *Game) getCanvas() *ebiten.Image {
+	width, height := g.mazeView.Bounds().Dx(), g.mazeView.Bounds().Dy()
+	if g.canvas == nil || g.canvas.Bounds().Dx() != width || g.canvas.Bounds().Dy() != height {
+		g.canvas = ebiten.NewImage(width, height)
+	}
+	return g.canvas
+}
+
+// drawToWindow draws the canvas with the largest integer scale fitting the window, letterboxed.
+// Windows smaller than the canvas get a smooth downscale.
+func (g *Game) drawToWindow(screen *ebiten.Image, canvas *ebiten.Image) {
+	canvasWidth, canvasHeight := float64(canvas.Bounds().Dx()), float64(canvas.Bounds().Dy())
+	scale, offsetX, offsetY := scaler.Fit(canvas.Bounds().Dx(), canvas.Bounds().Dy(), screen.Bounds().Dx(), screen.Bounds().Dy())
+
+	op := &ebiten.DrawImageOptions{}
+	if scale < 1 {
+		op.Filter = ebiten.FilterLinear
+	}
+
+	g.placement = viewPlacement{
+		offsetX: offsetX,
+		offsetY: offsetY,
+		scaleX:  scale * canvasWidth / float64(g.getBaseWidth()),
+		scaleY:  scale * canvasHeight / float64(g.getBaseHeight()),
+	}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(g.placement.offsetX, g.placement.offsetY)
+	screen.DrawImage(canvas, op)
+}
+
+// getBaseWidth returns the width of the presented image before scaling: the viewport or the game screen.
+func (g *Game) getBaseWidth() int {
+	if g.showScreen {
+		return renderer.ScreenWidth
+	}
+	return renderer.ViewportWidth
+}
+
+func (g *Game) getBaseHeight() int {
+	if g.showScreen {
+		return renderer.ScreenHeight
+	}
+	return renderer.ViewportHeight
+}
+
+// cursorToView returns the viewport pixel under the mouse cursor.
+func (g *Game) cursorToView() (int, int) {
+	if g.placement.scaleX == 0 {
+		return -1, -1
+	}
+	cursorX, cursorY := ebiten.CursorPosition()
+	x := math.Floor((float64(cursorX) - g.placement.offsetX) / g.placement.scaleX)
+	y := math.Floor((float64(cursorY) - g.placement.offsetY) / g.placement.scaleY)
+	return int(x), int(y)
+}
+
+// cycleScaler switches to the next scaler, the cached views of the other scalers are kept.
+func (g *Game) cycleScaler() {
+	g.scaler = scaler.Next(g.scaler)
+	g.presentationChanged()
+	log.Printf("Scaler: %s", g.scaler.Name())
+}
+
+func (g *Game) toggleScreen() {
+	g.showScreen = !g.showScreen
+	g.presentationChanged()
+}
+
+func (g *Game) toggleCRT() {
+	g.postprocess.CRT = !g.postprocess.CRT
+	g.presentationChanged()
+}
+
+func (g *Game) toggleAspectCorrection() {
+	g.postprocess.AspectCorrection = !g.postprocess.AspectCorrection
+	g.presentationChanged()
+}
+
+// presentationChanged presents the current view again, a running transition would mix images of different sizes.
+func (g *Game) presentationChanged() {
+	g.transition = nil
+	g.bufferedActions = nil
+	g.prevDirection = -1
+	if g.freeRoam != nil {
+		g.freeRoam.changed = true
+	}
+}
+
+func toggleFullscreen() {
+	ebiten.SetFullscreen(!ebiten.IsFullscreen())
+}